@@ -0,0 +1,445 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// CO-RE port of bpfassets/perf_event/perf_event.c, which is compiled once with clang and relocated by the loader
+// using the kernel BTF, so that the nodes do not need the kernel headers.
+
+#include "vmlinux.h"
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_core_read.h>
+
+// the maps indexed by CPU are resized by the loader to the number of CPUs
+#ifndef NUM_CPUS
+#define NUM_CPUS 128
+#endif
+
+#ifndef CPU_REF_FREQ
+#define CPU_REF_FREQ 2500
+#endif
+
+#ifndef HZ
+#define HZ 1000
+#endif
+
+#define MAP_SIZE 32768
+
+// must match MaxHWCounters in the attacher
+#define MAX_HW_COUNTERS 8
+
+// vm_fault_reason flags of the handle_mm_fault return value
+#define KEPLER_VM_FAULT_MAJOR 0x0004
+#define KEPLER_VM_FAULT_RETRY 0x0400
+// VM_FAULT_OOM | VM_FAULT_SIGBUS | VM_FAULT_HWPOISON | VM_FAULT_HWPOISON_LARGE | VM_FAULT_SIGSEGV | VM_FAULT_FALLBACK
+#define KEPLER_VM_FAULT_ERROR 0x0873
+
+#define TASK_RUNNING 0
+
+// set by the loader when the cgroup id is collected, replaces the SET_GROUP_ID option of the bcc program
+const volatile u32 set_group_id = 0;
+// set by the loader, the maps indexed by CPU are resized accordingly
+const volatile u32 num_cpus = NUM_CPUS;
+// number of the hardware counters selected in addition to the default counters, set by the loader
+const volatile u32 num_hw_counters = 0;
+
+// the layout must match ProcessBPFMetrics in the collector
+typedef struct process_metrics_t
+{
+    u64 cgroup_id;
+    u64 pid;
+    u64 process_run_time;
+    u64 cpu_cycles;
+    u64 cpu_instr;
+    u64 cache_miss;
+    u64 voluntary_ctx_switches;
+    u64 involuntary_ctx_switches;
+    u64 cpu_migrations;
+    u64 page_faults_major;
+    u64 page_faults_minor;
+    u64 net_tx_bytes;
+    u64 net_rx_bytes;
+    u64 block_read_bytes;
+    u64 block_write_bytes;
+    u64 hw_counters[MAX_HW_COUNTERS]; // selected hardware counters, indexed by their slot
+    u16 vec_nr[10]; // irq counter, 10 is the max number of irq vectors
+    char comm[16];
+} process_metrics_t;
+
+typedef struct pid_time_t
+{
+    u32 pid;
+    u32 cpu;
+} pid_time_t;
+
+#define BPF_HASH(_name, _key_type, _leaf_type)  \
+    struct                                       \
+    {                                            \
+        __uint(type, BPF_MAP_TYPE_HASH);         \
+        __type(key, _key_type);                  \
+        __type(value, _leaf_type);               \
+        __uint(max_entries, MAP_SIZE);           \
+    } _name SEC(".maps");
+
+#define BPF_ARRAY(_name, _leaf_type)             \
+    struct                                       \
+    {                                            \
+        __uint(type, BPF_MAP_TYPE_ARRAY);        \
+        __type(key, u32);                        \
+        __type(value, _leaf_type);               \
+        __uint(max_entries, NUM_CPUS);           \
+    } _name SEC(".maps");
+
+#define BPF_PERF_ARRAY(_name)                    \
+    struct                                       \
+    {                                            \
+        __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY); \
+        __type(key, u32);                        \
+        __type(value, u32);                      \
+        __uint(max_entries, NUM_CPUS);           \
+    } _name SEC(".maps");
+
+// the selected hardware counters are indexed by slot * num_cpus + cpu
+#define BPF_HW_COUNTERS_ARRAY(_name, _type, _value_type) \
+    struct                                       \
+    {                                            \
+        __uint(type, _type);                     \
+        __type(key, u32);                        \
+        __type(value, _value_type);              \
+        __uint(max_entries, NUM_CPUS * MAX_HW_COUNTERS); \
+    } _name SEC(".maps");
+
+// processes and pid time
+BPF_HASH(processes, u64, process_metrics_t);
+BPF_HASH(pid_time, pid_time_t, u64);
+// on cpu time of each process per cpu
+BPF_HASH(pid_cpu_time, pid_time_t, u64);
+
+// perf counters
+BPF_PERF_ARRAY(cpu_cycles_hc_reader);
+BPF_ARRAY(cpu_cycles, u64);
+
+BPF_PERF_ARRAY(cpu_ref_cycles_hc_reader);
+BPF_ARRAY(cpu_ref_cycles, u64);
+
+BPF_PERF_ARRAY(cpu_instr_hc_reader);
+BPF_ARRAY(cpu_instr, u64);
+
+BPF_PERF_ARRAY(cache_miss_hc_reader);
+BPF_ARRAY(cache_miss, u64);
+
+// selected hardware counters
+BPF_HW_COUNTERS_ARRAY(hw_counters_hc_reader, BPF_MAP_TYPE_PERF_EVENT_ARRAY, u32);
+BPF_HW_COUNTERS_ARRAY(hw_counters, BPF_MAP_TYPE_ARRAY, u64);
+
+// cpu freq counters
+BPF_ARRAY(cpu_freq_array, u32);
+
+static inline u64 get_on_cpu_time(u32 cur_pid, u32 prev_pid, u32 cpu_id, u64 cur_ts)
+{
+    u64 cpu_time = 0;
+
+    // get pid time
+    pid_time_t prev_pid_key = {.pid = prev_pid, .cpu = cpu_id};
+    u64 *prev_ts = bpf_map_lookup_elem(&pid_time, &prev_pid_key);
+    if (prev_ts != 0)
+    {
+        // Probably a clock issue where the recorded on-CPU event had a
+        // timestamp later than the recorded off-CPU event, or vice versa.
+        // But do not return, since the hardware counters can be collected.
+        if (cur_ts > *prev_ts)
+        {
+            cpu_time = (cur_ts - *prev_ts) / 1000000; /*milisecond*/
+            bpf_map_delete_elem(&pid_time, &prev_pid_key);
+            if (cpu_time > 0)
+            {
+                u64 zero = 0;
+                bpf_map_update_elem(&pid_cpu_time, &prev_pid_key, &zero, BPF_NOEXIST);
+                u64 *prev_cpu_time = bpf_map_lookup_elem(&pid_cpu_time, &prev_pid_key);
+                if (prev_cpu_time != 0)
+                    *prev_cpu_time += cpu_time;
+            }
+        }
+    }
+    pid_time_t new_pid_key = {.pid = cur_pid, .cpu = cpu_id};
+    bpf_map_update_elem(&pid_time, &new_pid_key, &cur_ts, BPF_ANY);
+
+    return cpu_time;
+}
+
+static inline u64 normalize(u64 *counter, u64 *enabled, u64 *running)
+{
+    if (*running > 0)
+        return *counter * *enabled / *running;
+    return *counter;
+}
+
+static inline u64 calc_delta(u64 *prev_val, u64 *val)
+{
+    u64 delta = 0;
+    if (prev_val)
+    {
+        if (*val > *prev_val)
+            delta = *val - *prev_val;
+    }
+    return delta;
+}
+
+// read the delta of a hardware counter of the current cpu since the previous context switch on it
+static inline u64 get_on_cpu_counter(void *reader, void *prev_values, u32 *cpu_id)
+{
+    u64 delta = 0;
+    struct bpf_perf_event_value c = {};
+    int error = bpf_perf_event_read_value(reader, BPF_F_CURRENT_CPU, &c, sizeof(struct bpf_perf_event_value));
+    if (error == 0)
+    {
+        u64 val = normalize(&c.counter, &c.enabled, &c.running);
+        u64 *prev_val = bpf_map_lookup_elem(prev_values, cpu_id);
+        delta = calc_delta(prev_val, &val);
+        bpf_map_update_elem(prev_values, cpu_id, &val, BPF_ANY);
+    }
+    return delta;
+}
+
+// read the delta of a hardware counter at an index of the selected hardware counters
+static inline u64 get_on_cpu_hw_counter(u32 index)
+{
+    u64 delta = 0;
+    struct bpf_perf_event_value c = {};
+    int error = bpf_perf_event_read_value(&hw_counters_hc_reader, index, &c, sizeof(struct bpf_perf_event_value));
+    if (error == 0)
+    {
+        u64 val = normalize(&c.counter, &c.enabled, &c.running);
+        u64 *prev_val = bpf_map_lookup_elem(&hw_counters, &index);
+        delta = calc_delta(prev_val, &val);
+        bpf_map_update_elem(&hw_counters, &index, &val, BPF_ANY);
+    }
+    return delta;
+}
+
+// calculate the average cpu freq
+static inline u64 get_on_cpu_avg_freq(u32 *cpu_id, u64 on_cpu_cycles_delta, u64 on_cpu_ref_cycles_delta)
+{
+    u32 *avg_freq = bpf_map_lookup_elem(&cpu_freq_array, cpu_id);
+    if (avg_freq == 0 || on_cpu_ref_cycles_delta == 0)
+        return 0;
+    u32 freq = ((on_cpu_cycles_delta * CPU_REF_FREQ) / on_cpu_ref_cycles_delta) * HZ;
+    if (*avg_freq == 0)
+        *avg_freq = freq;
+    else
+        *avg_freq = (*avg_freq + freq) / 2;
+    return *avg_freq;
+}
+
+// task_struct->state was renamed __state in linux 5.14
+struct task_struct___pre_5_14
+{
+    long state;
+} __attribute__((preserve_access_index));
+
+static inline long get_task_state(struct task_struct *task)
+{
+    if (bpf_core_field_exists(task->__state))
+        return BPF_CORE_READ(task, __state);
+    return BPF_CORE_READ((struct task_struct___pre_5_14 *)task, state);
+}
+
+SEC("kprobe/finish_task_switch")
+int BPF_KPROBE(kprobe__finish_task_switch, struct task_struct *prev)
+{
+    u64 cur_pid = bpf_get_current_pid_tgid() >> 32;
+    u64 cgroup_id = 0;
+    if (set_group_id)
+        cgroup_id = bpf_get_current_cgroup_id();
+
+    u64 cur_ts = bpf_ktime_get_ns();
+    u32 cpu_id = bpf_get_smp_processor_id();
+    u64 prev_pid = BPF_CORE_READ(prev, pid);
+    u64 on_cpu_time_delta = get_on_cpu_time(cur_pid, prev_pid, cpu_id, cur_ts);
+    u64 on_cpu_cycles_delta = get_on_cpu_counter(&cpu_cycles_hc_reader, &cpu_cycles, &cpu_id);
+    u64 on_cpu_ref_cycles_delta = get_on_cpu_counter(&cpu_ref_cycles_hc_reader, &cpu_ref_cycles, &cpu_id);
+    u64 on_cpu_instr_delta = get_on_cpu_counter(&cpu_instr_hc_reader, &cpu_instr, &cpu_id);
+    u64 on_cpu_cache_miss_delta = get_on_cpu_counter(&cache_miss_hc_reader, &cache_miss, &cpu_id);
+    get_on_cpu_avg_freq(&cpu_id, on_cpu_cycles_delta, on_cpu_ref_cycles_delta);
+    u64 on_cpu_hw_counters_delta[MAX_HW_COUNTERS] = {};
+#pragma unroll
+    for (u32 i = 0; i < MAX_HW_COUNTERS; i++)
+    {
+        if (i >= num_hw_counters)
+            break;
+        on_cpu_hw_counters_delta[i] = get_on_cpu_hw_counter(i * num_cpus + cpu_id);
+    }
+
+    // store process metrics
+    struct process_metrics_t *process_metrics;
+    process_metrics = bpf_map_lookup_elem(&processes, &prev_pid);
+    if (process_metrics != 0)
+    {
+        // update process time
+        process_metrics->process_run_time += on_cpu_time_delta;
+
+        process_metrics->cpu_cycles += on_cpu_cycles_delta;
+        process_metrics->cpu_instr += on_cpu_instr_delta;
+        process_metrics->cache_miss += on_cpu_cache_miss_delta;
+#pragma unroll
+        for (u32 i = 0; i < MAX_HW_COUNTERS; i++)
+            process_metrics->hw_counters[i] += on_cpu_hw_counters_delta[i];
+
+        // the previous task was preempted if it is still runnable, otherwise it gave up the cpu, e.g. to wait for I/O
+        if (get_task_state(prev) == TASK_RUNNING)
+            process_metrics->involuntary_ctx_switches++;
+        else
+            process_metrics->voluntary_ctx_switches++;
+    }
+
+    process_metrics = bpf_map_lookup_elem(&processes, &cur_pid);
+    if (process_metrics == 0)
+    {
+        process_metrics_t new_process = {};
+        new_process.pid = cur_pid;
+        new_process.cgroup_id = cgroup_id;
+        bpf_get_current_comm(&new_process.comm, sizeof(new_process.comm));
+        bpf_map_update_elem(&processes, &cur_pid, &new_process, BPF_NOEXIST);
+    }
+
+    return 0;
+}
+
+// per https://www.kernel.org/doc/html/latest/core-api/tracepoint.html#c.trace_softirq_entry
+SEC("tracepoint/irq/softirq_entry")
+int tracepoint__irq__softirq_entry(struct trace_event_raw_softirq *ctx)
+{
+    u64 cur_pid = bpf_get_current_pid_tgid() >> 32;
+    struct process_metrics_t *process_metrics;
+    u32 vec = ctx->vec;
+    process_metrics = bpf_map_lookup_elem(&processes, &cur_pid);
+    if (process_metrics != 0)
+    {
+        if (vec < 10)
+        {
+            process_metrics->vec_nr[vec]++;
+        }
+    }
+    return 0;
+}
+
+// per https://www.kernel.org/doc/html/latest/core-api/tracepoint.html#c.trace_sched_migrate_task
+SEC("tracepoint/sched/sched_migrate_task")
+int tracepoint__sched__sched_migrate_task(struct trace_event_raw_sched_migrate_task *ctx)
+{
+    u64 pid = ctx->pid;
+    struct process_metrics_t *process_metrics;
+    process_metrics = bpf_map_lookup_elem(&processes, &pid);
+    if (process_metrics != 0)
+    {
+        process_metrics->cpu_migrations++;
+    }
+    return 0;
+}
+
+// the page faults of the user space are handled by handle_mm_fault, which reports whether the fault required I/O
+SEC("kretprobe/handle_mm_fault")
+int BPF_KRETPROBE(kretprobe__handle_mm_fault, u32 ret)
+{
+    u64 cur_pid = bpf_get_current_pid_tgid() >> 32;
+    // the retried faults are handled again, so they are only counted once
+    if (ret & (KEPLER_VM_FAULT_ERROR | KEPLER_VM_FAULT_RETRY))
+        return 0;
+    struct process_metrics_t *process_metrics;
+    process_metrics = bpf_map_lookup_elem(&processes, &cur_pid);
+    if (process_metrics != 0)
+    {
+        if (ret & KEPLER_VM_FAULT_MAJOR)
+            process_metrics->page_faults_major++;
+        else
+            process_metrics->page_faults_minor++;
+    }
+    return 0;
+}
+
+// the network and block I/O bytes are attributed to the process of the current task
+static inline struct process_metrics_t *get_current_process_metrics()
+{
+    u64 cur_pid = bpf_get_current_pid_tgid() >> 32;
+    return bpf_map_lookup_elem(&processes, &cur_pid);
+}
+
+// the send functions return the number of bytes sent or a negative error
+SEC("kretprobe/tcp_sendmsg")
+int BPF_KRETPROBE(kretprobe__tcp_sendmsg, int ret)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && ret > 0)
+        process_metrics->net_tx_bytes += ret;
+    return 0;
+}
+
+SEC("kretprobe/udp_sendmsg")
+int BPF_KRETPROBE(kretprobe__udp_sendmsg, int ret)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && ret > 0)
+        process_metrics->net_tx_bytes += ret;
+    return 0;
+}
+
+SEC("kretprobe/udpv6_sendmsg")
+int BPF_KRETPROBE(kretprobe__udpv6_sendmsg, int ret)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && ret > 0)
+        process_metrics->net_tx_bytes += ret;
+    return 0;
+}
+
+// tcp_cleanup_rbuf is called with the number of bytes copied to the user space by tcp_recvmsg
+SEC("kprobe/tcp_cleanup_rbuf")
+int BPF_KPROBE(kprobe__tcp_cleanup_rbuf, struct sock *sk, int copied)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && copied > 0)
+        process_metrics->net_rx_bytes += copied;
+    return 0;
+}
+
+// skb_consume_udp is called with the number of bytes copied to the user space by udp_recvmsg and udpv6_recvmsg
+SEC("kprobe/skb_consume_udp")
+int BPF_KPROBE(kprobe__skb_consume_udp, struct sock *sk, struct sk_buff *skb, int len)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && len > 0)
+        process_metrics->net_rx_bytes += len;
+    return 0;
+}
+
+// per https://www.kernel.org/doc/html/latest/core-api/tracepoint.html#c.trace_block_rq_issue
+// the request is attributed to the task issuing it, the writeback of the page cache is done by the kernel flusher threads
+SEC("tracepoint/block/block_rq_issue")
+int tracepoint__block__block_rq_issue(struct trace_event_raw_block_rq *ctx)
+{
+    u64 bytes = ctx->bytes;
+    // the flush flag precedes the operation, e.g. FWS for a write with preflush
+    char op = ctx->rwbs[0] == 'F' ? ctx->rwbs[1] : ctx->rwbs[0];
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics == 0)
+        return 0;
+    if (op == 'R')
+        process_metrics->block_read_bytes += bytes;
+    else if (op == 'W')
+        process_metrics->block_write_bytes += bytes;
+    return 0;
+}
+
+char _license[] SEC("license") = "GPL";
@@ -60,6 +60,7 @@ var (
 	memProfile                   = flag.String("memprofile", "", "dump mem profile to a file")
 	profileDuration              = flag.Int("profile-duration", 60, "duration in seconds")
 	enabledMSR                   = flag.Bool("enable-msr", false, "whether MSR is allowed to obtain energy data")
+	samplePeriodSec              = flag.Int("sample-period", config.SamplePeriodSec, "time in seconds between two metric collections")
 )
 
 func healthProbe(w http.ResponseWriter, req *http.Request) {
@@ -70,10 +71,12 @@ func healthProbe(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func finalizing() {
-	stack := "exit stack: \n" + string(debug.Stack())
-	klog.Infof(stack)
-	exitCode := 10
+// finalizing flushes the logs and exits, the exit code is 0 when kepler was stopped by a signal
+func finalizing(exitCode int) {
+	if exitCode != 0 {
+		stack := "exit stack: \n" + string(debug.Stack())
+		klog.Infof(stack)
+	}
 	klog.Infoln(finishingMsg)
 	klog.FlushAndExit(klog.ExitFlushTimeout, exitCode)
 }
@@ -141,7 +144,8 @@ func startProfiling(cpuProfile, memProfile string) {
 
 func main() {
 	start := time.Now()
-	defer finalizing()
+	exitCode := 10
+	defer func() { finalizing(exitCode) }()
 	klog.InitFlags(nil)
 	flag.Parse()
 
@@ -153,6 +157,7 @@ func main() {
 	config.SetEnabledHardwareCounterMetrics(*exposeHardwareCounterMetrics)
 	config.SetEnabledGPU(*enableGPU)
 	config.EnabledMSR = *enabledMSR
+	config.SetSamplePeriodSec(*samplePeriodSec)
 
 	config.LogConfigs()
 
@@ -179,8 +184,9 @@ func main() {
 	m := manager.New()
 	prometheus.MustRegister(version.NewCollector("kepler_exporter"))
 	prometheus.MustRegister(m.PrometheusCollector)
-	defer m.MetricCollector.Destroy()
+	// the collector is stopped before the power meters, which it reads until it is stopped
 	defer components.StopPower()
+	defer m.Stop()
 
 	// starting a new gorotine to collect data and report metrics
 	if err := m.Start(); err != nil {
@@ -211,6 +217,15 @@ func main() {
 
 	klog.Infof(startedMsg, time.Since(start))
 	klog.Flush() // force flush to parse the start msg in the e2e test
-	err := <-ch
-	klog.Fatalf("%s", fmt.Sprintf("failed to bind on %s: %v", bindAddressConfig, err))
+
+	// return on SIGINT and SIGTERM so that the deferred functions release the collector and save its state
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-ch:
+		klog.Fatalf("%s", fmt.Sprintf("failed to bind on %s: %v", bindAddressConfig, err))
+	case sig := <-sigs:
+		klog.Infof("received signal %v, stopping", sig)
+		exitCode = 0
+	}
 }
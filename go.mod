@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/NVIDIA/go-nvml v0.11.6-0
+	github.com/cilium/ebpf v0.9.3
 	github.com/iovisor/gobpf v0.2.0
 	github.com/jszwec/csvutil v1.7.1
 	github.com/onsi/ginkgo/v2 v2.4.0
@@ -13,9 +14,11 @@ require (
 	github.com/prometheus/common v0.37.0
 	github.com/prometheus/prometheus v0.40.3
 	golang.org/x/sys v0.5.0
+	google.golang.org/grpc v1.50.1
 	k8s.io/api v0.25.3
 	k8s.io/apimachinery v0.25.3
 	k8s.io/client-go v0.25.3
+	k8s.io/cri-api v0.25.3
 	k8s.io/klog/v2 v2.80.0
 )
 
@@ -26,6 +29,7 @@ require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/emicklei/go-restful/v3 v3.8.0 // indirect
+	github.com/evanphx/json-patch v4.12.0+incompatible // indirect
 	github.com/go-logr/logr v1.2.3 // indirect
 	github.com/go-openapi/jsonpointer v0.19.5 // indirect
 	github.com/go-openapi/jsonreference v0.19.6 // indirect
@@ -53,6 +57,7 @@ require (
 	golang.org/x/text v0.7.0 // indirect
 	golang.org/x/time v0.1.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
+	google.golang.org/genproto v0.0.0-20221027153422-115e99e71e1c // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
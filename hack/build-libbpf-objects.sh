@@ -0,0 +1,25 @@
+#!/usr/bin/env bash
+#
+# Compiles the CO-RE eBPF program embedded in kepler for the libbpf backend.
+# Requires clang, bpftool and the libbpf headers; the kernel BTF is only needed to generate vmlinux.h.
+set -euo pipefail
+
+GOARCH=${GOARCH:-$(go env GOARCH)}
+SRC_DIR="bpfassets/libbpf/src"
+OUTPUT_DIR="pkg/bpfassets/libbpf"
+VMLINUX_H="${SRC_DIR}/vmlinux.h"
+
+case "${GOARCH}" in
+	amd64) TARGET_ARCH=x86 ;;
+	arm64) TARGET_ARCH=arm64 ;;
+	s390x) TARGET_ARCH=s390 ;;
+	*) echo "unsupported architecture ${GOARCH}" >&2 && exit 1 ;;
+esac
+
+if [ ! -f "${VMLINUX_H}" ]; then
+	bpftool btf dump file /sys/kernel/btf/vmlinux format c > "${VMLINUX_H}"
+fi
+
+clang -g -O2 -target bpf -D__TARGET_ARCH_${TARGET_ARCH} \
+	-I"${SRC_DIR}" -c "${SRC_DIR}/kepler.bpf.c" -o "${OUTPUT_DIR}/kepler.${GOARCH}.o"
+llvm-strip -g "${OUTPUT_DIR}/kepler.${GOARCH}.o"
@@ -0,0 +1,176 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attacher
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+
+	assets "github.com/sustainable-computing-io/kepler/pkg/bpfassets"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// BCCBackend compiles the eBPF program at runtime, which requires the kernel headers on the node
+	BCCBackend = "bcc"
+	// LibbpfBackend loads the pre-compiled CO-RE eBPF program, which requires the kernel BTF
+	LibbpfBackend = "libbpf"
+	// AutoBackend uses the libbpf backend if possible and falls back to bcc
+	AutoBackend = "auto"
+)
+
+// BpfModule is the eBPF program loaded by a backend
+type BpfModule interface {
+	Close()
+}
+
+// BpfTable is an eBPF map read by the collector
+type BpfTable interface {
+	Iter() BpfTableIterator
+	DeleteAll() error
+}
+
+// BpfTableIterator lists the raw entries of an eBPF map
+type BpfTableIterator interface {
+	// Next looks up the next element and return true if one is available.
+	Next() bool
+	// Key returns the current key value of the iterator, the slice is valid only until the next call to Next.
+	Key() []byte
+	// Leaf returns the current leaf value of the iterator, the slice is valid only until the next call to Next.
+	Leaf() []byte
+}
+
+type BpfModuleTables struct {
+	// Backend is the backend that loaded the eBPF program, i.e. bcc or libbpf
+	Backend      string
+	Module       BpfModule
+	Table        BpfTable
+	CPUFreqTable BpfTable
+	CPUTimeTable BpfTable
+}
+
+type perfCounter struct {
+	evType   int
+	evConfig int
+	enabled  bool
+	// slot is the index of the counter in the hw_counters array of the eBPF program, or defaultCounterSlot for the
+	// default counters which have their own perf event array
+	slot int
+}
+
+var (
+	// defaultCounters are collected when HARDWARE_COUNTERS is empty, the cpu cycles are also used to compute the cpu frequency
+	defaultCounters = map[string]perfCounter{
+		CPUCycleLabel:       {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_CPU_CYCLES, enabled: true, slot: defaultCounterSlot},
+		CPURefCycleLabel:    {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_REF_CPU_CYCLES, enabled: true, slot: defaultCounterSlot},
+		CPUInstructionLabel: {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_INSTRUCTIONS, enabled: true, slot: defaultCounterSlot},
+		CacheMissLabel:      {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_CACHE_MISSES, enabled: true, slot: defaultCounterSlot},
+	}
+	// Counters are the hardware counters selected by HARDWARE_COUNTERS
+	Counters                = newCounters(config.HardwareCounters)
+	HardwareCountersEnabled = isCPUFrequencyCounted(Counters)
+	bpfPerfArrayPrefix      = "_hc_reader"
+)
+
+// AttachBPFAssets loads the eBPF program with the backend selected by BPF_BACKEND
+func AttachBPFAssets() (*BpfModuleTables, error) {
+	switch config.BPFBackend {
+	case BCCBackend:
+		return attachBCCModule()
+	case LibbpfBackend:
+		return attachLibbpfModule()
+	case AutoBackend, "":
+		m, libbpfErr := attachLibbpfModule()
+		if libbpfErr == nil {
+			return m, nil
+		}
+		klog.Infof("failed to load the eBPF program with libbpf, trying bcc: %v", libbpfErr)
+		m, bccErr := attachBCCModule()
+		if bccErr != nil {
+			return nil, fmt.Errorf("failed to load the eBPF program with libbpf: %v, and with bcc: %v", libbpfErr, bccErr)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown BPF_BACKEND %q, supported backends are %s, %s and %s", config.BPFBackend, AutoBackend, LibbpfBackend, BCCBackend)
+}
+
+func DetachBPFModules(bpfModules *BpfModuleTables) {
+	bpfModules.Module.Close()
+}
+
+// isBackendAvailable returns true if the eBPF program can be loaded by a backend of this build
+func isBackendAvailable() bool {
+	switch config.BPFBackend {
+	case BCCBackend:
+		return bccAvailable
+	case LibbpfBackend:
+		return assets.HasLibbpfObject()
+	case AutoBackend, "":
+		return bccAvailable || assets.HasLibbpfObject()
+	}
+	return false
+}
+
+func GetEnabledHWCounters() []string {
+	var metrics []string
+	klog.V(5).Infof("hardeware counter metrics config %t", config.ExposeHardwareCounterMetrics)
+	if !config.ExposeHardwareCounterMetrics {
+		klog.V(5).Info("hardeware counter metrics not enabled")
+		return metrics
+	}
+	if !isBackendAvailable() {
+		return metrics
+	}
+
+	for metric, counter := range Counters {
+		if counter.enabled {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+func GetEnabledBPFCounters() []string {
+	var metrics []string
+	if !isBackendAvailable() {
+		return metrics
+	}
+	metrics = append(metrics, config.CPUTime)
+
+	klog.V(5).Infof("task event metrics config %t", config.ExposeTaskEventMetrics)
+	if config.ExposeTaskEventMetrics {
+		metrics = append(metrics, TaskEventCounters...)
+	}
+	klog.V(5).Infof("bpf I/O metrics config %t", config.ExposeBPFIOMetrics)
+	if config.ExposeBPFIOMetrics {
+		metrics = append(metrics, IOCounters...)
+	}
+
+	klog.V(5).Infof("irq counter metrics config %t", config.ExposeIRQCounterMetrics)
+	if !config.ExposeIRQCounterMetrics {
+		klog.V(5).Info("irq counter metrics not enabled")
+		return metrics
+	}
+	metrics = append(metrics, config.IRQNetTXLabel)
+	metrics = append(metrics, config.IRQNetRXLabel)
+	metrics = append(metrics, config.IRQBlockLabel)
+
+	return metrics
+}
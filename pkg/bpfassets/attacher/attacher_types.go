@@ -26,6 +26,19 @@ const (
 	CPUInstructionLabel = config.CPUInstruction
 	CacheMissLabel      = config.CacheMiss
 
+	// task events counted per process
+	VoluntaryCtxSwitchLabel   = config.VoluntaryCtxSwitch
+	InvoluntaryCtxSwitchLabel = config.InvoluntaryCtxSwitch
+	CPUMigrationLabel         = config.CPUMigration
+	PageFaultMajorLabel       = config.PageFaultMajor
+	PageFaultMinorLabel       = config.PageFaultMinor
+
+	// network and block I/O bytes counted per process
+	NetTxBytesLabel      = config.NetTxBytes
+	NetRxBytesLabel      = config.NetRxBytes
+	BlockReadBytesLabel  = config.BlockReadBytes
+	BlockWriteBytesLabel = config.BlockWriteBytes
+
 	// Per /sys/kernel/debug/tracing/events/irq/softirq_entry/format
 	// { 0, "HI" }, { 1, "TIMER" }, { 2, "NET_TX" }, { 3, "NET_RX" }, { 4, "BLOCK" }, { 5, "IRQ_POLL" }, { 6, "TASKLET" }, { 7, "SCHED" }, { 8, "HRTIMER" }, { 9, "RCU" }
 
@@ -34,3 +47,28 @@ const (
 	IRQNetRX = 3
 	IRQBlock = 4
 )
+
+// TaskEventCounters are the context switch, migration and page fault counters of the processes
+var TaskEventCounters = []string{VoluntaryCtxSwitchLabel, InvoluntaryCtxSwitchLabel, CPUMigrationLabel, PageFaultMajorLabel, PageFaultMinorLabel}
+
+// IOCounters are the network and block I/O bytes of the processes
+var IOCounters = []string{NetTxBytesLabel, NetRxBytesLabel, BlockReadBytesLabel, BlockWriteBytesLabel}
+
+// BPFStatCounters are all the counters of the processes that are counted by the eBPF program, besides the cpu time and hardware counters
+var BPFStatCounters = append(append([]string{}, TaskEventCounters...), IOCounters...)
+
+// ioKprobe is a kernel function probed by the eBPF program to count the network bytes of the processes
+type ioKprobe struct {
+	function string
+	program  string
+	isReturn bool
+}
+
+// ioKprobes count the bytes returned by the send functions and the bytes copied by the receive functions
+var ioKprobes = []ioKprobe{
+	{function: "tcp_sendmsg", program: "kretprobe__tcp_sendmsg", isReturn: true},
+	{function: "udp_sendmsg", program: "kretprobe__udp_sendmsg", isReturn: true},
+	{function: "udpv6_sendmsg", program: "kretprobe__udpv6_sendmsg", isReturn: true},
+	{function: "tcp_cleanup_rbuf", program: "kprobe__tcp_cleanup_rbuf"},
+	{function: "skb_consume_udp", program: "kprobe__skb_consume_udp"},
+}
@@ -21,11 +21,8 @@ package attacher
 
 import (
 	"fmt"
-	"runtime"
 	"strconv"
 
-	"golang.org/x/sys/unix"
-
 	assets "github.com/sustainable-computing-io/kepler/pkg/bpfassets"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 
@@ -34,30 +31,29 @@ import (
 	"k8s.io/klog/v2"
 )
 
-type BpfModuleTables struct {
-	Module       *bpf.Module
-	Table        *bpf.Table
-	CPUFreqTable *bpf.Table
+// bccAvailable is true when kepler is built with the bcc build tag
+const bccAvailable = true
+
+// bccTable adapts the bcc table iterator to BpfTable
+type bccTable struct {
+	*bpf.Table
 }
 
-type perfCounter struct {
-	evType   int
-	evConfig int
-	enabled  bool
+func (t bccTable) Iter() BpfTableIterator {
+	return t.Table.Iter()
 }
 
-var (
-	Counters = map[string]perfCounter{
-		CPUCycleLabel:       {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CPU_CYCLES, true},
-		CPURefCycleLabel:    {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_REF_CPU_CYCLES, true},
-		CPUInstructionLabel: {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_INSTRUCTIONS, true},
-		CacheMissLabel:      {unix.PERF_TYPE_HARDWARE, unix.PERF_COUNT_HW_CACHE_MISSES, true},
-	}
-	HardwareCountersEnabled = true
-	bpfPerfArrayPrefix      = "_hc_reader"
-)
+// bccModule closes the perf events opened for the bcc module
+type bccModule struct {
+	*bpf.Module
+}
+
+func (m bccModule) Close() {
+	closePerfEvent()
+	m.Module.Close()
+}
 
-func loadModule(objProg []byte, options []string) (m *bpf.Module, err error) {
+func loadModule(objProg []byte, options []string, numCPUs int) (m *bpf.Module, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("failed to attach the bpf program: %v", err)
@@ -85,41 +81,41 @@ func loadModule(objProg []byte, options []string) (m *bpf.Module, err error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to attach softirq_entry: %s", err)
 	}
+	if config.ExposeTaskEventMetrics {
+		attachBCCTaskEventProbes(m)
+	}
+	if config.ExposeBPFIOMetrics {
+		attachBCCIOProbes(m)
+	}
 
-	for arrayName, counter := range Counters {
-		bpfPerfArrayName := arrayName + bpfPerfArrayPrefix
-		t := bpf.NewTable(m.TableId(bpfPerfArrayName), m)
+	openHWCounters(numCPUs, func(perfArrayName string, offset int, counter perfCounter) error {
+		t := bpf.NewTable(m.TableId(perfArrayName), m)
 		if t == nil {
-			return nil, fmt.Errorf("failed to find perf array: %s", bpfPerfArrayName)
+			return fmt.Errorf("failed to find perf array: %s", perfArrayName)
 		}
-		perfErr := openPerfEvent(t, counter.evType, counter.evConfig)
-		if perfErr != nil {
-			// some hypervisors don't expose perf counters
-			klog.Infof("failed to attach perf event %s: %v\n", bpfPerfArrayName, perfErr)
-			counter.enabled = false
-
-			// if any counter is not enabled, we need disable HardwareCountersEnabled
-			HardwareCountersEnabled = false
-		}
-	}
+		return openPerfEvent(t, counter.evType, counter.evConfig, offset)
+	})
 	return m, err
 }
 
-func AttachBPFAssets() (*BpfModuleTables, error) {
-	bpfModules := &BpfModuleTables{}
+func attachBCCModule() (*BpfModuleTables, error) {
+	bpfModules := &BpfModuleTables{Backend: BCCBackend}
 	program := assets.Program
 	objProg, err := assets.Asset(program)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get program %q: %v", program, err)
 	}
+	// the maps are indexed by cpu id, which can be larger than the number of online cpus
+	numCPUs := possibleCPUs()
 	options := []string{
-		"-DNUM_CPUS=" + strconv.Itoa(runtime.NumCPU()),
+		"-DNUM_CPUS=" + strconv.Itoa(numCPUs),
+		"-DNUM_HW_COUNTERS=" + strconv.Itoa(numAdditionalHWCounters()),
 	}
 	if config.EnabledEBPFCgroupID {
 		options = append(options, "-DSET_GROUP_ID")
 	}
 	// TODO: verify if ebpf can run in the VM without hardware counter support, if not, we can disable the HC part and only collect the cpu time
-	m, err := loadModule(objProg, options)
+	m, err := loadModule(objProg, options, numCPUs)
 	if err != nil {
 		klog.Infof("failed to attach perf module with options %v: %v, not able to load eBPF modules\n", options, err)
 		return nil, err
@@ -127,49 +123,58 @@ func AttachBPFAssets() (*BpfModuleTables, error) {
 
 	table := bpf.NewTable(m.TableId("processes"), m)
 	cpuFreqTable := bpf.NewTable(m.TableId("cpu_freq_array"), m)
+	cpuTimeTable := bpf.NewTable(m.TableId("pid_cpu_time"), m)
 
-	bpfModules.Module = m
-	bpfModules.Table = table
-	bpfModules.CPUFreqTable = cpuFreqTable
+	bpfModules.Module = bccModule{m}
+	bpfModules.Table = bccTable{table}
+	bpfModules.CPUFreqTable = bccTable{cpuFreqTable}
+	bpfModules.CPUTimeTable = bccTable{cpuTimeTable}
 
 	klog.Infof("Successfully load eBPF module with option: %s", options)
 
 	return bpfModules, nil
 }
 
-func DetachBPFModules(bpfModules *BpfModuleTables) {
-	closePerfEvent()
-	bpfModules.Module.Close()
-}
-
-func GetEnabledHWCounters() []string {
-	var metrics []string
-	klog.V(5).Infof("hardeware counter metrics config %t", config.ExposeHardwareCounterMetrics)
-	if !config.ExposeHardwareCounterMetrics {
-		klog.V(5).Info("hardeware counter metrics not enabled")
-		return metrics
+// attachBCCTaskEventProbes attaches the probes counting the cpu migrations and page faults.
+// The task events are optional, so that the energy metrics are still collected if the kernel does not expose them.
+func attachBCCTaskEventProbes(m *bpf.Module) {
+	migrateTask, err := m.LoadTracepoint("tracepoint__sched__sched_migrate_task")
+	if err == nil {
+		err = m.AttachTracepoint("sched:sched_migrate_task", migrateTask)
 	}
-
-	for metric, counter := range Counters {
-		if counter.enabled {
-			metrics = append(metrics, metric)
-		}
+	if err != nil {
+		klog.Infof("failed to attach sched_migrate_task, the cpu migrations are not counted: %v", err)
+	}
+	mmFault, err := m.LoadKprobe("kretprobe__handle_mm_fault")
+	if err == nil {
+		err = m.AttachKretprobe("handle_mm_fault", mmFault, -1)
+	}
+	if err != nil {
+		klog.Infof("failed to attach handle_mm_fault, the page faults are not counted: %v", err)
 	}
-	return metrics
 }
 
-func GetEnabledBPFCounters() []string {
-	var metrics []string
-	metrics = append(metrics, config.CPUTime)
-
-	klog.V(5).Infof("irq counter metrics config %t", config.ExposeIRQCounterMetrics)
-	if !config.ExposeIRQCounterMetrics {
-		klog.V(5).Info("irq counter metrics not enabled")
-		return metrics
+// attachBCCIOProbes attaches the probes counting the network and block I/O bytes of the processes.
+// A probe that cannot be attached, e.g. if the kernel function is inlined, only disables its counter.
+func attachBCCIOProbes(m *bpf.Module) {
+	for _, probe := range ioKprobes {
+		fd, err := m.LoadKprobe(probe.program)
+		if err == nil {
+			if probe.isReturn {
+				err = m.AttachKretprobe(probe.function, fd, -1)
+			} else {
+				err = m.AttachKprobe(probe.function, fd, -1)
+			}
+		}
+		if err != nil {
+			klog.Infof("failed to attach %s, the bytes are not counted: %v", probe.function, err)
+		}
+	}
+	blockRqIssue, err := m.LoadTracepoint("tracepoint__block__block_rq_issue")
+	if err == nil {
+		err = m.AttachTracepoint("block:block_rq_issue", blockRqIssue)
+	}
+	if err != nil {
+		klog.Infof("failed to attach block_rq_issue, the block I/O bytes are not counted: %v", err)
 	}
-	metrics = append(metrics, config.IRQNetTXLabel)
-	metrics = append(metrics, config.IRQNetRXLabel)
-	metrics = append(metrics, config.IRQBlockLabel)
-
-	return metrics
 }
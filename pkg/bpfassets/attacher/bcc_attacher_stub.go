@@ -23,67 +23,9 @@ import (
 	"fmt"
 )
 
-type perfCounter struct{}
+// bccAvailable is true when kepler is built with the bcc build tag
+const bccAvailable = false
 
-type ModuleStub struct{}
-
-// Table references a BPF table.
-type Table struct {
-}
-
-// TableIterator contains the current position for iteration over a *bcc.Table and provides methods for iteration.
-type TableIterator struct {
-	leaf []byte
-	key  []byte
-}
-
-// Iter returns an iterator to list all table entries available as raw bytes.
-func (table *Table) Iter() *TableIterator {
-	return &TableIterator{}
-}
-
-// Next looks up the next element and return true if one is available.
-func (it *TableIterator) Next() bool {
-	return false
-}
-
-// Key returns the current key value of the iterator, if the most recent call to Next returned true.
-// The slice is valid only until the next call to Next.
-func (it *TableIterator) Key() []byte {
-	return it.key
-}
-
-// Leaf returns the current leaf value of the iterator, if the most recent call to Next returned true.
-// The slice is valid only until the next call to Next.
-func (it *TableIterator) Leaf() []byte {
-	return it.leaf
-}
-
-func (table *Table) DeleteAll() {
-}
-
-type BpfModuleTables struct {
-	Module       ModuleStub
-	Table        *Table
-	CPUFreqTable *Table
-}
-
-var (
-	Counters                = map[string]perfCounter{}
-	HardwareCountersEnabled = true
-)
-
-func AttachBPFAssets() (*BpfModuleTables, error) {
-	return nil, fmt.Errorf("no bcc build tag")
-}
-
-func DetachBPFModules(bpfModules *BpfModuleTables) {
-}
-
-func GetEnabledHWCounters() []string {
-	return []string{}
-}
-
-func GetEnabledBPFCounters() []string {
-	return []string{}
+func attachBCCModule() (*BpfModuleTables, error) {
+	return nil, fmt.Errorf("kepler was built without the bcc build tag, use the libbpf backend instead")
 }
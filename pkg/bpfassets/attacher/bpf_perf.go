@@ -50,7 +50,8 @@ func init() {
 	byteOrder = bpf.GetHostByteOrder()
 }
 
-func openPerfEvent(table *bpf.Table, typ, config int) error {
+// openPerfEvent opens the perf event on each online cpu and stores it in the table at offset + cpu
+func openPerfEvent(table *bpf.Table, typ, config, offset int) error {
 	perfKey := fmt.Sprintf("%d:%d", typ, config)
 	if _, ok := perfEvents[perfKey]; ok {
 		return nil
@@ -76,7 +77,7 @@ func openPerfEvent(table *bpf.Table, typ, config int) error {
 		}
 		key := make([]byte, keySize)
 		leaf := make([]byte, leafSize)
-		byteOrder.PutUint32(key, uint32(i))
+		byteOrder.PutUint32(key, uint32(offset+i))
 		byteOrder.PutUint32(leaf, uint32(fd))
 		keyP := unsafe.Pointer(&key[0])
 		leafP := unsafe.Pointer(&leaf[0])
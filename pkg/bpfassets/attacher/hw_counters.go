@@ -0,0 +1,262 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attacher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// MaxHWCounters is the number of hardware counters that can be collected in addition to the default counters,
+	// it must match MAX_HW_COUNTERS in the eBPF program
+	MaxHWCounters = 8
+	// the perf events of the additional counters are stored in a single perf event array, at slot * number of cpus + cpu
+	hwCountersPerfArray = "hw_counters_hc_reader"
+	hwCountersArray     = "hw_counters"
+
+	defaultCounterSlot = -1
+	rawCounterPrefix   = "raw_"
+)
+
+var (
+	// pmuDevicesPath lists the PMUs of the kernel with their named events, e.g. cpu/events/topdown-fetch-bubbles
+	pmuDevicesPath = "/sys/bus/event_source/devices"
+
+	// hwCounterEvents are the generic hardware and cache events that can be selected by name
+	hwCounterEvents = map[string]perfCounter{
+		"branch_instructions":     {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_BRANCH_INSTRUCTIONS},
+		"branch_misses":           {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_BRANCH_MISSES},
+		"bus_cycles":              {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_BUS_CYCLES},
+		"cache_references":        {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_CACHE_REFERENCES},
+		"stalled_cycles_frontend": {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_STALLED_CYCLES_FRONTEND},
+		"stalled_cycles_backend":  {evType: unix.PERF_TYPE_HARDWARE, evConfig: unix.PERF_COUNT_HW_STALLED_CYCLES_BACKEND},
+		"l1d_read_accesses":       hwCacheCounter(unix.PERF_COUNT_HW_CACHE_L1D, unix.PERF_COUNT_HW_CACHE_RESULT_ACCESS),
+		"l1d_read_misses":         hwCacheCounter(unix.PERF_COUNT_HW_CACHE_L1D, unix.PERF_COUNT_HW_CACHE_RESULT_MISS),
+		"l1i_read_misses":         hwCacheCounter(unix.PERF_COUNT_HW_CACHE_L1I, unix.PERF_COUNT_HW_CACHE_RESULT_MISS),
+		"llc_references":          hwCacheCounter(unix.PERF_COUNT_HW_CACHE_LL, unix.PERF_COUNT_HW_CACHE_RESULT_ACCESS),
+		"llc_read_misses":         hwCacheCounter(unix.PERF_COUNT_HW_CACHE_LL, unix.PERF_COUNT_HW_CACHE_RESULT_MISS),
+		"dtlb_read_misses":        hwCacheCounter(unix.PERF_COUNT_HW_CACHE_DTLB, unix.PERF_COUNT_HW_CACHE_RESULT_MISS),
+		"itlb_read_misses":        hwCacheCounter(unix.PERF_COUNT_HW_CACHE_ITLB, unix.PERF_COUNT_HW_CACHE_RESULT_MISS),
+	}
+
+	regexInvalidMetricChars = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// hwCacheCounter returns the read event of a cache, per the perf_event_open man page the config is
+// (cache id) | (operation id << 8) | (result id << 16)
+func hwCacheCounter(cache, result int) perfCounter {
+	return perfCounter{
+		evType:   unix.PERF_TYPE_HW_CACHE,
+		evConfig: cache | unix.PERF_COUNT_HW_CACHE_OP_READ<<8 | result<<16,
+	}
+}
+
+// newCounters selects the hardware counters to collect, the default counters are collected if none is configured.
+// The counters can be the default counters, the generic events of hwCounterEvents, raw events, e.g. raw_1b0 for the
+// event 0xb0 and umask 0x01 on x86, or the named events of a PMU, e.g. cpu/topdown-fetch-bubbles.
+func newCounters(names []string) map[string]perfCounter {
+	counters := map[string]perfCounter{}
+	if len(names) == 0 {
+		for name, counter := range defaultCounters {
+			counters[name] = counter
+		}
+		return counters
+	}
+	slot := 0
+	for _, name := range names {
+		if counter, found := defaultCounters[name]; found {
+			counters[name] = counter
+			continue
+		}
+		label, counter, err := parseHWCounter(name)
+		if err != nil {
+			klog.Infof("failed to select the hardware counter %s: %v", name, err)
+			continue
+		}
+		if _, found := counters[label]; found {
+			continue
+		}
+		if slot >= MaxHWCounters {
+			klog.Infof("failed to select the hardware counter %s: at most %d counters can be added to the default counters", name, MaxHWCounters)
+			continue
+		}
+		counter.enabled = true
+		counter.slot = slot
+		slot++
+		counters[label] = counter
+	}
+	return counters
+}
+
+// parseHWCounter returns the metric label and the perf event of a configured hardware counter
+func parseHWCounter(name string) (string, perfCounter, error) {
+	if counter, found := hwCounterEvents[name]; found {
+		return name, counter, nil
+	}
+	if strings.HasPrefix(name, rawCounterPrefix) {
+		rawConfig := strings.TrimPrefix(strings.TrimPrefix(name, rawCounterPrefix), "0x")
+		evConfig, err := strconv.ParseUint(rawConfig, 16, 63)
+		if err != nil {
+			return "", perfCounter{}, fmt.Errorf("invalid raw event: %v", err)
+		}
+		return metricLabel(name), perfCounter{evType: unix.PERF_TYPE_RAW, evConfig: int(evConfig)}, nil
+	}
+	if pmu, event, found := strings.Cut(strings.Trim(name, "/"), "/"); found {
+		counter, err := readPMUEvent(pmuDevicesPath, pmu, event)
+		if err != nil {
+			return "", perfCounter{}, err
+		}
+		return metricLabel(pmu + "_" + event), counter, nil
+	}
+	return "", perfCounter{}, fmt.Errorf("unknown hardware counter")
+}
+
+// readPMUEvent resolves a named event of a PMU, e.g. event=0x3c,umask=0x01, with the config bits of its terms
+func readPMUEvent(devicesPath, pmu, event string) (perfCounter, error) {
+	pmuPath := filepath.Join(devicesPath, pmu)
+	data, err := os.ReadFile(filepath.Join(pmuPath, "type"))
+	if err != nil {
+		return perfCounter{}, fmt.Errorf("unknown PMU %s: %v", pmu, err)
+	}
+	evType, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return perfCounter{}, fmt.Errorf("invalid type of the PMU %s: %v", pmu, err)
+	}
+	data, err = os.ReadFile(filepath.Join(pmuPath, "events", event))
+	if err != nil {
+		return perfCounter{}, fmt.Errorf("unknown event %s of the PMU %s: %v", event, pmu, err)
+	}
+	var evConfig uint64
+	for _, term := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(term), "=")
+		termValue := uint64(1)
+		if hasValue {
+			if termValue, err = strconv.ParseUint(value, 0, 64); err != nil {
+				return perfCounter{}, fmt.Errorf("unsupported term %s of the event %s: %v", term, event, err)
+			}
+		}
+		format, err := os.ReadFile(filepath.Join(pmuPath, "format", key))
+		if err != nil {
+			return perfCounter{}, fmt.Errorf("unknown term %s of the event %s: %v", key, event, err)
+		}
+		bits, err := termConfigBits(strings.TrimSpace(string(format)))
+		if err != nil {
+			return perfCounter{}, fmt.Errorf("unsupported term %s of the event %s: %v", key, event, err)
+		}
+		// the value is spread over the bits of the format in order, e.g. config:0-7,32-35
+		for _, bit := range bits {
+			evConfig |= (termValue & 1) << bit
+			termValue >>= 1
+		}
+	}
+	return perfCounter{evType: evType, evConfig: int(evConfig)}, nil
+}
+
+// termConfigBits returns the bits of the event config set by a PMU format, e.g. config:0-7,21
+func termConfigBits(format string) ([]int, error) {
+	field, ranges, found := strings.Cut(format, ":")
+	if !found || field != "config" {
+		return nil, fmt.Errorf("only the config field is supported, got %q", format)
+	}
+	bits := []int{}
+	for _, bitRange := range strings.Split(ranges, ",") {
+		first, last, isRange := strings.Cut(bitRange, "-")
+		if !isRange {
+			last = first
+		}
+		low, lowErr := strconv.Atoi(first)
+		high, highErr := strconv.Atoi(last)
+		if lowErr != nil || highErr != nil || low > high || high > 63 {
+			return nil, fmt.Errorf("invalid bits %q", bitRange)
+		}
+		for bit := low; bit <= high; bit++ {
+			bits = append(bits, bit)
+		}
+	}
+	return bits, nil
+}
+
+// metricLabel converts a counter name to a metric label, e.g. cpu/topdown-fetch-bubbles to cpu_topdown_fetch_bubbles
+func metricLabel(name string) string {
+	return strings.Trim(regexInvalidMetricChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// isCPUFrequencyCounted returns true if the counters used to compute the cpu frequency are collected
+func isCPUFrequencyCounted(counters map[string]perfCounter) bool {
+	cycles, cyclesFound := counters[CPUCycleLabel]
+	refCycles, refCyclesFound := counters[CPURefCycleLabel]
+	return cyclesFound && refCyclesFound && cycles.enabled && refCycles.enabled
+}
+
+// GetHWCounterSlot returns the index of a hardware counter in the hw_counters array of the eBPF program,
+// false is returned for the default counters, which have their own field
+func GetHWCounterSlot(label string) (int, bool) {
+	counter, found := Counters[label]
+	if !found || counter.slot == defaultCounterSlot {
+		return 0, false
+	}
+	return counter.slot, true
+}
+
+// GetAdditionalHWCounters returns the selected hardware counters which are not default counters in the order of their slot
+func GetAdditionalHWCounters() []string {
+	labels := []string{}
+	for label, counter := range Counters {
+		if counter.slot != defaultCounterSlot {
+			labels = append(labels, label)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return Counters[labels[i]].slot < Counters[labels[j]].slot
+	})
+	return labels
+}
+
+// openHWCounters opens the perf events of the selected hardware counters with the perf event opener of a backend
+func openHWCounters(numCPUs int, openPerfEvent func(perfArrayName string, offset int, counter perfCounter) error) {
+	for label, counter := range Counters {
+		perfArrayName, offset := label+bpfPerfArrayPrefix, 0
+		if counter.slot != defaultCounterSlot {
+			perfArrayName, offset = hwCountersPerfArray, counter.slot*numCPUs
+		}
+		if err := openPerfEvent(perfArrayName, offset, counter); err != nil {
+			// some hypervisors don't expose perf counters
+			klog.Infof("failed to attach perf event %s: %v\n", label, err)
+			counter.enabled = false
+			Counters[label] = counter
+
+			// if any default counter is not enabled, we need disable HardwareCountersEnabled
+			if counter.slot == defaultCounterSlot {
+				HardwareCountersEnabled = false
+			}
+		}
+	}
+}
+
+// numAdditionalHWCounters returns the number of slots used in the hw_counters array
+func numAdditionalHWCounters() int {
+	return len(GetAdditionalHWCounters())
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attacher
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+)
+
+func writePMUFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewCounters(t *testing.T) {
+	g := NewWithT(t)
+
+	counters := newCounters(nil)
+	g.Expect(counters).To(HaveLen(len(defaultCounters)))
+	g.Expect(isCPUFrequencyCounted(counters)).To(BeTrue())
+
+	counters = newCounters([]string{CPUInstructionLabel, "llc_references", "raw_0x1b0", "unknown", "llc_references"})
+	g.Expect(counters).To(HaveLen(3))
+	g.Expect(counters[CPUInstructionLabel].slot).To(Equal(defaultCounterSlot))
+	g.Expect(counters["llc_references"].slot).To(Equal(0))
+	g.Expect(counters["llc_references"].evType).To(Equal(unix.PERF_TYPE_HW_CACHE))
+	g.Expect(counters["llc_references"].evConfig).To(Equal(unix.PERF_COUNT_HW_CACHE_LL))
+	g.Expect(counters["raw_0x1b0"]).To(Equal(perfCounter{evType: unix.PERF_TYPE_RAW, evConfig: 0x1b0, enabled: true, slot: 1}))
+	// the cpu frequency is computed from the cycles
+	g.Expect(isCPUFrequencyCounted(counters)).To(BeFalse())
+
+	names := []string{}
+	for i := 0; i <= MaxHWCounters; i++ {
+		names = append(names, "raw_"+strconv.Itoa(i))
+	}
+	g.Expect(newCounters(names)).To(HaveLen(MaxHWCounters))
+}
+
+func TestAdditionalHWCounters(t *testing.T) {
+	g := NewWithT(t)
+	counters := Counters
+	defer func() { Counters = counters }()
+
+	Counters = newCounters([]string{CPUCycleLabel, "branch_misses", "l1d_read_misses"})
+	g.Expect(GetAdditionalHWCounters()).To(Equal([]string{"branch_misses", "l1d_read_misses"}))
+	slot, found := GetHWCounterSlot("l1d_read_misses")
+	g.Expect(found).To(BeTrue())
+	g.Expect(slot).To(Equal(1))
+	_, found = GetHWCounterSlot(CPUCycleLabel)
+	g.Expect(found).To(BeFalse())
+}
+
+func TestReadPMUEvent(t *testing.T) {
+	g := NewWithT(t)
+	devicesPath := t.TempDir()
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "type"), "4\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "format", "event"), "config:0-7\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "format", "umask"), "config:8-15\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "format", "edge"), "config:18\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "format", "ldlat"), "config1:0-15\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "events", "topdown-fetch-bubbles"), "event=0x9c,umask=0x01\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "events", "edge-event"), "event=0x3c,edge\n")
+	writePMUFile(t, filepath.Join(devicesPath, "cpu", "events", "mem-loads"), "event=0xcd,umask=0x1,ldlat=3\n")
+
+	counter, err := readPMUEvent(devicesPath, "cpu", "topdown-fetch-bubbles")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(counter).To(Equal(perfCounter{evType: 4, evConfig: 0x019c}))
+	counter, err = readPMUEvent(devicesPath, "cpu", "edge-event")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(counter.evConfig).To(Equal(1<<18 | 0x3c))
+	_, err = readPMUEvent(devicesPath, "cpu", "mem-loads")
+	g.Expect(err).To(MatchError(ContainSubstring("only the config field is supported")))
+	_, err = readPMUEvent(devicesPath, "cpu", "unknown")
+	g.Expect(err).To(HaveOccurred())
+	_, err = readPMUEvent(devicesPath, "uncore", "unknown")
+	g.Expect(err).To(HaveOccurred())
+
+	devices := pmuDevicesPath
+	defer func() { pmuDevicesPath = devices }()
+	pmuDevicesPath = devicesPath
+	label, counter, err := parseHWCounter("cpu/topdown-fetch-bubbles/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(label).To(Equal("cpu_topdown_fetch_bubbles"))
+	g.Expect(counter.evConfig).To(Equal(0x019c))
+}
+
+func TestTermConfigBits(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(termConfigBits("config:0-3,32-33")).To(Equal([]int{0, 1, 2, 3, 32, 33}))
+	g.Expect(termConfigBits("config:21")).To(Equal([]int{21}))
+	_, err := termConfigBits("config:7-0")
+	g.Expect(err).To(HaveOccurred())
+	_, err = termConfigBits("config2:0-7")
+	g.Expect(err).To(HaveOccurred())
+}
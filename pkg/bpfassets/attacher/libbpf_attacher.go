@@ -0,0 +1,321 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attacher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+
+	assets "github.com/sustainable-computing-io/kepler/pkg/bpfassets"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	kernelBTFPath = "/sys/kernel/btf/vmlinux"
+	onlineCPUPath = "/sys/devices/system/cpu/online"
+	// the CPU ids that can be brought online, which can be sparse or larger than the number of online CPUs
+	possibleCPUPath = "/sys/devices/system/cpu/possible"
+)
+
+var (
+	// the maps indexed by CPU, which are resized to the number of CPUs before loading the program
+	libbpfPerCPUMaps = []string{"cpu_cycles", "cpu_ref_cycles", "cpu_instr", "cache_miss", "cpu_freq_array"}
+)
+
+// libbpfModule holds the eBPF objects, the attached probes and the perf events of the libbpf backend
+type libbpfModule struct {
+	collection *ebpf.Collection
+	links      []link.Link
+	perfEvents []int
+}
+
+func (m *libbpfModule) Close() {
+	for _, l := range m.links {
+		if err := l.Close(); err != nil {
+			klog.V(5).Infof("failed to detach the eBPF program: %v", err)
+		}
+	}
+	for _, fd := range m.perfEvents {
+		unix.Close(fd)
+	}
+	m.collection.Close()
+}
+
+// libbpfTable reads an eBPF map with cilium/ebpf
+type libbpfTable struct {
+	m *ebpf.Map
+}
+
+func (t *libbpfTable) Iter() BpfTableIterator {
+	return &libbpfTableIterator{it: t.m.Iterate()}
+}
+
+// DeleteAll deletes all the entries of the map, the keys are listed first since deleting while iterating a hash map is not safe
+func (t *libbpfTable) DeleteAll() error {
+	var keys [][]byte
+	for it := t.Iter(); it.Next(); {
+		keys = append(keys, append([]byte{}, it.Key()...))
+	}
+	for _, key := range keys {
+		if err := t.m.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete the entry of map %s: %v", t.m, err)
+		}
+	}
+	return nil
+}
+
+type libbpfTableIterator struct {
+	it   *ebpf.MapIterator
+	key  []byte
+	leaf []byte
+}
+
+func (it *libbpfTableIterator) Next() bool {
+	if it.it.Next(&it.key, &it.leaf) {
+		return true
+	}
+	if err := it.it.Err(); err != nil {
+		klog.V(5).Infof("failed to iterate the eBPF map: %v", err)
+	}
+	return false
+}
+
+func (it *libbpfTableIterator) Key() []byte {
+	return it.key
+}
+
+func (it *libbpfTableIterator) Leaf() []byte {
+	return it.leaf
+}
+
+func attachLibbpfModule() (*BpfModuleTables, error) {
+	objProg, err := assets.LibbpfObject()
+	if err != nil {
+		return nil, err
+	}
+	// CO-RE relocates the program with the kernel type information instead of the kernel headers
+	if _, err := os.Stat(kernelBTFPath); err != nil {
+		return nil, fmt.Errorf("the kernel does not expose its BTF in %s, which is required by the libbpf backend: %v", kernelBTFPath, err)
+	}
+	// kernels older than 5.11 account the eBPF maps in the locked memory
+	if err := rlimit.RemoveMemlock(); err != nil {
+		klog.V(5).Infof("failed to remove the memlock limit: %v", err)
+	}
+
+	spec, err := ebpf.LoadCollectionSpecFromReader(bytes.NewReader(objProg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the CO-RE eBPF object: %v", err)
+	}
+	var setGroupID uint32
+	if config.EnabledEBPFCgroupID {
+		setGroupID = 1
+	}
+	numCPUs := uint32(possibleCPUs())
+	constants := map[string]interface{}{
+		"set_group_id":    setGroupID,
+		"num_cpus":        numCPUs,
+		"num_hw_counters": uint32(numAdditionalHWCounters()),
+	}
+	if err := spec.RewriteConstants(constants); err != nil {
+		return nil, fmt.Errorf("failed to set the eBPF program options: %v", err)
+	}
+	perCPUMaps := append([]string{}, libbpfPerCPUMaps...)
+	for name := range defaultCounters {
+		perCPUMaps = append(perCPUMaps, name+bpfPerfArrayPrefix)
+	}
+	for _, name := range perCPUMaps {
+		if mapSpec, found := spec.Maps[name]; found {
+			mapSpec.MaxEntries = numCPUs
+		}
+	}
+	// the additional hardware counters are stored per slot and cpu
+	for _, name := range []string{hwCountersPerfArray, hwCountersArray} {
+		if mapSpec, found := spec.Maps[name]; found {
+			mapSpec.MaxEntries = numCPUs * MaxHWCounters
+		}
+	}
+	collection, err := ebpf.NewCollection(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the CO-RE eBPF program: %v", err)
+	}
+	m := &libbpfModule{collection: collection}
+
+	kprobe, err := link.Kprobe("finish_task_switch", collection.Programs["kprobe__finish_task_switch"], nil)
+	if err != nil {
+		kprobe, err = link.Kprobe("finish_task_switch.isra.0", collection.Programs["kprobe__finish_task_switch"], nil)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("failed to attach finish_task_switch: %v", err)
+		}
+	}
+	m.links = append(m.links, kprobe)
+	softirqEntry, err := link.Tracepoint("irq", "softirq_entry", collection.Programs["tracepoint__irq__softirq_entry"], nil)
+	if err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to attach softirq_entry: %v", err)
+	}
+	m.links = append(m.links, softirqEntry)
+	if config.ExposeTaskEventMetrics {
+		m.attachTaskEventProbes()
+	}
+	if config.ExposeBPFIOMetrics {
+		m.attachIOProbes()
+	}
+
+	openHWCounters(int(numCPUs), func(perfArrayName string, offset int, counter perfCounter) error {
+		return m.openPerfEvent(collection.Maps[perfArrayName], counter.evType, counter.evConfig, offset)
+	})
+
+	klog.Infof("Successfully load the CO-RE eBPF module with libbpf")
+	return &BpfModuleTables{
+		Backend:      LibbpfBackend,
+		Module:       m,
+		Table:        &libbpfTable{collection.Maps["processes"]},
+		CPUFreqTable: &libbpfTable{collection.Maps["cpu_freq_array"]},
+		CPUTimeTable: &libbpfTable{collection.Maps["pid_cpu_time"]},
+	}, nil
+}
+
+// openPerfEvent opens the hardware counter on each online CPU and stores its file descriptor in the perf event array at offset + cpu
+func (m *libbpfModule) openPerfEvent(perfArray *ebpf.Map, typ, config, offset int) error {
+	if perfArray == nil {
+		return fmt.Errorf("failed to find the perf event array")
+	}
+	data, err := os.ReadFile(onlineCPUPath)
+	if err != nil {
+		return fmt.Errorf("failed to determine online cpus: %v", err)
+	}
+	cpus, err := parseCPUList(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to determine online cpus: %v", err)
+	}
+	attr := &unix.PerfEventAttr{
+		Type:   uint32(typ),
+		Config: uint64(config),
+		Size:   uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+	}
+	for _, cpu := range cpus {
+		fd, err := unix.PerfEventOpen(attr, -1, cpu, -1, unix.PERF_FLAG_FD_CLOEXEC)
+		if err != nil {
+			return fmt.Errorf("failed to open bpf perf event: %v", err)
+		}
+		m.perfEvents = append(m.perfEvents, fd)
+		if err := perfArray.Update(uint32(offset+cpu), uint32(fd), ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("failed to set the perf event of cpu %d: %v", cpu, err)
+		}
+	}
+	return nil
+}
+
+// parseCPUList parses a CPU list of the sysfs, e.g. 0-3,8
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, cpuRange := range strings.Split(strings.TrimSpace(list), ",") {
+		if cpuRange == "" {
+			continue
+		}
+		bounds := strings.SplitN(cpuRange, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q: %v", list, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu list %q", list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// possibleCPUs returns the number of entries of the maps indexed by CPU id, i.e. the highest possible CPU id + 1.
+// It falls back to the number of CPUs usable by kepler if the possible CPUs cannot be read.
+func possibleCPUs() int {
+	data, err := os.ReadFile(possibleCPUPath)
+	if err == nil {
+		var count int
+		if count, err = countCPUs(string(data)); err == nil {
+			return count
+		}
+	}
+	klog.V(5).Infof("failed to determine the possible cpus, using the number of cpus: %v", err)
+	return runtime.NumCPU()
+}
+
+// countCPUs returns the highest CPU id of a CPU list + 1
+func countCPUs(list string) (int, error) {
+	cpus, err := parseCPUList(list)
+	if err != nil {
+		return 0, err
+	}
+	if len(cpus) == 0 {
+		return 0, fmt.Errorf("empty cpu list")
+	}
+	return cpus[len(cpus)-1] + 1, nil
+}
+
+// attachTaskEventProbes attaches the probes counting the cpu migrations and page faults.
+// The task events are optional, so that the energy metrics are still collected if the kernel does not expose them.
+func (m *libbpfModule) attachTaskEventProbes() {
+	if migrateTask, err := link.Tracepoint("sched", "sched_migrate_task", m.collection.Programs["tracepoint__sched__sched_migrate_task"], nil); err != nil {
+		klog.Infof("failed to attach sched_migrate_task, the cpu migrations are not counted: %v", err)
+	} else {
+		m.links = append(m.links, migrateTask)
+	}
+	if mmFault, err := link.Kretprobe("handle_mm_fault", m.collection.Programs["kretprobe__handle_mm_fault"], nil); err != nil {
+		klog.Infof("failed to attach handle_mm_fault, the page faults are not counted: %v", err)
+	} else {
+		m.links = append(m.links, mmFault)
+	}
+}
+
+// attachIOProbes attaches the probes counting the network and block I/O bytes of the processes.
+// A probe that cannot be attached, e.g. if the kernel function is inlined, only disables its counter.
+func (m *libbpfModule) attachIOProbes() {
+	for _, probe := range ioKprobes {
+		attach := link.Kprobe
+		if probe.isReturn {
+			attach = link.Kretprobe
+		}
+		if l, err := attach(probe.function, m.collection.Programs[probe.program], nil); err != nil {
+			klog.Infof("failed to attach %s, the bytes are not counted: %v", probe.function, err)
+		} else {
+			m.links = append(m.links, l)
+		}
+	}
+	if blockRqIssue, err := link.Tracepoint("block", "block_rq_issue", m.collection.Programs["tracepoint__block__block_rq_issue"], nil); err != nil {
+		klog.Infof("failed to attach block_rq_issue, the block I/O bytes are not counted: %v", err)
+	} else {
+		m.links = append(m.links, blockRqIssue)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package attacher
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	assets "github.com/sustainable-computing-io/kepler/pkg/bpfassets"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+func TestParseCPUList(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(parseCPUList("0\n")).To(Equal([]int{0}))
+	g.Expect(parseCPUList("0-3,8,10-11\n")).To(Equal([]int{0, 1, 2, 3, 8, 10, 11}))
+	_, err := parseCPUList("0-a")
+	g.Expect(err).To(HaveOccurred())
+	_, err = parseCPUList("3-1")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCountCPUs(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(countCPUs("0-3\n")).To(Equal(4))
+	// the maps must be indexed by the highest cpu id, not by the number of cpus
+	g.Expect(countCPUs("0-1,6-7\n")).To(Equal(8))
+	_, err := countCPUs("\n")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestBackendSelection(t *testing.T) {
+	g := NewWithT(t)
+	backend := config.BPFBackend
+	defer func() { config.BPFBackend = backend }()
+
+	config.BPFBackend = "unknown"
+	_, err := AttachBPFAssets()
+	g.Expect(err).To(MatchError(ContainSubstring("unknown BPF_BACKEND")))
+	g.Expect(GetEnabledBPFCounters()).To(BeEmpty())
+
+	config.BPFBackend = LibbpfBackend
+	if !assets.HasLibbpfObject() {
+		// the error explains how to embed the CO-RE program
+		_, err = AttachBPFAssets()
+		g.Expect(err).To(MatchError(ContainSubstring("make genlibbpfobjects")))
+		g.Expect(GetEnabledBPFCounters()).To(BeEmpty())
+	}
+
+	config.BPFBackend = BCCBackend
+	if !bccAvailable {
+		_, err = AttachBPFAssets()
+		g.Expect(err).To(MatchError(ContainSubstring("bcc build tag")))
+		g.Expect(GetEnabledHWCounters()).To(BeEmpty())
+	}
+}
@@ -19,3 +19,11 @@ var _ = Describe("BPF asset generation", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 })
+
+var _ = Describe("CO-RE eBPF objects", func() {
+	It("should explain how to build the missing objects", func() {
+		_, err := libbpfObject("unknown")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("make genlibbpfobjects"))
+	})
+})
@@ -0,0 +1,49 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bpfassets
+
+import (
+	"embed"
+	"fmt"
+	"runtime"
+)
+
+// libbpfObjects holds the CO-RE objects compiled from bpfassets/libbpf/src by hack/build-libbpf-objects.sh,
+// the directory only contains a placeholder when the objects were not built
+//
+//go:embed all:libbpf
+var libbpfObjects embed.FS
+
+// LibbpfObject returns the pre-compiled CO-RE eBPF object of the current architecture
+func LibbpfObject() ([]byte, error) {
+	return libbpfObject(runtime.GOARCH)
+}
+
+// HasLibbpfObject returns true if the CO-RE eBPF object of the current architecture was embedded at build time
+func HasLibbpfObject() bool {
+	_, err := LibbpfObject()
+	return err == nil
+}
+
+func libbpfObject(arch string) ([]byte, error) {
+	name := fmt.Sprintf("libbpf/kepler.%s.o", arch)
+	obj, err := libbpfObjects.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("the CO-RE eBPF object %s was not embedded in this build, run make genlibbpfobjects before building kepler: %v", name, err)
+	}
+	return obj, nil
+}
@@ -73,6 +73,8 @@ limitations under the License.
 */
 
 #include <linux/sched.h>
+#include <linux/version.h>
+#include <net/sock.h>
 // #include <linux/bpf.h>
 // #include <linux/bpf_perf_event.h>
 
@@ -88,6 +90,20 @@ limitations under the License.
 #define HZ 1000
 #endif
 
+// number of the hardware counters selected in addition to the default counters, set by the loader
+#ifndef NUM_HW_COUNTERS
+#define NUM_HW_COUNTERS 0
+#endif
+
+// must match MaxHWCounters in the attacher
+#define MAX_HW_COUNTERS 8
+
+// vm_fault_reason flags of the handle_mm_fault return value
+#define KEPLER_VM_FAULT_MAJOR 0x0004
+#define KEPLER_VM_FAULT_RETRY 0x0400
+// VM_FAULT_OOM | VM_FAULT_SIGBUS | VM_FAULT_HWPOISON | VM_FAULT_HWPOISON_LARGE | VM_FAULT_SIGSEGV | VM_FAULT_FALLBACK
+#define KEPLER_VM_FAULT_ERROR 0x0873
+
 typedef struct process_metrics_t
 {
     u64 cgroup_id;
@@ -96,6 +112,16 @@ typedef struct process_metrics_t
     u64 cpu_cycles;
     u64 cpu_instr;
     u64 cache_miss;
+    u64 voluntary_ctx_switches;
+    u64 involuntary_ctx_switches;
+    u64 cpu_migrations;
+    u64 page_faults_major;
+    u64 page_faults_minor;
+    u64 net_tx_bytes;
+    u64 net_rx_bytes;
+    u64 block_read_bytes;
+    u64 block_write_bytes;
+    u64 hw_counters[MAX_HW_COUNTERS]; // selected hardware counters, indexed by their slot
     u16 vec_nr[10]; // irq counter, 10 is the max number of irq vectors
     char comm[16];
 } process_metrics_t;
@@ -109,6 +135,8 @@ typedef struct pid_time_t
 // processes and pid time
 BPF_HASH(processes, u64, process_metrics_t);
 BPF_HASH(pid_time, pid_time_t);
+// on cpu time of each process per cpu
+BPF_HASH(pid_cpu_time, pid_time_t);
 
 // perf counters
 BPF_PERF_ARRAY(cpu_cycles_hc_reader, NUM_CPUS);
@@ -123,6 +151,10 @@ BPF_ARRAY(cpu_instr, u64, NUM_CPUS);
 BPF_PERF_ARRAY(cache_miss_hc_reader, NUM_CPUS);
 BPF_ARRAY(cache_miss, u64, NUM_CPUS);
 
+// selected hardware counters, the perf event of a counter is stored at slot * NUM_CPUS + cpu
+BPF_PERF_ARRAY(hw_counters_hc_reader, NUM_CPUS * MAX_HW_COUNTERS);
+BPF_ARRAY(hw_counters, u64, NUM_CPUS * MAX_HW_COUNTERS);
+
 // cpu freq counters
 BPF_ARRAY(cpu_freq_array, u32, NUM_CPUS);
 
@@ -142,6 +174,13 @@ static inline u64 get_on_cpu_time(u32 cur_pid, u32 prev_pid, u32 cpu_id, u64 cur
         {
             cpu_time = (cur_ts - *prev_ts) / 1000000; /*milisecond*/
             pid_time.delete(&prev_pid_key);
+            if (cpu_time > 0)
+            {
+                u64 zero = 0;
+                u64 *prev_cpu_time = pid_cpu_time.lookup_or_try_init(&prev_pid_key, &zero);
+                if (prev_cpu_time != 0)
+                    *prev_cpu_time += cpu_time;
+            }
         }
     }
     pid_time_t new_pid_key = {.pid = cur_pid, .cpu = cpu_id};
@@ -229,6 +268,21 @@ static inline u64 get_on_cpu_cache_miss(u32 *cpu_id)
     return delta;
 }
 
+static inline u64 get_on_cpu_hw_counter(u32 index)
+{
+    u64 delta = 0;
+    struct bpf_perf_event_value c = {};
+    int error = hw_counters_hc_reader.perf_counter_value(index, &c, sizeof(struct bpf_perf_event_value));
+    if (error == 0)
+    {
+        u64 val = normalize(&c.counter, &c.enabled, &c.running);
+        u64 *prev_val = hw_counters.lookup(&index);
+        delta = calc_delta(prev_val, &val);
+        hw_counters.update(&index, &val);
+    }
+    return delta;
+}
+
 // calculate the average cpu freq
 static inline u64 get_on_cpu_avg_freq(u32 *cpu_id, u64 on_cpu_cycles_delta, u64 on_cpu_ref_cycles_delta)
 {
@@ -246,6 +300,15 @@ static inline u64 get_on_cpu_avg_freq(u32 *cpu_id, u64 on_cpu_cycles_delta, u64
     return avg_freq;
 }
 
+static inline long get_task_state(struct task_struct *task)
+{
+#if LINUX_VERSION_CODE >= KERNEL_VERSION(5, 14, 0)
+    return task->__state;
+#else
+    return task->state;
+#endif
+}
+
 // int kprobe__finish_task_switch(switch_args *ctx)
 int kprobe__finish_task_switch(struct pt_regs *ctx, struct task_struct *prev)
 {
@@ -265,6 +328,10 @@ int kprobe__finish_task_switch(struct pt_regs *ctx, struct task_struct *prev)
     u64 on_cpu_instr_delta = get_on_cpu_instr(&cpu_id);
     u64 on_cpu_cache_miss_delta = get_on_cpu_cache_miss(&cpu_id);
     u64 on_cpu_avg_freq = get_on_cpu_avg_freq(&cpu_id, on_cpu_cycles_delta, on_cpu_ref_cycles_delta);
+    u64 on_cpu_hw_counters_delta[MAX_HW_COUNTERS] = {};
+#pragma unroll
+    for (u32 i = 0; i < NUM_HW_COUNTERS && i < MAX_HW_COUNTERS; i++)
+        on_cpu_hw_counters_delta[i] = get_on_cpu_hw_counter(i * NUM_CPUS + cpu_id);
 
     // store process metrics
     struct process_metrics_t *process_metrics;
@@ -277,6 +344,15 @@ int kprobe__finish_task_switch(struct pt_regs *ctx, struct task_struct *prev)
         process_metrics->cpu_cycles += on_cpu_cycles_delta;
         process_metrics->cpu_instr += on_cpu_instr_delta;
         process_metrics->cache_miss += on_cpu_cache_miss_delta;
+#pragma unroll
+        for (u32 i = 0; i < NUM_HW_COUNTERS && i < MAX_HW_COUNTERS; i++)
+            process_metrics->hw_counters[i] += on_cpu_hw_counters_delta[i];
+
+        // the previous task was preempted if it is still runnable, otherwise it gave up the cpu, e.g. to wait for I/O
+        if (get_task_state(prev) == TASK_RUNNING)
+            process_metrics->involuntary_ctx_switches++;
+        else
+            process_metrics->voluntary_ctx_switches++;
     }
 
     process_metrics = processes.lookup(&cur_pid);
@@ -306,6 +382,109 @@ TRACEPOINT_PROBE(irq, softirq_entry)
     }
     return 0;
 }
+
+// per https://www.kernel.org/doc/html/latest/core-api/tracepoint.html#c.trace_sched_migrate_task
+TRACEPOINT_PROBE(sched, sched_migrate_task)
+{
+    u64 pid = args->pid;
+    struct process_metrics_t *process_metrics;
+    process_metrics = processes.lookup(&pid);
+    if (process_metrics != 0)
+    {
+        process_metrics->cpu_migrations++;
+    }
+    return 0;
+}
+
+// the page faults of the user space are handled by handle_mm_fault, which reports whether the fault required I/O
+int kretprobe__handle_mm_fault(struct pt_regs *ctx)
+{
+    u64 cur_pid = bpf_get_current_pid_tgid() >> 32;
+    u32 ret = PT_REGS_RC(ctx);
+    // the retried faults are handled again, so they are only counted once
+    if (ret & (KEPLER_VM_FAULT_ERROR | KEPLER_VM_FAULT_RETRY))
+        return 0;
+    struct process_metrics_t *process_metrics;
+    process_metrics = processes.lookup(&cur_pid);
+    if (process_metrics != 0)
+    {
+        if (ret & KEPLER_VM_FAULT_MAJOR)
+            process_metrics->page_faults_major++;
+        else
+            process_metrics->page_faults_minor++;
+    }
+    return 0;
+}
+
+// the network and block I/O bytes are attributed to the process of the current task
+static inline struct process_metrics_t *get_current_process_metrics()
+{
+    u64 cur_pid = bpf_get_current_pid_tgid() >> 32;
+    return processes.lookup(&cur_pid);
+}
+
+// the send functions return the number of bytes sent or a negative error
+int kretprobe__tcp_sendmsg(struct pt_regs *ctx)
+{
+    int ret = PT_REGS_RC(ctx);
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && ret > 0)
+        process_metrics->net_tx_bytes += ret;
+    return 0;
+}
+
+int kretprobe__udp_sendmsg(struct pt_regs *ctx)
+{
+    int ret = PT_REGS_RC(ctx);
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && ret > 0)
+        process_metrics->net_tx_bytes += ret;
+    return 0;
+}
+
+int kretprobe__udpv6_sendmsg(struct pt_regs *ctx)
+{
+    int ret = PT_REGS_RC(ctx);
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && ret > 0)
+        process_metrics->net_tx_bytes += ret;
+    return 0;
+}
+
+// tcp_cleanup_rbuf is called with the number of bytes copied to the user space by tcp_recvmsg
+int kprobe__tcp_cleanup_rbuf(struct pt_regs *ctx, struct sock *sk, int copied)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && copied > 0)
+        process_metrics->net_rx_bytes += copied;
+    return 0;
+}
+
+// skb_consume_udp is called with the number of bytes copied to the user space by udp_recvmsg and udpv6_recvmsg
+int kprobe__skb_consume_udp(struct pt_regs *ctx, struct sock *sk, struct sk_buff *skb, int len)
+{
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics != 0 && len > 0)
+        process_metrics->net_rx_bytes += len;
+    return 0;
+}
+
+// per https://www.kernel.org/doc/html/latest/core-api/tracepoint.html#c.trace_block_rq_issue
+// the request is attributed to the task issuing it, the writeback of the page cache is done by the kernel flusher threads
+TRACEPOINT_PROBE(block, block_rq_issue)
+{
+    u64 bytes = args->bytes;
+    // the flush flag precedes the operation, e.g. FWS for a write with preflush
+    char op = args->rwbs[0] == 'F' ? args->rwbs[1] : args->rwbs[0];
+    struct process_metrics_t *process_metrics = get_current_process_metrics();
+    if (process_metrics == 0)
+        return 0;
+    if (op == 'R')
+        process_metrics->block_read_bytes += bytes;
+    else if (op == 'W')
+        process_metrics->block_write_bytes += bytes;
+    return 0;
+}
 `)
 
 func bpfassetsPerf_eventPerf_eventCBytes() ([]byte, error) {
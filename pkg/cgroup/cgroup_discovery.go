@@ -0,0 +1,64 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// the container cgroups are named <runtime>-<id>.scope with the systemd cgroup driver, e.g. cri-containerd-<id>.scope,
+	// and <id> in the pod cgroup with the cgroupfs driver
+	regexContainerScope = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+)
+
+// DiscoverContainerIDs walks the container cgroups below the top path of the slice handler and returns the container IDs.
+// It is used to find the containers when the BPF programs cannot be attached.
+func DiscoverContainerIDs() (map[string]bool, error) {
+	return discoverContainerIDs(SliceHandlerInstance.GetCPUTopPath())
+}
+
+func discoverContainerIDs(topPath string) (map[string]bool, error) {
+	containerIDs := map[string]bool{}
+	err := filepath.WalkDir(topPath, func(path string, dentry fs.DirEntry, err error) error {
+		if err != nil {
+			// the cgroup of a container might be removed while walking
+			if path != topPath {
+				return nil
+			}
+			return err
+		}
+		if !dentry.IsDir() {
+			return nil
+		}
+		name := dentry.Name()
+		// the conmon process of CRI-O runs in its own scope next to the container
+		if strings.Contains(name, "conmon") {
+			return filepath.SkipDir
+		}
+		if match := regexContainerScope.FindStringSubmatch(name); match != nil {
+			containerIDs[match[1]] = true
+			// the container cgroup can have sub cgroups created by the container itself
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return containerIDs, err
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiscoverContainerIDs(t *testing.T) {
+	g := NewWithT(t)
+
+	id1 := strings.Repeat("a", 64)
+	id2 := strings.Repeat("b", 64)
+	id3 := strings.Repeat("c", 64)
+	dir := t.TempDir()
+	for _, path := range []string{
+		// systemd cgroup driver
+		"kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1.slice/cri-containerd-" + id1 + ".scope",
+		"kubepods.slice/kubepods-pod2.slice/crio-" + id2 + ".scope/container",
+		"kubepods.slice/kubepods-pod2.slice/crio-conmon-" + id2 + ".scope",
+		// cgroupfs driver
+		"kubepods/burstable/pod3/" + id3,
+		"system.slice/sshd.service",
+	} {
+		g.Expect(os.MkdirAll(filepath.Join(dir, path), 0o755)).To(Succeed())
+	}
+
+	ids, err := discoverContainerIDs(dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ids).To(Equal(map[string]bool{id1: true, id2: true, id3: true}))
+
+	_, err = discoverContainerIDs(filepath.Join(dir, "missing"))
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	runtimeTimeout = 2 * time.Second
+	// runtimeCacheTTL is how long a container found in a container runtime is considered alive without asking the runtime again
+	runtimeCacheTTL = 30 * time.Second
+
+	// labels set by the kubelet on the containers created through the CRI
+	criPodNameLabel       = "io.kubernetes.pod.name"
+	criPodNamespaceLabel  = "io.kubernetes.pod.namespace"
+	criPodUIDLabel        = "io.kubernetes.pod.uid"
+	criContainerNameLabel = "io.kubernetes.container.name"
+)
+
+// ContainerRuntimeResolver reads the metadata of a container from a container runtime
+type ContainerRuntimeResolver interface {
+	// Name returns the name of the container runtime, e.g. containerd or docker
+	Name() string
+	// GetContainerInfo returns the metadata of the container, or an error if the runtime does not know the container
+	GetContainerInfo(containerID string) (*ContainerInfo, error)
+}
+
+type containerRuntimeSocket struct {
+	name string
+	path string
+	// docker is true for the runtimes implementing the Docker Engine API instead of the CRI
+	docker bool
+}
+
+var (
+	// defaultContainerRuntimeSockets are the sockets probed when CONTAINER_RUNTIME_ENDPOINT is not set
+	defaultContainerRuntimeSockets = []containerRuntimeSocket{
+		{name: "containerd", path: "/run/containerd/containerd.sock"},
+		{name: "cri-o", path: "/var/run/crio/crio.sock"},
+		{name: "docker", path: "/var/run/docker.sock", docker: true},
+		{name: "podman", path: "/run/podman/podman.sock", docker: true},
+	}
+
+	// containerRuntimeResolvers are queried in order for the containers that are not in the kubelet pod list
+	containerRuntimeResolvers = []ContainerRuntimeResolver{}
+	// runtimeContainerCache holds the containers found in the container runtimes by container ID
+	runtimeContainerCache = map[string]runtimeCacheEntry{}
+)
+
+type runtimeCacheEntry struct {
+	info      *ContainerInfo
+	expiresAt time.Time
+}
+
+// initContainerRuntimeResolvers connects to the container runtime at endpoint, or to all the runtimes found in the default sockets
+func initContainerRuntimeResolvers(endpoint string) {
+	sockets := defaultContainerRuntimeSockets
+	if endpoint != "" {
+		path := strings.TrimPrefix(endpoint, "unix://")
+		sockets = []containerRuntimeSocket{{
+			name:   path,
+			path:   path,
+			docker: strings.Contains(path, "docker") || strings.Contains(path, "podman"),
+		}}
+	}
+	resolvers := []ContainerRuntimeResolver{}
+	for _, socket := range sockets {
+		if _, err := os.Stat(socket.path); err != nil {
+			continue
+		}
+		var resolver ContainerRuntimeResolver
+		var err error
+		if socket.docker {
+			resolver, err = NewDockerResolver(socket.name, socket.path)
+		} else {
+			resolver, err = NewCRIResolver(socket.name, socket.path)
+		}
+		if err != nil {
+			klog.V(1).Infof("Could not connect to the container runtime %s: %v", socket.path, err)
+			continue
+		}
+		klog.V(1).Infof("Using the container runtime %s to resolve the container metadata", socket.path)
+		resolvers = append(resolvers, resolver)
+	}
+	containerRuntimeResolvers = resolvers
+}
+
+// HasContainerRuntimeResolver returns true if the container metadata can be read from a container runtime
+func HasContainerRuntimeResolver() bool {
+	return len(containerRuntimeResolvers) > 0
+}
+
+// getContainerInfoFromRuntime returns the metadata of the container from the first runtime that knows it.
+// The containers found are cached during runtimeCacheTTL, so that a removed container can be reported alive until the cache expires.
+func getContainerInfoFromRuntime(containerID string) (*ContainerInfo, error) {
+	now := time.Now()
+	if entry, found := runtimeContainerCache[containerID]; found && now.Before(entry.expiresAt) {
+		return entry.info, nil
+	}
+	delete(runtimeContainerCache, containerID)
+	for _, resolver := range containerRuntimeResolvers {
+		info, err := resolver.GetContainerInfo(containerID)
+		if err == nil {
+			runtimeContainerCache[containerID] = runtimeCacheEntry{info: info, expiresAt: now.Add(runtimeCacheTTL)}
+			return info, nil
+		}
+		klog.V(5).Infof("container %s not found in %s: %v", containerID, resolver.Name(), err)
+	}
+	return nil, fmt.Errorf("container %s not found in the container runtimes", containerID)
+}
+
+// newRuntimeContainerInfo creates the ContainerInfo of a container that is not in the kubelet pod list.
+// Containers created by the kubelet are identified by their labels, the others use the runtime name as namespace.
+func newRuntimeContainerInfo(runtimeName, containerID, name, image string, labels map[string]string) *ContainerInfo {
+	info := &ContainerInfo{
+		ContainerID:   containerID,
+		ContainerName: strings.TrimPrefix(name, "/"),
+		PodName:       strings.TrimPrefix(name, "/"),
+		Namespace:     runtimeName,
+		Image:         image,
+		Labels:        labels,
+	}
+	if podName, found := labels[criPodNameLabel]; found {
+		info.PodName = podName
+		info.Namespace = labels[criPodNamespaceLabel]
+		info.PodUID = labels[criPodUIDLabel]
+		if containerName, found := labels[criContainerNameLabel]; found {
+			info.ContainerName = containerName
+		}
+	}
+	return info
+}
+
+// unixDialer connects to a unix socket regardless of the requested address
+func unixDialer(socketPath string) func(ctx context.Context, _ string) (net.Conn, error) {
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socketPath)
+	}
+}
+
+// CRIResolver reads the container metadata from the CRI runtime service, e.g. containerd or CRI-O
+type CRIResolver struct {
+	name   string
+	conn   *grpc.ClientConn
+	client runtimeapi.RuntimeServiceClient
+}
+
+// NewCRIResolver connects to the CRI runtime service listening on socketPath
+func NewCRIResolver(name, socketPath string) (*CRIResolver, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), runtimeTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(unixDialer(socketPath)),
+		grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	r := &CRIResolver{
+		name:   name,
+		conn:   conn,
+		client: runtimeapi.NewRuntimeServiceClient(conn),
+	}
+	// the runtime must implement the v1 CRI API
+	if _, err := r.client.Version(ctx, &runtimeapi.VersionRequest{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CRIResolver) Name() string {
+	return r.name
+}
+
+func (r *CRIResolver) GetContainerInfo(containerID string) (*ContainerInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), runtimeTimeout)
+	defer cancel()
+	resp, err := r.client.ContainerStatus(ctx, &runtimeapi.ContainerStatusRequest{ContainerId: containerID})
+	if err != nil {
+		return nil, err
+	}
+	status := resp.GetStatus()
+	if status == nil {
+		return nil, fmt.Errorf("no status for container %s", containerID)
+	}
+	return newRuntimeContainerInfo(r.name, containerID, status.GetMetadata().GetName(), status.GetImage().GetImage(), status.GetLabels()), nil
+}
+
+// Close closes the connection to the runtime service
+func (r *CRIResolver) Close() error {
+	return r.conn.Close()
+}
+
+// dockerContainer is the subset of the Docker Engine API container inspect response used by kepler
+type dockerContainer struct {
+	Name   string `json:"Name"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// DockerResolver reads the container metadata from the Docker Engine API, which is also implemented by Podman
+type DockerResolver struct {
+	name   string
+	client *http.Client
+}
+
+// NewDockerResolver connects to the Docker Engine API listening on socketPath
+func NewDockerResolver(name, socketPath string) (*DockerResolver, error) {
+	r := &DockerResolver{
+		name: name,
+		client: &http.Client{
+			Timeout:   runtimeTimeout,
+			Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) { return unixDialer(socketPath)(ctx, "") }},
+		},
+	}
+	if err := r.get("/_ping", nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *DockerResolver) Name() string {
+	return r.name
+}
+
+func (r *DockerResolver) GetContainerInfo(containerID string) (*ContainerInfo, error) {
+	var container dockerContainer
+	if err := r.get("/containers/"+containerID+"/json", &container); err != nil {
+		return nil, err
+	}
+	return newRuntimeContainerInfo(r.name, containerID, container.Name, container.Config.Image, container.Config.Labels), nil
+}
+
+func (r *DockerResolver) get(path string, v interface{}) error {
+	// the host is ignored when connecting to a unix socket
+	resp, err := r.client.Get("http://localhost" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %s: %s", path, resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/cri-api/pkg/apis/runtime/v1"
+)
+
+// fakeRuntimeService implements the CRI calls used by the CRIResolver
+type fakeRuntimeService struct {
+	runtimeapi.UnimplementedRuntimeServiceServer
+	containers map[string]*runtimeapi.ContainerStatus
+}
+
+func (f *fakeRuntimeService) Version(ctx context.Context, req *runtimeapi.VersionRequest) (*runtimeapi.VersionResponse, error) {
+	return &runtimeapi.VersionResponse{RuntimeName: "fake", RuntimeApiVersion: "v1"}, nil
+}
+
+func (f *fakeRuntimeService) ContainerStatus(ctx context.Context, req *runtimeapi.ContainerStatusRequest) (*runtimeapi.ContainerStatusResponse, error) {
+	container, found := f.containers[req.ContainerId]
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "container %s not found", req.ContainerId)
+	}
+	return &runtimeapi.ContainerStatusResponse{Status: container}, nil
+}
+
+// countingResolver is a ContainerRuntimeResolver counting the container lookups
+type countingResolver struct {
+	containers map[string]*ContainerInfo
+	calls      int
+}
+
+func (f *countingResolver) Name() string {
+	return "fake"
+}
+
+func (f *countingResolver) GetContainerInfo(containerID string) (*ContainerInfo, error) {
+	f.calls++
+	info, found := f.containers[containerID]
+	if !found {
+		return nil, fmt.Errorf("container %s not found", containerID)
+	}
+	return info, nil
+}
+
+func startFakeCRIServer(t *testing.T, containers map[string]*runtimeapi.ContainerStatus) string {
+	socketPath := filepath.Join(t.TempDir(), "cri.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	runtimeapi.RegisterRuntimeServiceServer(server, &fakeRuntimeService{containers: containers})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return socketPath
+}
+
+func startFakeDockerServer(t *testing.T, containers map[string]dockerContainer) string {
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	})
+	mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
+		containerID := filepath.Base(filepath.Dir(r.URL.Path))
+		container, found := containers[containerID]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(container)
+	})
+	server := httptest.NewUnstartedServer(mux)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return socketPath
+}
+
+func newDockerContainer(name, image string, labels map[string]string) dockerContainer {
+	container := dockerContainer{Name: name}
+	container.Config.Image = image
+	container.Config.Labels = labels
+	return container
+}
+
+func TestCRIResolver(t *testing.T) {
+	g := NewWithT(t)
+
+	socketPath := startFakeCRIServer(t, map[string]*runtimeapi.ContainerStatus{
+		"abc": {
+			Metadata: &runtimeapi.ContainerMetadata{Name: "nginx"},
+			Image:    &runtimeapi.ImageSpec{Image: "docker.io/library/nginx:latest"},
+			Labels:   map[string]string{"app": "web"},
+		},
+		"def": {
+			Metadata: &runtimeapi.ContainerMetadata{Name: "app"},
+			Image:    &runtimeapi.ImageSpec{Image: "quay.io/app:v1"},
+			Labels: map[string]string{
+				criPodNameLabel:       "podA",
+				criPodNamespaceLabel:  "default",
+				criPodUIDLabel:        "uid-a",
+				criContainerNameLabel: "app",
+			},
+		},
+	})
+	resolver, err := NewCRIResolver("containerd", socketPath)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer resolver.Close()
+
+	info, err := resolver.GetContainerInfo("abc")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*info).To(Equal(ContainerInfo{
+		ContainerID:   "abc",
+		ContainerName: "nginx",
+		PodName:       "nginx",
+		Namespace:     "containerd",
+		Image:         "docker.io/library/nginx:latest",
+		Labels:        map[string]string{"app": "web"},
+	}))
+
+	// the containers created by the kubelet keep their pod metadata
+	info, err = resolver.GetContainerInfo("def")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.PodName).To(Equal("podA"))
+	g.Expect(info.Namespace).To(Equal("default"))
+	g.Expect(info.PodUID).To(Equal("uid-a"))
+	g.Expect(info.ContainerName).To(Equal("app"))
+
+	_, err = resolver.GetContainerInfo("unknown")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestDockerResolver(t *testing.T) {
+	g := NewWithT(t)
+
+	socketPath := startFakeDockerServer(t, map[string]dockerContainer{
+		"abc": newDockerContainer("/redis", "redis:7", map[string]string{"com.docker.compose.project": "cache"}),
+	})
+	resolver, err := NewDockerResolver("docker", socketPath)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	info, err := resolver.GetContainerInfo("abc")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.ContainerName).To(Equal("redis"))
+	g.Expect(info.PodName).To(Equal("redis"))
+	g.Expect(info.Namespace).To(Equal("docker"))
+	g.Expect(info.Image).To(Equal("redis:7"))
+	g.Expect(info.Labels).To(HaveKeyWithValue("com.docker.compose.project", "cache"))
+
+	_, err = resolver.GetContainerInfo("unknown")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestContainerRuntimeResolverFallback(t *testing.T) {
+	g := NewWithT(t)
+	defer func() {
+		containerRuntimeResolvers = []ContainerRuntimeResolver{}
+		runtimeContainerCache = map[string]runtimeCacheEntry{}
+		podProvider = &podLister
+	}()
+
+	socketPath := startFakeCRIServer(t, map[string]*runtimeapi.ContainerStatus{
+		"abc": {
+			Metadata: &runtimeapi.ContainerMetadata{Name: "nginx"},
+			Image:    &runtimeapi.ImageSpec{Image: "nginx"},
+		},
+	})
+	initContainerRuntimeResolvers("unix://" + socketPath)
+	g.Expect(HasContainerRuntimeResolver()).To(BeTrue())
+
+	info, err := getContainerInfoFromRuntime("abc")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(info.ContainerName).To(Equal("nginx"))
+	containerIDToContainerInfo["abc"] = info
+	containerIDToContainerInfo["gone"] = &ContainerInfo{ContainerID: "gone", Image: "nginx"}
+
+	// without kubelet the runtime containers are alive as long as the runtime knows them
+	podProvider = &fakePodProvider{err: fmt.Errorf("kubelet not available")}
+	aliveContainers, err := GetAliveContainers()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(aliveContainers).To(HaveKey("abc"))
+	g.Expect(aliveContainers).NotTo(HaveKey("gone"))
+	g.Expect(containerIDToContainerInfo).NotTo(HaveKey("gone"))
+	delete(containerIDToContainerInfo, "abc")
+
+	// an endpoint without runtime listening is ignored
+	initContainerRuntimeResolvers("unix://" + filepath.Join(t.TempDir(), "missing.sock"))
+	g.Expect(HasContainerRuntimeResolver()).To(BeFalse())
+}
+
+func TestGetAliveContainersCachesTheRuntimeLookups(t *testing.T) {
+	g := NewWithT(t)
+	defer func() {
+		containerRuntimeResolvers = []ContainerRuntimeResolver{}
+		runtimeContainerCache = map[string]runtimeCacheEntry{}
+		podProvider = &podLister
+		delete(containerIDToContainerInfo, "abc")
+	}()
+
+	info := &ContainerInfo{ContainerID: "abc", ContainerName: "nginx", Image: "nginx"}
+	resolver := &countingResolver{containers: map[string]*ContainerInfo{"abc": info}}
+	containerRuntimeResolvers = []ContainerRuntimeResolver{resolver}
+	podProvider = &fakePodProvider{err: fmt.Errorf("kubelet not available")}
+	containerIDToContainerInfo["abc"] = getContainerInfoByID("abc", newSystemProcessInfo())
+	g.Expect(resolver.calls).To(Equal(1))
+
+	// the container is alive without asking the runtime again until the cache expires
+	for i := 0; i < 3; i++ {
+		aliveContainers, err := GetAliveContainers()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(aliveContainers).To(HaveKey("abc"))
+	}
+	g.Expect(resolver.calls).To(Equal(1))
+
+	// the removed container is found dead once the cache expires
+	delete(resolver.containers, "abc")
+	runtimeContainerCache["abc"] = runtimeCacheEntry{info: info, expiresAt: time.Now().Add(-time.Second)}
+	aliveContainers, err := GetAliveContainers()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(aliveContainers).NotTo(HaveKey("abc"))
+	g.Expect(resolver.calls).To(Equal(2))
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	replicaSetKind = "ReplicaSet"
+	jobKind        = "Job"
+
+	// maxOwnerDepth limits the number of controllers followed from a pod to its top controller
+	maxOwnerDepth = 5
+	// ownerCacheTTL is how long a controller lookup is cached, the owner of a controller rarely changes
+	ownerCacheTTL = 10 * time.Minute
+)
+
+// ControllerGetter returns the controller owning a workload controller, e.g. the Deployment of a ReplicaSet.
+// It returns nil if the controller has no owner or if the kind is not supported.
+type ControllerGetter interface {
+	GetControllerOf(namespace, kind, name string) (*metav1.OwnerReference, error)
+}
+
+// InformerControllerGetter reads the ReplicaSets and Jobs from a local cache kept up to date by watching the API server,
+// so that resolving the owner of a new pod does not send any request
+type InformerControllerGetter struct {
+	factory          informers.SharedInformerFactory
+	replicaSetLister appslisters.ReplicaSetLister
+	jobLister        batchlisters.JobLister
+	synced           []cache.InformerSynced
+	stopCh           chan struct{}
+}
+
+// NewInformerControllerGetter creates a ControllerGetter watching the controllers with the given client, it must be started before use
+func NewInformerControllerGetter(client kubernetes.Interface) *InformerControllerGetter {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	replicaSetInformer := factory.Apps().V1().ReplicaSets()
+	jobInformer := factory.Batch().V1().Jobs()
+	return &InformerControllerGetter{
+		factory:          factory,
+		replicaSetLister: replicaSetInformer.Lister(),
+		jobLister:        jobInformer.Lister(),
+		synced:           []cache.InformerSynced{replicaSetInformer.Informer().HasSynced, jobInformer.Informer().HasSynced},
+		stopCh:           make(chan struct{}),
+	}
+}
+
+// Start starts watching the controllers and waits until they are listed or the timeout expires
+func (g *InformerControllerGetter) Start(timeout time.Duration) error {
+	g.factory.Start(g.stopCh)
+	stopCh := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stopCh) })
+	defer timer.Stop()
+	if !cache.WaitForCacheSync(stopCh, g.synced...) {
+		return fmt.Errorf("timed out waiting for the controller informers to sync")
+	}
+	return nil
+}
+
+// Stop stops watching the controllers
+func (g *InformerControllerGetter) Stop() {
+	close(g.stopCh)
+}
+
+func (g *InformerControllerGetter) GetControllerOf(namespace, kind, name string) (*metav1.OwnerReference, error) {
+	switch kind {
+	case replicaSetKind:
+		rs, err := g.replicaSetLister.ReplicaSets(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return metav1.GetControllerOf(rs), nil
+	case jobKind:
+		job, err := g.jobLister.Jobs(namespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+		return metav1.GetControllerOf(job), nil
+	}
+	return nil, nil
+}
+
+type ownerCacheEntry struct {
+	owner     *metav1.OwnerReference
+	expiresAt time.Time
+}
+
+// OwnerResolver resolves the top controller of a pod, e.g. the Deployment instead of the ReplicaSet.
+// The controller lookups are cached since all pods of a workload share the same owners.
+type OwnerResolver struct {
+	getter ControllerGetter
+	cache  map[string]ownerCacheEntry
+	mu     sync.Mutex
+}
+
+// NewOwnerResolver creates an OwnerResolver, if getter is nil only the direct controller of the pod is resolved
+func NewOwnerResolver(getter ControllerGetter) *OwnerResolver {
+	return &OwnerResolver{
+		getter: getter,
+		cache:  map[string]ownerCacheEntry{},
+	}
+}
+
+// GetPodOwner returns the kind and name of the top controller of the pod, empty if the pod has no controller.
+// If a controller cannot be read, the last resolved controller is returned.
+func (r *OwnerResolver) GetPodOwner(pod *corev1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "", ""
+	}
+	kind, name = owner.Kind, owner.Name
+	if r == nil || r.getter == nil {
+		return kind, name
+	}
+	for i := 0; i < maxOwnerDepth; i++ {
+		parent, err := r.getControllerOf(pod.Namespace, kind, name)
+		if err != nil {
+			klog.V(5).Infof("failed to get the owner of %s %s/%s: %v", kind, pod.Namespace, name, err)
+			break
+		}
+		if parent == nil {
+			break
+		}
+		kind, name = parent.Kind, parent.Name
+	}
+	return kind, name
+}
+
+func (r *OwnerResolver) getControllerOf(namespace, kind, name string) (*metav1.OwnerReference, error) {
+	if kind != replicaSetKind && kind != jobKind {
+		return nil, nil
+	}
+	key := kind + "/" + namespace + "/" + name
+	now := time.Now()
+	r.mu.Lock()
+	entry, found := r.cache[key]
+	r.mu.Unlock()
+	if found && now.Before(entry.expiresAt) {
+		return entry.owner, nil
+	}
+	owner, err := r.getter.GetControllerOf(namespace, kind, name)
+	if err != nil {
+		// errors are not cached, the controller of a new pod can be missing until the informer receives it
+		return nil, err
+	}
+	r.mu.Lock()
+	r.cache[key] = ownerCacheEntry{owner: owner, expiresAt: now.Add(ownerCacheTTL)}
+	// remove the expired entries of deleted controllers
+	for k, e := range r.cache {
+		if now.After(e.expiresAt) {
+			delete(r.cache, k)
+		}
+	}
+	r.mu.Unlock()
+	return owner, nil
+}
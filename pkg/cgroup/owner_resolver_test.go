@@ -0,0 +1,149 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeControllerGetter returns the owners of the controllers from a map indexed by kind/namespace/name
+type fakeControllerGetter struct {
+	owners map[string]*metav1.OwnerReference
+	calls  int
+	err    error
+}
+
+func (f *fakeControllerGetter) GetControllerOf(namespace, kind, name string) (*metav1.OwnerReference, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.owners[kind+"/"+namespace+"/"+name], nil
+}
+
+func newOwnedPod(namespace, ownerKind, ownerName string) *corev1.Pod {
+	isController := true
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ownerName + "-x2x4q",
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: ownerKind, Name: ownerName, Controller: &isController},
+			},
+		},
+	}
+}
+
+func TestGetPodOwner(t *testing.T) {
+	g := NewWithT(t)
+
+	getter := &fakeControllerGetter{
+		owners: map[string]*metav1.OwnerReference{
+			"ReplicaSet/default/web-7c9f8":  {Kind: "Deployment", Name: "web"},
+			"Job/default/backup-28012345":   {Kind: "CronJob", Name: "backup"},
+			"ReplicaSet/default/standalone": nil,
+		},
+	}
+	resolver := NewOwnerResolver(getter)
+
+	var testcases = []struct {
+		name       string
+		pod        *corev1.Pod
+		expectKind string
+		expectName string
+	}{
+		{"deployment", newOwnedPod("default", "ReplicaSet", "web-7c9f8"), "Deployment", "web"},
+		{"cronjob", newOwnedPod("default", "Job", "backup-28012345"), "CronJob", "backup"},
+		{"replicaset without deployment", newOwnedPod("default", "ReplicaSet", "standalone"), "ReplicaSet", "standalone"},
+		{"daemonset", newOwnedPod("default", "DaemonSet", "agent"), "DaemonSet", "agent"},
+		{"no controller", &corev1.Pod{}, "", ""},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			kind, name := resolver.GetPodOwner(testcase.pod)
+			g.Expect(kind).To(Equal(testcase.expectKind))
+			g.Expect(name).To(Equal(testcase.expectName))
+		})
+	}
+	// the DaemonSet is not looked up and the lookups of the ReplicaSet and Job owners are cached
+	calls := getter.calls
+	g.Expect(calls).To(Equal(3))
+	resolver.GetPodOwner(newOwnedPod("default", "ReplicaSet", "web-7c9f8"))
+	resolver.GetPodOwner(newOwnedPod("default", "ReplicaSet", "standalone"))
+	g.Expect(getter.calls).To(Equal(calls))
+}
+
+func TestGetPodOwnerWithoutAPI(t *testing.T) {
+	g := NewWithT(t)
+
+	// without access to the API the direct controller is returned
+	kind, name := NewOwnerResolver(nil).GetPodOwner(newOwnedPod("default", "ReplicaSet", "web-7c9f8"))
+	g.Expect(kind).To(Equal("ReplicaSet"))
+	g.Expect(name).To(Equal("web-7c9f8"))
+
+	// errors are not cached
+	getter := &fakeControllerGetter{err: fmt.Errorf("forbidden")}
+	resolver := NewOwnerResolver(getter)
+	kind, _ = resolver.GetPodOwner(newOwnedPod("default", "ReplicaSet", "web-7c9f8"))
+	g.Expect(kind).To(Equal("ReplicaSet"))
+	getter.err = nil
+	getter.owners = map[string]*metav1.OwnerReference{"ReplicaSet/default/web-7c9f8": {Kind: "Deployment", Name: "web"}}
+	kind, name = resolver.GetPodOwner(newOwnedPod("default", "ReplicaSet", "web-7c9f8"))
+	g.Expect(kind).To(Equal("Deployment"))
+	g.Expect(name).To(Equal("web"))
+}
+
+func TestInformerControllerGetter(t *testing.T) {
+	g := NewWithT(t)
+
+	isController := true
+	client := fake.NewSimpleClientset(
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-7c9f8", Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &isController}}}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "backup-28012345", Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "CronJob", Name: "backup", Controller: &isController}}}},
+	)
+	getter := NewInformerControllerGetter(client)
+	g.Expect(getter.Start(10 * time.Second)).To(Succeed())
+	defer getter.Stop()
+	resolver := NewOwnerResolver(getter)
+
+	kind, name := resolver.GetPodOwner(newOwnedPod("default", "ReplicaSet", "web-7c9f8"))
+	g.Expect(kind).To(Equal("Deployment"))
+	g.Expect(name).To(Equal("web"))
+	kind, name = resolver.GetPodOwner(newOwnedPod("default", "Job", "backup-28012345"))
+	g.Expect(kind).To(Equal("CronJob"))
+	g.Expect(name).To(Equal("backup"))
+	// the unknown controllers are returned as the owner
+	kind, _ = resolver.GetPodOwner(newOwnedPod("default", "ReplicaSet", "deleted"))
+	g.Expect(kind).To(Equal("ReplicaSet"))
+
+	// the controllers are read from the informer cache, only list and watch requests are sent
+	for _, action := range client.Actions() {
+		g.Expect(action.GetVerb()).To(BeElementOf("list", "watch"))
+	}
+}
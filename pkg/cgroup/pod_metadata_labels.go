@@ -0,0 +1,97 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"regexp"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the metric label names follow the kube-state-metrics convention, e.g. the pod label team is exported as label_team
+	podLabelPrefix      = "label_"
+	podAnnotationPrefix = "annotation_"
+)
+
+var (
+	invalidMetricLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+	// podMetadataLabels are the pod labels and annotations exported as metric labels
+	podMetadataLabels = newPodMetadataLabels(config.PodLabelsAllowlist, config.PodAnnotationsAllowlist, config.MaxPodMetadataLabels)
+)
+
+// podMetadataLabel maps a pod label or annotation to a metric label
+type podMetadataLabel struct {
+	name       string
+	key        string
+	annotation bool
+}
+
+func newPodMetadataLabels(labels, annotations []string, limit int) []podMetadataLabel {
+	metadataLabels := []podMetadataLabel{}
+	names := map[string]bool{}
+	add := func(prefix, key string, annotation bool) {
+		name := prefix + invalidMetricLabelChars.ReplaceAllString(key, "_")
+		if names[name] {
+			return
+		}
+		// the cardinality guard, each label multiplies the number of series by the number of its values
+		if len(metadataLabels) >= limit {
+			klog.Warningf("refuse to export the pod metadata %s as metric label, the limit of %d labels is reached", key, limit)
+			return
+		}
+		names[name] = true
+		metadataLabels = append(metadataLabels, podMetadataLabel{name: name, key: key, annotation: annotation})
+	}
+	for _, key := range labels {
+		add(podLabelPrefix, key, false)
+	}
+	for _, key := range annotations {
+		add(podAnnotationPrefix, key, true)
+	}
+	return metadataLabels
+}
+
+// SetPodMetadataLabels sets the pod labels and annotations exported as metric labels
+func SetPodMetadataLabels(labels, annotations []string, limit int) {
+	podMetadataLabels = newPodMetadataLabels(labels, annotations, limit)
+}
+
+// GetPodMetadataLabelNames returns the metric label names of the allowed pod labels and annotations
+func GetPodMetadataLabelNames() []string {
+	names := make([]string, len(podMetadataLabels))
+	for i, label := range podMetadataLabels {
+		names[i] = label.name
+	}
+	return names
+}
+
+// getPodMetadataLabelValues returns the values of the allowed pod labels and annotations, empty if not set in the pod
+func getPodMetadataLabelValues(pod *corev1.Pod) []string {
+	values := make([]string, len(podMetadataLabels))
+	for i, label := range podMetadataLabels {
+		if label.annotation {
+			values[i] = pod.Annotations[label.key]
+		} else {
+			values[i] = pod.Labels[label.key]
+		}
+	}
+	return values
+}
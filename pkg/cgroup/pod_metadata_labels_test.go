@@ -0,0 +1,50 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodMetadataLabels(t *testing.T) {
+	g := NewWithT(t)
+	defer SetPodMetadataLabels(nil, nil, 0)
+
+	SetPodMetadataLabels([]string{"team", "cost-center", "team"}, []string{"example.com/owner"}, 5)
+	g.Expect(GetPodMetadataLabelNames()).To(Equal([]string{"label_team", "label_cost_center", "annotation_example_com_owner"}))
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"team": "energy", "app": "web"},
+			Annotations: map[string]string{"example.com/owner": "alice"},
+		},
+	}
+	// the missing cost-center label is exported as an empty value
+	g.Expect(getPodMetadataLabelValues(pod)).To(Equal([]string{"energy", "", "alice"}))
+	info := NewContainerInfo("c1", "nginx", pod)
+	g.Expect(info.PodMetadataLabels).To(Equal([]string{"energy", "", "alice"}))
+
+	// the labels beyond the limit are refused
+	SetPodMetadataLabels([]string{"team", "cost-center"}, []string{"example.com/owner"}, 2)
+	g.Expect(GetPodMetadataLabelNames()).To(Equal([]string{"label_team", "label_cost_center"}))
+	g.Expect(getPodMetadataLabelValues(pod)).To(Equal([]string{"energy", ""}))
+}
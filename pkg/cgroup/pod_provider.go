@@ -0,0 +1,130 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// podInformerSyncTimeout is how long we wait for the first list of pods before falling back to the kubelet
+	podInformerSyncTimeout = 30 * time.Second
+)
+
+// PodMetadataProvider lists the pods running on the node
+type PodMetadataProvider interface {
+	ListPods() (*[]corev1.Pod, error)
+}
+
+// PodInformerProvider lists the pods of the node from a local cache kept up to date by watching the API server.
+// Unlike the kubelet /pods endpoint, listing the pods does not send any request.
+type PodInformerProvider struct {
+	nodeName string
+	factory  informers.SharedInformerFactory
+	lister   corelisters.PodLister
+	synced   cache.InformerSynced
+	stopCh   chan struct{}
+}
+
+// NewPodInformerProvider creates a PodInformerProvider watching the pods scheduled on nodeName
+func NewPodInformerProvider(client kubernetes.Interface, nodeName string) *PodInformerProvider {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		}))
+	podInformer := factory.Core().V1().Pods()
+	return &PodInformerProvider{
+		nodeName: nodeName,
+		factory:  factory,
+		lister:   podInformer.Lister(),
+		synced:   podInformer.Informer().HasSynced,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start starts watching the pods and waits until the pods are listed or the timeout expires
+func (p *PodInformerProvider) Start(timeout time.Duration) error {
+	p.factory.Start(p.stopCh)
+	stopCh := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() { close(stopCh) })
+	defer timer.Stop()
+	if !cache.WaitForCacheSync(stopCh, p.synced) {
+		return fmt.Errorf("timed out waiting for the pod informer to sync")
+	}
+	return nil
+}
+
+// Stop stops watching the pods
+func (p *PodInformerProvider) Stop() {
+	close(p.stopCh)
+}
+
+// ListPods returns the pods of the node from the informer cache
+func (p *PodInformerProvider) ListPods() (*[]corev1.Pod, error) {
+	if !p.synced() {
+		return nil, fmt.Errorf("the pod informer is not synced")
+	}
+	cachedPods, err := p.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]corev1.Pod, 0, len(cachedPods))
+	for _, pod := range cachedPods {
+		// the field selector is applied by the API server, we keep the check for the clients that ignore it
+		if pod.Spec.NodeName != p.nodeName {
+			continue
+		}
+		pods = append(pods, *pod)
+	}
+	return &pods, nil
+}
+
+// fallbackPodProvider lists the pods with the primary provider and uses the fallback provider when it fails
+type fallbackPodProvider struct {
+	primary  PodMetadataProvider
+	fallback PodMetadataProvider
+}
+
+func (f *fallbackPodProvider) ListPods() (*[]corev1.Pod, error) {
+	pods, err := f.primary.ListPods()
+	if err == nil {
+		return pods, nil
+	}
+	klog.V(4).Infof("failed to list the pods from the primary provider, using the fallback: %v", err)
+	return f.fallback.ListPods()
+}
+
+// newInClusterClient creates a kubernetes client using the service account of the pod
+func newInClusterClient() (kubernetes.Interface, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
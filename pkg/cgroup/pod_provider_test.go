@@ -0,0 +1,138 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newNodePod(name, nodeName, containerID string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", ContainerID: "containerd://" + containerID},
+			},
+		},
+	}
+}
+
+func podNames(pods *[]corev1.Pod) []string {
+	names := []string{}
+	for i := range *pods {
+		names = append(names, (*pods)[i].Name)
+	}
+	return names
+}
+
+// fakePodProvider returns a fixed list of pods or an error
+type fakePodProvider struct {
+	pods  []corev1.Pod
+	err   error
+	calls int
+}
+
+func (f *fakePodProvider) ListPods() (*[]corev1.Pod, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &f.pods, nil
+}
+
+func TestPodInformerProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	client := fake.NewSimpleClientset(newNodePod("podA", "node-a", "a1"), newNodePod("podB", "node-b", "b1"))
+	provider := NewPodInformerProvider(client, "node-a")
+	_, err := provider.ListPods()
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(provider.Start(10 * time.Second)).To(Succeed())
+	defer provider.Stop()
+	pods, err := provider.ListPods()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(podNames(pods)).To(ConsistOf("podA"))
+
+	// the new pods are received from the watch without listing the pods again
+	_, err = client.CoreV1().Pods("default").Create(context.Background(), newNodePod("podC", "node-a", "c1"), metav1.CreateOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Eventually(func() []string {
+		pods, _ := provider.ListPods()
+		return podNames(pods)
+	}, 5*time.Second, 10*time.Millisecond).Should(ConsistOf("podA", "podC"))
+}
+
+func TestFallbackPodProvider(t *testing.T) {
+	g := NewWithT(t)
+
+	primary := &fakePodProvider{pods: []corev1.Pod{*newNodePod("podA", "node-a", "a1")}}
+	fallback := &fakePodProvider{pods: []corev1.Pod{*newNodePod("podB", "node-a", "b1")}}
+	provider := &fallbackPodProvider{primary: primary, fallback: fallback}
+
+	pods, err := provider.ListPods()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(podNames(pods)).To(ConsistOf("podA"))
+	g.Expect(fallback.calls).To(Equal(0))
+
+	primary.err = fmt.Errorf("not synced")
+	pods, err = provider.ListPods()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(podNames(pods)).To(ConsistOf("podB"))
+	g.Expect(fallback.calls).To(Equal(1))
+}
+
+func TestInitAPIProviders(t *testing.T) {
+	g := NewWithT(t)
+	defer func() {
+		podProvider = &podLister
+		podOwnerResolver = NewOwnerResolver(nil)
+	}()
+
+	client := fake.NewSimpleClientset(newNodePod("podA", "node-a", "a1"))
+	nodeName := config.NodeName
+	config.NodeName = "node-a"
+	defer func() { config.NodeName = nodeName }()
+	// the pod informer and the pod owner resolution are opt-in
+	enablePodInformer, resolvePodOwner := config.EnablePodInformer, config.ResolvePodOwner
+	config.EnablePodInformer, config.ResolvePodOwner = true, true
+	defer func() { config.EnablePodInformer, config.ResolvePodOwner = enablePodInformer, resolvePodOwner }()
+	initAPIProviders(client)
+	if informer, ok := podProvider.(*fallbackPodProvider); ok {
+		defer informer.primary.(*PodInformerProvider).Stop()
+	}
+	if getter, ok := podOwnerResolver.getter.(*InformerControllerGetter); ok {
+		defer getter.Stop()
+	}
+
+	// the containers are resolved from the informer cache without calling the kubelet
+	_, err := updateListPodCache("", false)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(containerIDToContainerInfo).To(HaveKey("a1"))
+	g.Expect(containerIDToContainerInfo["a1"].PodName).To(Equal("podA"))
+}
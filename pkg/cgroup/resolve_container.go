@@ -25,11 +25,13 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/kubelet"
 	"github.com/sustainable-computing-io/kepler/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
 
@@ -38,6 +40,21 @@ type ContainerInfo struct {
 	ContainerName string
 	PodName       string
 	Namespace     string
+	PodUID        string
+	QOSClass      string
+	// OwnerKind and OwnerName identify the top controller of the pod, e.g. Deployment or DaemonSet
+	OwnerKind string
+	OwnerName string
+	// PodMetadataLabels are the values of the pod labels and annotations exported as metric labels, see GetPodMetadataLabelNames
+	PodMetadataLabels []string
+	// resources of the container spec, the CPU in millicores and the memory in bytes, 0 if not set
+	CPURequest    uint64
+	MemoryRequest uint64
+	CPULimit      uint64
+	MemoryLimit   uint64
+	// Image and Labels are only set for the containers resolved from the container runtime
+	Image  string
+	Labels map[string]string
 }
 
 const (
@@ -45,16 +62,25 @@ const (
 
 	procPath   string = "/proc/%d/cgroup"
 	cgroupPath string = "/sys/fs/cgroup"
+
+	// unknownContainerRetryInterval is how long a container ID discovered in the cgroups is not looked up again after it was not found
+	unknownContainerRetryInterval = 30 * time.Second
 )
 
 var (
 	byteOrder binary.ByteOrder         = utils.DetermineHostByteOrder()
 	podLister kubelet.KubeletPodLister = kubelet.KubeletPodLister{}
+	// podProvider lists the node pods, from the kubelet /pods endpoint unless the pod informer is started
+	podProvider PodMetadataProvider = &podLister
+	// podOwnerResolver resolves the top controller of the pods, it only knows the direct controller until Init is called
+	podOwnerResolver = NewOwnerResolver(nil)
 
 	// map to cache data to speedup lookups
 	containerIDCache           = map[uint64]string{}
 	containerIDToContainerInfo = map[string]*ContainerInfo{}
 	cGroupIDToPath             = map[uint64]string{}
+	// unknownContainerIDs holds the next lookup time of the discovered container IDs that were neither in the pod list nor in the container runtime
+	unknownContainerIDs = map[string]time.Time{}
 
 	// regex to extract container ID from path
 	regexFindContainerIDPath          = regexp.MustCompile(`.*-(.*?)\.scope`)
@@ -67,9 +93,48 @@ var (
 )
 
 func Init() (*[]corev1.Pod, error) {
+	if config.EnableContainerRuntimeResolver {
+		initContainerRuntimeResolvers(config.ContainerRuntimeEndpoint)
+	}
+	if config.ResolvePodOwner || config.EnablePodInformer {
+		client, err := newInClusterClient()
+		if err != nil {
+			klog.V(1).Infof("Could not access the kubernetes API, using the kubelet to list pods and the direct controller as pod owner: %v", err)
+		} else {
+			initAPIProviders(client)
+		}
+	}
 	return updateListPodCache("", false)
 }
 
+// initAPIProviders uses the kubernetes API to watch the node pods and resolve the pod owners
+func initAPIProviders(client kubernetes.Interface) {
+	if config.ResolvePodOwner {
+		getter := NewInformerControllerGetter(client)
+		if err := getter.Start(podInformerSyncTimeout); err != nil {
+			klog.V(1).Infof("Could not watch the pod controllers, using the direct controller as pod owner: %v", err)
+			getter.Stop()
+		} else {
+			podOwnerResolver = NewOwnerResolver(getter)
+		}
+	}
+	if !config.EnablePodInformer {
+		return
+	}
+	if config.NodeName == "" {
+		klog.V(1).Infoln("NODE_NAME is not set, using the kubelet to list pods")
+		return
+	}
+	informer := NewPodInformerProvider(client, config.NodeName)
+	if err := informer.Start(podInformerSyncTimeout); err != nil {
+		klog.V(1).Infof("Could not watch the pods, using the kubelet to list pods: %v", err)
+		informer.Stop()
+		return
+	}
+	klog.V(1).Infof("Watching the pods of node %s", config.NodeName)
+	podProvider = &fallbackPodProvider{primary: informer, fallback: &podLister}
+}
+
 func GetPodName(cGroupID, pid uint64, withCGroupID bool) (string, error) {
 	info, err := getContainerInfo(cGroupID, pid, withCGroupID)
 	return info.PodName, err
@@ -90,6 +155,11 @@ func GetContainerID(cGroupID, pid uint64, withCGroupID bool) (string, error) {
 	return info.ContainerID, err
 }
 
+// GetContainerInfo returns the kubernetes metadata of the container running the given process or cgroup
+func GetContainerInfo(cGroupID, pid uint64, withCGroupID bool) (*ContainerInfo, error) {
+	return getContainerInfo(cGroupID, pid, withCGroupID)
+}
+
 func GetContainerMetrics() (containerCPU, containerMem map[string]float64, nodeCPU, nodeMem float64, retErr error) {
 	return podLister.ListMetrics()
 }
@@ -101,45 +171,82 @@ func GetAvailableKubeletMetrics() []string {
 func getContainerInfo(cGroupID, pid uint64, withCGroupID bool) (*ContainerInfo, error) {
 	var err error
 	var containerID string
-	info := &ContainerInfo{
+	info := newSystemProcessInfo()
+
+	if containerID, err = getContainerIDFromPath(cGroupID, pid, withCGroupID); err != nil {
+		return info, err
+	}
+	return getContainerInfoByID(containerID, info), nil
+}
+
+// GetContainerInfoByID returns the metadata of a container found without process, e.g. when walking the cgroups.
+// An unknown container is not cached as a system process, it is looked up again after unknownContainerRetryInterval
+// since its pod might not be listed yet.
+func GetContainerInfoByID(containerID string) *ContainerInfo {
+	now := time.Now()
+	if retryAt, ok := unknownContainerIDs[containerID]; ok && now.Before(retryAt) {
+		return newSystemProcessInfo()
+	}
+	if info, found := findContainerInfo(containerID); found {
+		delete(unknownContainerIDs, containerID)
+		return info
+	}
+	unknownContainerIDs[containerID] = now.Add(unknownContainerRetryInterval)
+	return newSystemProcessInfo()
+}
+
+// newSystemProcessInfo returns the metadata of the processes that are not in a container
+func newSystemProcessInfo() *ContainerInfo {
+	return &ContainerInfo{
 		ContainerID:   utils.SystemProcessName,
 		ContainerName: utils.SystemProcessName,
 		PodName:       utils.SystemProcessName,
 		Namespace:     utils.SystemProcessNamespace,
 	}
+}
 
-	if containerID, err = getContainerIDFromPath(cGroupID, pid, withCGroupID); err != nil {
-		return info, err
-	}
-
+// findContainerInfo looks the container up in the cache, the pod list and the container runtime
+func findContainerInfo(containerID string) (*ContainerInfo, bool) {
 	if i, ok := containerIDToContainerInfo[containerID]; ok {
-		return i, nil
+		return i, true
 	}
 
 	// update cache info and stop loop if container id found
 	_, _ = updateListPodCache(containerID, true)
 	if cinfo, ok := containerIDToContainerInfo[containerID]; ok {
-		return cinfo, nil
+		return cinfo, true
 	}
 
-	if _, ok := containerIDToContainerInfo[containerID]; !ok {
-		containerIDToContainerInfo[containerID] = info
-		// some system process might have container ID, but we need to replace it if the container is not a kubernetes container
-		if info.ContainerName == utils.SystemProcessName {
-			containerID = utils.SystemProcessName
-			// in addition to the system container ID, add also the system process name to the cache
-			if _, ok := containerIDToContainerInfo[containerID]; !ok {
-				containerIDToContainerInfo[containerID] = info
-			}
+	// the container is not in the kubelet pod list, e.g. the host is not a kubernetes node
+	if cinfo, err := getContainerInfoFromRuntime(containerID); err == nil {
+		containerIDToContainerInfo[containerID] = cinfo
+		return cinfo, true
+	}
+	return nil, false
+}
+
+// getContainerInfoByID returns the cached metadata of the container, or info if the container is unknown
+func getContainerInfoByID(containerID string, info *ContainerInfo) *ContainerInfo {
+	if cinfo, found := findContainerInfo(containerID); found {
+		return cinfo
+	}
+
+	containerIDToContainerInfo[containerID] = info
+	// some system process might have container ID, but we need to replace it if the container is not a kubernetes container
+	if info.ContainerName == utils.SystemProcessName {
+		containerID = utils.SystemProcessName
+		// in addition to the system container ID, add also the system process name to the cache
+		if _, ok := containerIDToContainerInfo[containerID]; !ok {
+			containerIDToContainerInfo[containerID] = info
 		}
 	}
-	return containerIDToContainerInfo[containerID], nil
+	return containerIDToContainerInfo[containerID]
 }
 
 // updateListPodCache updates cache info with all pods and optionally
 // stops the loop when a given container ID is found
 func updateListPodCache(targetContainerID string, stopWhenFound bool) (*[]corev1.Pod, error) {
-	pods, err := podLister.ListPods()
+	pods, err := podProvider.ListPods()
 	if err != nil {
 		klog.V(4).Infof("%v", err)
 		return pods, err
@@ -148,12 +255,7 @@ func updateListPodCache(targetContainerID string, stopWhenFound bool) (*[]corev1
 		containers := (*pods)[i].Status.ContainerStatuses
 		for j := 0; j < len(containers); j++ {
 			containerID := ParseContainerIDFromPodStatus(containers[j].ContainerID)
-			containerIDToContainerInfo[containerID] = &ContainerInfo{
-				ContainerID:   containerID,
-				ContainerName: containers[j].Name,
-				PodName:       (*pods)[i].Name,
-				Namespace:     (*pods)[i].Namespace,
-			}
+			containerIDToContainerInfo[containerID] = NewContainerInfo(containerID, containers[j].Name, &(*pods)[i])
 			if stopWhenFound && containers[j].ContainerID == targetContainerID {
 				return pods, err
 			}
@@ -161,12 +263,7 @@ func updateListPodCache(targetContainerID string, stopWhenFound bool) (*[]corev1
 		containers = (*pods)[i].Status.InitContainerStatuses
 		for j := 0; j < len(containers); j++ {
 			containerID := ParseContainerIDFromPodStatus(containers[j].ContainerID)
-			containerIDToContainerInfo[containerID] = &ContainerInfo{
-				ContainerID:   containerID,
-				ContainerName: containers[j].Name,
-				PodName:       (*pods)[i].Name,
-				Namespace:     (*pods)[i].Namespace,
-			}
+			containerIDToContainerInfo[containerID] = NewContainerInfo(containerID, containers[j].Name, &(*pods)[i])
 			if stopWhenFound && containers[j].ContainerID == targetContainerID {
 				return pods, err
 			}
@@ -174,12 +271,7 @@ func updateListPodCache(targetContainerID string, stopWhenFound bool) (*[]corev1
 		containers = (*pods)[i].Status.EphemeralContainerStatuses
 		for j := 0; j < len(containers); j++ {
 			containerID := ParseContainerIDFromPodStatus(containers[j].ContainerID)
-			containerIDToContainerInfo[containerID] = &ContainerInfo{
-				ContainerID:   containerID,
-				ContainerName: containers[j].Name,
-				PodName:       (*pods)[i].Name,
-				Namespace:     (*pods)[i].Namespace,
-			}
+			containerIDToContainerInfo[containerID] = NewContainerInfo(containerID, containers[j].Name, &(*pods)[i])
 			if stopWhenFound && containers[j].ContainerID == targetContainerID {
 				return pods, err
 			}
@@ -188,6 +280,42 @@ func updateListPodCache(targetContainerID string, stopWhenFound bool) (*[]corev1
 	return pods, err
 }
 
+// NewContainerInfo creates the ContainerInfo of a container running in the given pod
+func NewContainerInfo(containerID, containerName string, pod *corev1.Pod) *ContainerInfo {
+	info := &ContainerInfo{
+		ContainerID:   containerID,
+		ContainerName: containerName,
+		PodName:       pod.Name,
+		Namespace:     pod.Namespace,
+		PodUID:        string(pod.UID),
+		QOSClass:      string(pod.Status.QOSClass),
+	}
+	info.OwnerKind, info.OwnerName = podOwnerResolver.GetPodOwner(pod)
+	info.PodMetadataLabels = getPodMetadataLabelValues(pod)
+	resources := getContainerResources(pod, containerName)
+	info.CPURequest = uint64(resources.Requests.Cpu().MilliValue())
+	info.MemoryRequest = uint64(resources.Requests.Memory().Value())
+	info.CPULimit = uint64(resources.Limits.Cpu().MilliValue())
+	info.MemoryLimit = uint64(resources.Limits.Memory().Value())
+	return info
+}
+
+// getContainerResources returns the resource requests and limits of a container in the pod spec
+func getContainerResources(pod *corev1.Pod, containerName string) corev1.ResourceRequirements {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			return pod.Spec.Containers[i].Resources
+		}
+	}
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == containerName {
+			return pod.Spec.InitContainers[i].Resources
+		}
+	}
+	// the ephemeral containers have no resources
+	return corev1.ResourceRequirements{}
+}
+
 func ParseContainerIDFromPodStatus(containerID string) string {
 	return regexReplaceContainerIDPrefix.ReplaceAllString(containerID, "")
 }
@@ -236,7 +364,7 @@ func getPathFromPID(searchPath string, pid uint64) (string, error) {
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if strings.Contains(line, "pod") || strings.Contains(line, "containerd") || strings.Contains(line, "crio") {
+		if strings.Contains(line, "pod") || strings.Contains(line, "containerd") || strings.Contains(line, "crio") || strings.Contains(line, "docker") {
 			return line, nil
 		}
 	}
@@ -302,7 +430,7 @@ func extractPodContainerIDfromPath(path string) (string, error) {
 			if cgroup == 2 && (strings.Contains(element, "-conmon-") || strings.Contains(element, ".service")) {
 				return "", fmt.Errorf("process is not in a kubernetes pod")
 				// TODO: we need to extend this to include other runtimes
-			} else if strings.Contains(element, "crio") || strings.Contains(element, "docker") || strings.Contains(element, "containerd") {
+			} else if strings.Contains(element, "crio") || strings.Contains(element, "docker") || strings.Contains(element, "containerd") || strings.Contains(element, "libpod") {
 				containerID := regexReplaceContainerIDPathPrefix.ReplaceAllString(element, "")
 				containerID = regexReplaceContainerIDPathSufix.ReplaceAllString(containerID, "")
 				return containerID, nil
@@ -345,10 +473,36 @@ func getAliveContainers(pods *[]corev1.Pod) (map[string]bool, error) {
 
 // GetAliveContainers returns alive pod map
 func GetAliveContainers() (map[string]bool, error) {
-	pods, err := podLister.ListPods()
+	pods, err := podProvider.ListPods()
 	if err != nil {
-		return nil, err
+		if !HasContainerRuntimeResolver() {
+			return nil, err
+		}
+		pods = &[]corev1.Pod{}
 	}
 
-	return getAliveContainers(pods)
+	aliveContainers, err := getAliveContainers(pods)
+	if err != nil {
+		return nil, err
+	}
+	// the unknown containers whose retry time is over are looked up again anyway, forget them in case their cgroup was removed
+	now := time.Now()
+	for containerID, retryAt := range unknownContainerIDs {
+		if !now.Before(retryAt) {
+			delete(unknownContainerIDs, containerID)
+		}
+	}
+	// the containers resolved from the container runtime are alive as long as the runtime knows them,
+	// the runtime is asked at most once per runtimeCacheTTL for each container
+	for containerID, info := range containerIDToContainerInfo {
+		if info.Image == "" || aliveContainers[containerID] {
+			continue
+		}
+		if _, err := getContainerInfoFromRuntime(containerID); err == nil {
+			aliveContainers[containerID] = true
+		} else {
+			delete(containerIDToContainerInfo, containerID)
+		}
+	}
+	return aliveContainers, nil
 }
@@ -18,10 +18,15 @@ package cgroup
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/sustainable-computing-io/kepler/pkg/utils"
 )
 
 var init1Status = []corev1.ContainerStatus{
@@ -122,3 +127,115 @@ func TestGetAliveContainers(t *testing.T) {
 		})
 	}
 }
+
+func TestNewContainerInfo(t *testing.T) {
+	g := NewWithT(t)
+
+	isController := true
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-7c9f8-x2x4q",
+			Namespace: "default",
+			UID:       "d3b07384-d9a0-4c0b-9b1e-0e3e6a8b1c2d",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "web-7c9f8", Controller: &isController},
+			},
+		},
+		Status: corev1.PodStatus{
+			QOSClass: corev1.PodQOSBurstable,
+		},
+	}
+	podOwnerResolver = NewOwnerResolver(&fakeControllerGetter{
+		owners: map[string]*metav1.OwnerReference{"ReplicaSet/default/web-7c9f8": {Kind: "Deployment", Name: "web"}},
+	})
+	defer func() { podOwnerResolver = NewOwnerResolver(nil) }()
+	info := NewContainerInfo("c1", "nginx", &pod)
+	g.Expect(*info).To(Equal(ContainerInfo{
+		ContainerID:   "c1",
+		ContainerName: "nginx",
+		PodName:       "web-7c9f8-x2x4q",
+		Namespace:     "default",
+		PodUID:        "d3b07384-d9a0-4c0b-9b1e-0e3e6a8b1c2d",
+		QOSClass:      "Burstable",
+		OwnerKind:     "Deployment",
+		OwnerName:     "web",
+
+		PodMetadataLabels: []string{},
+	}))
+
+	// pods without a controller, e.g. static pods, have no owner
+	pod.OwnerReferences = nil
+	info = NewContainerInfo("c1", "nginx", &pod)
+	g.Expect(info.OwnerKind).To(BeEmpty())
+	g.Expect(info.OwnerName).To(BeEmpty())
+}
+
+func TestNewContainerInfoResources(t *testing.T) {
+	g := NewWithT(t)
+
+	pod := corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{
+				Name: "init",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				},
+			}},
+			Containers: []corev1.Container{{
+				Name: "nginx",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+			}, {
+				Name: "sidecar",
+			}},
+		},
+	}
+	info := NewContainerInfo("c1", "nginx", &pod)
+	g.Expect(info.CPURequest).To(Equal(uint64(500)))
+	g.Expect(info.MemoryRequest).To(Equal(uint64(128 << 20)))
+	g.Expect(info.CPULimit).To(Equal(uint64(2000)))
+	g.Expect(info.MemoryLimit).To(Equal(uint64(256 << 20)))
+
+	info = NewContainerInfo("c2", "init", &pod)
+	g.Expect(info.CPURequest).To(Equal(uint64(100)))
+	g.Expect(info.CPULimit).To(BeZero())
+
+	// best effort containers have no requests nor limits
+	info = NewContainerInfo("c3", "sidecar", &pod)
+	g.Expect(info.CPURequest).To(BeZero())
+	g.Expect(info.MemoryRequest).To(BeZero())
+}
+
+func TestGetContainerInfoByIDRetriesUnknownContainers(t *testing.T) {
+	g := NewWithT(t)
+
+	provider := &fakePodProvider{}
+	savedProvider := podProvider
+	podProvider = provider
+	defer func() {
+		podProvider = savedProvider
+		delete(containerIDToContainerInfo, "d1")
+		delete(unknownContainerIDs, "d1")
+	}()
+
+	// the pod of the discovered container is not listed yet
+	info := GetContainerInfoByID("d1")
+	g.Expect(info.ContainerName).To(Equal(utils.SystemProcessName))
+	g.Expect(containerIDToContainerInfo).NotTo(HaveKey("d1"))
+
+	// the container is not looked up again before the retry interval
+	provider.pods = []corev1.Pod{*newNodePod("podD", "node-a", "d1")}
+	calls := provider.calls
+	info = GetContainerInfoByID("d1")
+	g.Expect(info.ContainerName).To(Equal(utils.SystemProcessName))
+	g.Expect(provider.calls).To(Equal(calls))
+
+	// the container is found once the retry interval is over
+	unknownContainerIDs["d1"] = time.Now()
+	info = GetContainerInfoByID("d1")
+	g.Expect(info.PodName).To(Equal("podD"))
+	g.Expect(info.ContainerName).To(Equal("app"))
+	g.Expect(unknownContainerIDs).NotTo(HaveKey("d1"))
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// rootSlice is the systemd name of the root cgroup, e.g. for the kernel threads
+	rootSlice   = "-.slice"
+	sliceSuffix = ".slice"
+)
+
+var (
+	// systemdUnitSuffixes are the systemd unit types that own processes
+	systemdUnitSuffixes = []string{".service", scopeSuffix, ".socket", ".mount", ".swap"}
+	// systemdCgroupRoots are the mount points of the cgroup hierarchy managed by systemd, with cgroup v2 and v1
+	systemdCgroupRoots = []string{cgroupPath, filepath.Join(cgroupPath, "systemd")}
+)
+
+// SystemdUnit identifies the systemd unit running a process and the slice containing the unit
+type SystemdUnit struct {
+	Unit  string
+	Slice string
+	// Path is the cgroup path of the unit, e.g. /system.slice/sshd.service
+	Path string
+}
+
+// GetSystemdUnit returns the systemd unit of the process from its cgroup path
+func GetSystemdUnit(pid uint64) (SystemdUnit, error) {
+	path, err := getSystemdCgroupPathFromPID(procPath, pid)
+	if err != nil {
+		return SystemdUnit{}, err
+	}
+	return ParseSystemdUnit(path), nil
+}
+
+// getSystemdCgroupPathFromPID returns the cgroup path of the process in the unified hierarchy,
+// or in the systemd named hierarchy with cgroup v1
+func getSystemdCgroupPathFromPID(searchPath string, pid uint64) (string, error) {
+	path := fmt.Sprintf(searchPath, pid)
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open cgroup description file for pid %d: %v", pid, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// each line is hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" || fields[1] == "name=systemd" {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("could not find the systemd cgroup of pid %d", pid)
+}
+
+// ParseSystemdUnit returns the innermost systemd unit and slice of a cgroup path, e.g.
+// /system.slice/sshd.service is the unit sshd.service in system.slice and
+// /user.slice/user-1000.slice/session-2.scope is the unit session-2.scope in user-1000.slice.
+// Processes outside of any unit, e.g. the kernel threads, are assigned to the root slice.
+func ParseSystemdUnit(path string) SystemdUnit {
+	unit := SystemdUnit{Unit: rootSlice, Slice: rootSlice, Path: "/"}
+	current := ""
+	for _, element := range strings.Split(path, "/") {
+		if element == "" {
+			continue
+		}
+		current += "/" + element
+		if strings.HasSuffix(element, sliceSuffix) {
+			unit.Slice = element
+			// a process directly in a slice is accounted to the slice
+			unit.Unit = element
+			unit.Path = current
+			continue
+		}
+		if isSystemdUnit(element) {
+			unit.Unit = element
+			unit.Path = current
+		}
+	}
+	return unit
+}
+
+// SystemdUnitExists returns true if the cgroup of the unit still exists, systemd removes it when the unit is stopped
+func SystemdUnitExists(path string) bool {
+	for _, root := range systemdCgroupRoots {
+		if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isSystemdUnit(name string) bool {
+	for _, suffix := range systemdUnitSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseSystemdUnit(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		path     string
+		expected SystemdUnit
+	}{
+		{"/system.slice/postgresql.service", SystemdUnit{Unit: "postgresql.service", Slice: "system.slice", Path: "/system.slice/postgresql.service"}},
+		// the sub cgroups created by a service are accounted to the service
+		{"/system.slice/containerd.service/payload", SystemdUnit{Unit: "containerd.service", Slice: "system.slice", Path: "/system.slice/containerd.service"}},
+		{"/user.slice/user-1000.slice/session-2.scope", SystemdUnit{Unit: "session-2.scope", Slice: "user-1000.slice", Path: "/user.slice/user-1000.slice/session-2.scope"}},
+		{"/user.slice/user-1000.slice/user@1000.service/app.slice/dbus.service", SystemdUnit{Unit: "dbus.service", Slice: "app.slice",
+			Path: "/user.slice/user-1000.slice/user@1000.service/app.slice/dbus.service"}},
+		{"/init.scope", SystemdUnit{Unit: "init.scope", Slice: "-.slice", Path: "/init.scope"}},
+		{"/machine.slice", SystemdUnit{Unit: "machine.slice", Slice: "machine.slice", Path: "/machine.slice"}},
+		{"/", SystemdUnit{Unit: "-.slice", Slice: "-.slice", Path: "/"}},
+	}
+	for _, testcase := range testcases {
+		g.Expect(ParseSystemdUnit(testcase.path)).To(Equal(testcase.expected), testcase.path)
+	}
+}
+
+func TestGetSystemdCgroupPathFromPID(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	cgroupV2 := "0::/system.slice/sshd.service\n"
+	cgroupV1 := "12:cpu,cpuacct:/system.slice/mysqld.service\n1:name=systemd:/system.slice/mysqld.service\n"
+	g.Expect(os.WriteFile(filepath.Join(dir, "1"), []byte(cgroupV2), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "2"), []byte(cgroupV1), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "3"), []byte("12:cpu:/\n"), 0o600)).To(Succeed())
+	searchPath := filepath.Join(dir, "%d")
+
+	path, err := getSystemdCgroupPathFromPID(searchPath, 1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(path).To(Equal("/system.slice/sshd.service"))
+	path, err = getSystemdCgroupPathFromPID(searchPath, 2)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(path).To(Equal("/system.slice/mysqld.service"))
+	_, err = getSystemdCgroupPathFromPID(searchPath, 3)
+	g.Expect(err).To(HaveOccurred())
+	_, err = getSystemdCgroupPathFromPID(searchPath, 4)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSystemdUnitExists(t *testing.T) {
+	g := NewWithT(t)
+	roots := systemdCgroupRoots
+	defer func() { systemdCgroupRoots = roots }()
+
+	v2, v1 := t.TempDir(), t.TempDir()
+	systemdCgroupRoots = []string{v2, v1}
+	g.Expect(os.MkdirAll(filepath.Join(v2, "system.slice", "sshd.service"), 0o755)).To(Succeed())
+	g.Expect(os.MkdirAll(filepath.Join(v1, "system.slice", "mysqld.service"), 0o755)).To(Succeed())
+
+	g.Expect(SystemdUnitExists("/system.slice/sshd.service")).To(BeTrue())
+	g.Expect(SystemdUnitExists("/system.slice/mysqld.service")).To(BeTrue())
+	g.Expect(SystemdUnitExists("/system.slice/stopped.service")).To(BeFalse())
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// libvirt runs each QEMU domain in the scope machine-qemu\x2d<id>\x2d<name>.scope of machine.slice
+	qemuScopePrefix = "machine-qemu-"
+)
+
+var vmResolver = NewVMResolver(config.LibvirtDomainXMLPath)
+
+// GetVMInfo returns the virtual machine running in the systemd unit, or nil if the unit is not a QEMU scope
+func GetVMInfo(unit string) *VMInfo {
+	return vmResolver.GetVMInfo(unit)
+}
+
+// RetainVMs removes the cached virtual machines of the units that do not exist anymore
+func RetainVMs(units map[string]bool) {
+	vmResolver.Retain(units)
+}
+
+// VMInfo identifies the virtual machine running a process
+type VMInfo struct {
+	// ID is the libvirt domain id, which changes when the domain is restarted
+	ID   string
+	Name string
+	// UUID is only set when the libvirt domain XML is found
+	UUID string
+}
+
+// libvirtDomain is the subset of the libvirt domain XML used by kepler
+type libvirtDomain struct {
+	Name string `xml:"name"`
+	UUID string `xml:"uuid"`
+}
+
+// libvirtDomainStatus is the runtime status XML written by libvirt in /run/libvirt/qemu, which wraps the domain XML
+type libvirtDomainStatus struct {
+	Domain libvirtDomain `xml:"domain"`
+}
+
+// VMResolver maps the systemd scope of the QEMU processes to the virtual machine
+type VMResolver struct {
+	// domainXMLPath is the directory with the libvirt domain XML files named <name>.xml, empty to skip the UUID lookup
+	domainXMLPath string
+	cache         map[string]*VMInfo
+	mu            sync.Mutex
+}
+
+// NewVMResolver creates a VMResolver reading the libvirt domain XML files in domainXMLPath
+func NewVMResolver(domainXMLPath string) *VMResolver {
+	return &VMResolver{
+		domainXMLPath: domainXMLPath,
+		cache:         map[string]*VMInfo{},
+	}
+}
+
+// GetVMInfo returns the virtual machine of a systemd unit, or nil if the unit is not a QEMU scope
+func (r *VMResolver) GetVMInfo(unit string) *VMInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if info, found := r.cache[unit]; found {
+		return info
+	}
+	info := ParseQEMUScope(unit)
+	if info != nil && r.domainXMLPath != "" {
+		if domain := r.getDomain(info.Name); domain != nil {
+			// the name in the scope is truncated by libvirt for long names
+			if domain.Name != "" {
+				info.Name = domain.Name
+			}
+			info.UUID = domain.UUID
+		}
+	}
+	// the units that are not VMs are also cached to avoid parsing them again
+	r.cache[unit] = info
+	return info
+}
+
+// Retain removes the cached information of the units that are not in units
+func (r *VMResolver) Retain(units map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for unit := range r.cache {
+		if !units[unit] {
+			delete(r.cache, unit)
+		}
+	}
+}
+
+// getDomain reads the libvirt domain XML of the VM, or of the only domain starting with name if the name was truncated
+func (r *VMResolver) getDomain(name string) *libvirtDomain {
+	path := filepath.Join(r.domainXMLPath, name+".xml")
+	if _, err := os.Stat(path); err != nil {
+		matches, globErr := filepath.Glob(filepath.Join(r.domainXMLPath, name+"*.xml"))
+		if globErr != nil || len(matches) != 1 {
+			klog.V(5).Infof("failed to find the libvirt domain of VM %s: %v", name, err)
+			return nil
+		}
+		path = matches[0]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		klog.V(5).Infof("failed to read the libvirt domain of VM %s: %v", name, err)
+		return nil
+	}
+	var status libvirtDomainStatus
+	if err := xml.Unmarshal(data, &status); err == nil && status.Domain.UUID != "" {
+		return &status.Domain
+	}
+	var domain libvirtDomain
+	if err := xml.Unmarshal(data, &domain); err != nil {
+		klog.V(5).Infof("failed to parse the libvirt domain of VM %s: %v", name, err)
+		return nil
+	}
+	return &domain
+}
+
+// ParseQEMUScope returns the virtual machine of a libvirt QEMU scope, e.g. machine-qemu\x2d1\x2ddb\x2d01.scope is the VM db-01 with id 1.
+// It returns nil if the unit is not a QEMU scope.
+func ParseQEMUScope(unit string) *VMInfo {
+	if !strings.HasSuffix(unit, scopeSuffix) {
+		return nil
+	}
+	name := unescapeSystemdUnit(strings.TrimSuffix(unit, scopeSuffix))
+	if !strings.HasPrefix(name, qemuScopePrefix) {
+		return nil
+	}
+	idAndName := strings.SplitN(strings.TrimPrefix(name, qemuScopePrefix), "-", 2)
+	if len(idAndName) != 2 || idAndName[1] == "" {
+		return nil
+	}
+	if _, err := strconv.Atoi(idAndName[0]); err != nil {
+		return nil
+	}
+	return &VMInfo{ID: idAndName[0], Name: idAndName[1]}
+}
+
+// unescapeSystemdUnit decodes the \xNN sequences used by systemd to escape the unit names
+func unescapeSystemdUnit(name string) string {
+	if !strings.Contains(name, `\x`) {
+		return name
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) && name[i+1] == 'x' {
+			if b, err := strconv.ParseUint(name[i+2:i+4], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
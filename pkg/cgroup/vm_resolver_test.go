@@ -0,0 +1,62 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseQEMUScope(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ParseQEMUScope(`machine-qemu\x2d1\x2ddb\x2d01.scope`)).To(Equal(&VMInfo{ID: "1", Name: "db-01"}))
+	g.Expect(ParseQEMUScope(`machine-qemu\x2d12\x2dweb.scope`)).To(Equal(&VMInfo{ID: "12", Name: "web"}))
+	g.Expect(ParseQEMUScope("sshd.service")).To(BeNil())
+	g.Expect(ParseQEMUScope("session-2.scope")).To(BeNil())
+	g.Expect(ParseQEMUScope(`machine-qemu\x2dweb.scope`)).To(BeNil())
+	g.Expect(ParseQEMUScope(`machine-qemu\x2d1\x2d.scope`)).To(BeNil())
+}
+
+func TestVMResolver(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	// the runtime status XML of libvirt wraps the domain XML
+	status := `<domstatus state="running" pid="1234"><domain type="kvm" id="1"><name>db-01</name><uuid>6c0e3f2a-1b2c-4d5e-8f90-1234567890ab</uuid></domain></domstatus>`
+	domain := `<domain type="kvm"><name>a-very-long-virtual-machine-name</name><uuid>0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0</uuid></domain>`
+	g.Expect(os.WriteFile(filepath.Join(dir, "db-01.xml"), []byte(status), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "a-very-long-virtual-machine-name.xml"), []byte(domain), 0o600)).To(Succeed())
+	resolver := NewVMResolver(dir)
+
+	info := resolver.GetVMInfo(`machine-qemu\x2d1\x2ddb\x2d01.scope`)
+	g.Expect(info).To(Equal(&VMInfo{ID: "1", Name: "db-01", UUID: "6c0e3f2a-1b2c-4d5e-8f90-1234567890ab"}))
+	// libvirt truncates the long names in the scope name
+	info = resolver.GetVMInfo(`machine-qemu\x2d2\x2da\x2dvery\x2dlong\x2dvirt.scope`)
+	g.Expect(info).To(Equal(&VMInfo{ID: "2", Name: "a-very-long-virtual-machine-name", UUID: "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"}))
+	// the VMs without domain XML are identified by name
+	info = resolver.GetVMInfo(`machine-qemu\x2d3\x2dtest.scope`)
+	g.Expect(info).To(Equal(&VMInfo{ID: "3", Name: "test"}))
+	g.Expect(resolver.GetVMInfo("sshd.service")).To(BeNil())
+	g.Expect(resolver.cache).To(HaveLen(4))
+
+	resolver.Retain(map[string]bool{`machine-qemu\x2d1\x2ddb\x2d01.scope`: true})
+	g.Expect(resolver.cache).To(HaveLen(1))
+}
@@ -23,6 +23,39 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// getDiscoveredContainerInfo is replaced in the tests, which have no pod list nor container runtime
+var getDiscoveredContainerInfo = cgroup.GetContainerInfoByID
+
+// updateCgroupDiscovery adds the containers found in the cgroup hierarchy and removes the containers whose cgroup was deleted,
+// it replaces the bpf container discovery when the bpf programs cannot be attached
+func (c *Collector) updateCgroupDiscovery() {
+	containerIDs, err := cgroup.DiscoverContainerIDs()
+	if err != nil {
+		klog.V(5).Infof("failed to discover the containers from the cgroups: %v", err)
+		return
+	}
+	for containerID := range containerIDs {
+		if _, found := c.ContainersMetrics[containerID]; found {
+			continue
+		}
+		info := getDiscoveredContainerInfo(containerID)
+		// the cgroups that are neither in the pod list nor in the container runtime are left to the system processes
+		if info.ContainerName == c.systemProcessName {
+			continue
+		}
+		c.ContainersMetrics[containerID] = collector_metric.NewContainerMetrics(info.ContainerName, info.PodName, info.Namespace)
+		setContainerPodInfo(c.ContainersMetrics[containerID], info)
+	}
+	for containerID := range c.ContainersMetrics {
+		if containerID == c.systemProcessName {
+			continue
+		}
+		if !containerIDs[containerID] {
+			delete(c.ContainersMetrics, containerID)
+		}
+	}
+}
+
 // updateCgroupMetrics adds container-level cgroup data
 func (c *Collector) updateCgroupMetrics() {
 	klog.V(5).Infof("overall cgroup stats %v", cgroup.SliceHandlerInstance)
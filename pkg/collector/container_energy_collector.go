@@ -17,10 +17,37 @@ limitations under the License.
 package collector
 
 import (
+	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
 	"github.com/sustainable-computing-io/kepler/pkg/model"
+	"k8s.io/klog/v2"
 )
 
 // updateContainerEnergy matches the container resource usage with the node energy consumption
 func (c *Collector) updateContainerEnergy() {
 	model.UpdateContainerEnergy(c.ContainersMetrics, &c.NodeMetrics)
 }
+
+// updatePodEnergy aggregates the container energy per pod.
+// A pod is removed when none of its containers is in the ContainersMetrics anymore.
+// The system processes are not in a pod, so they are not aggregated.
+func (c *Collector) updatePodEnergy() {
+	alivePods := map[string]bool{}
+	for containerID, container := range c.ContainersMetrics {
+		if containerID == c.systemProcessName {
+			continue
+		}
+		podKey := collector_metric.PodKey(container)
+		alivePods[podKey] = true
+		if _, ok := c.PodsMetrics[podKey]; !ok {
+			c.PodsMetrics[podKey] = collector_metric.NewPodMetrics(container)
+		}
+		if err := c.PodsMetrics[podKey].AddContainerEnergy(container); err != nil {
+			klog.V(5).Infof("failed to add the energy of container %s to pod %s: %v", container.ContainerName, podKey, err)
+		}
+	}
+	for podKey := range c.PodsMetrics {
+		if !alivePods[podKey] {
+			delete(c.PodsMetrics, podKey)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package collector
 import (
 	"bytes"
 	"encoding/binary"
+	"strconv"
 
 	"unsafe"
 
@@ -36,19 +37,36 @@ import "C"
 
 // TODO in sync with bpf program
 type ProcessBPFMetrics struct {
-	CGroupID       uint64
-	PID            uint64
-	ProcessRunTime uint64
-	CPUCycles      uint64
-	CPUInstr       uint64
-	CacheMisses    uint64
-	VecNR          [config.MaxIRQ]uint16 // irq counter, 10 is the max number of irq vectors
-	Command        [16]byte
+	CGroupID               uint64
+	PID                    uint64
+	ProcessRunTime         uint64
+	CPUCycles              uint64
+	CPUInstr               uint64
+	CacheMisses            uint64
+	VoluntaryCtxSwitches   uint64
+	InvoluntaryCtxSwitches uint64
+	CPUMigrations          uint64
+	PageFaultsMajor        uint64
+	PageFaultsMinor        uint64
+	NetTxBytes             uint64
+	NetRxBytes             uint64
+	BlockReadBytes         uint64
+	BlockWriteBytes        uint64
+	HWCounters             [attacher.MaxHWCounters]uint64 // selected hardware counters, indexed by their slot
+	VecNR                  [config.MaxIRQ]uint16          // irq counter, 10 is the max number of irq vectors
+	Command                [16]byte
+}
+
+// ProcessCPUTimeKey is the key of the per cpu process time BPF table
+type ProcessCPUTimeKey struct {
+	PID uint32
+	CPU uint32
 }
 
 // resetBPFTables reset BPF module's tables
 func (c *Collector) resetBPFTables() {
 	c.bpfHCMeter.Table.DeleteAll()
+	c.bpfHCMeter.CPUTimeTable.DeleteAll()
 }
 
 // updateBasicBPF
@@ -64,6 +82,13 @@ func (c *Collector) updateBasicBPF(containerID string, ct *ProcessBPFMetrics, is
 			klog.V(5).Infoln(err)
 		}
 	}
+	// update task events
+	for _, counterKey := range collector_metric.AvailableBPFStatCounters {
+		val := getBPFStatValue(ct, counterKey)
+		if err := c.ContainersMetrics[containerID].BPFStats[counterKey].AddNewDelta(val); err != nil {
+			klog.V(5).Infoln(err)
+		}
+	}
 	// track system process metrics
 	if isSystemProcess && config.EnableProcessMetrics {
 		for i := 0; i < config.MaxIRQ; i++ {
@@ -71,6 +96,37 @@ func (c *Collector) updateBasicBPF(containerID string, ct *ProcessBPFMetrics, is
 				klog.V(5).Infoln(err)
 			}
 		}
+		for _, counterKey := range collector_metric.AvailableBPFStatCounters {
+			if err := c.ProcessMetrics[ct.PID].BPFStats[counterKey].AddNewDelta(getBPFStatValue(ct, counterKey)); err != nil {
+				klog.V(5).Infoln(err)
+			}
+		}
+	}
+}
+
+// getBPFStatValue returns the value of a task event or I/O counter in the BPF metrics of a process
+func getBPFStatValue(ct *ProcessBPFMetrics, counterKey string) uint64 {
+	switch counterKey {
+	case attacher.VoluntaryCtxSwitchLabel:
+		return ct.VoluntaryCtxSwitches
+	case attacher.InvoluntaryCtxSwitchLabel:
+		return ct.InvoluntaryCtxSwitches
+	case attacher.CPUMigrationLabel:
+		return ct.CPUMigrations
+	case attacher.PageFaultMajorLabel:
+		return ct.PageFaultsMajor
+	case attacher.PageFaultMinorLabel:
+		return ct.PageFaultsMinor
+	case attacher.NetTxBytesLabel:
+		return ct.NetTxBytes
+	case attacher.NetRxBytesLabel:
+		return ct.NetRxBytes
+	case attacher.BlockReadBytesLabel:
+		return ct.BlockReadBytes
+	case attacher.BlockWriteBytesLabel:
+		return ct.BlockWriteBytes
+	default:
+		return 0
 	}
 }
 
@@ -87,7 +143,9 @@ func (c *Collector) updateHWCounters(containerID string, ct *ProcessBPFMetrics,
 		case attacher.CacheMissLabel:
 			val = ct.CacheMisses
 		default:
-			val = 0
+			if slot, found := attacher.GetHWCounterSlot(counterKey); found {
+				val = ct.HWCounters[slot]
+			}
 		}
 		if err := c.ContainersMetrics[containerID].CounterStats[counterKey].AddNewDelta(val); err != nil {
 			klog.V(5).Infoln(err)
@@ -108,6 +166,7 @@ func (c *Collector) updateBPFMetrics() {
 	}
 	foundContainer := make(map[string]bool)
 	foundProcess := make(map[uint64]bool)
+	processContainer := make(map[uint64]string)
 	var ct ProcessBPFMetrics
 	for it := c.bpfHCMeter.Table.Iter(); it.Next(); {
 		data := it.Leaf()
@@ -156,10 +215,12 @@ func (c *Collector) updateBPFMetrics() {
 
 		// TODO: improve the removal of deleted containers from ContainersMetrics. Currently we verify the maxInactiveContainers using the foundContainer map
 		foundContainer[containerID] = true
+		processContainer[ct.PID] = containerID
 		if isSystemProcess {
 			foundProcess[ct.PID] = true
 		}
 	}
+	c.updateCPUTimePerCPU(processContainer)
 	c.resetBPFTables()
 	c.handleInactiveContainers(foundContainer)
 	if config.EnableProcessMetrics {
@@ -167,6 +228,39 @@ func (c *Collector) updateBPFMetrics() {
 	}
 }
 
+// updateCPUTimePerCPU reads the BPF table with the CPU time of each process per CPU,
+// the time is also summed per CPU package so that the package energy can be split per socket
+func (c *Collector) updateCPUTimePerCPU(processContainer map[uint64]string) {
+	var key ProcessCPUTimeKey
+	byteOrder := utils.DetermineHostByteOrder()
+	for it := c.bpfHCMeter.CPUTimeTable.Iter(); it.Next(); {
+		if err := binary.Read(bytes.NewBuffer(it.Key()), byteOrder, &key); err != nil {
+			klog.V(5).Infof("failed to decode received data: %v", err)
+			continue
+		}
+		pid := uint64(key.PID)
+		containerID, found := processContainer[pid]
+		if !found {
+			continue
+		}
+		cpu := strconv.Itoa(int(key.CPU))
+		cpuTime := byteOrder.Uint64(it.Leaf())
+		pkg, hasPackage := collector_metric.NodeCPUPackageMap[int32(key.CPU)]
+		c.ContainersMetrics[containerID].CPUTimePerCPU.AddDeltaStat(cpu, cpuTime)
+		if hasPackage {
+			c.ContainersMetrics[containerID].CPUTimePerPackage.AddDeltaStat(pkg, cpuTime)
+		}
+		if containerID == c.systemProcessName && config.EnableProcessMetrics {
+			if _, exist := c.ProcessMetrics[pid]; exist {
+				c.ProcessMetrics[pid].CPUTimePerCPU.AddDeltaStat(cpu, cpuTime)
+				if hasPackage {
+					c.ProcessMetrics[pid].CPUTimePerPackage.AddDeltaStat(pkg, cpuTime)
+				}
+			}
+		}
+	}
+}
+
 // handleInactiveContainers
 func (c *Collector) handleInactiveContainers(foundContainer map[string]bool) {
 	numOfInactive := len(c.ContainersMetrics) - len(foundContainer)
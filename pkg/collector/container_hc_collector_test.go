@@ -1,8 +1,167 @@
 package collector
 
 import (
+	"bytes"
+	"encoding/binary"
+
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
+
+	"github.com/sustainable-computing-io/kepler/pkg/bpfassets/attacher"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/utils"
 )
 
+// fakeBPFTable holds the raw entries of an eBPF map
+type fakeBPFTable struct {
+	keys   [][]byte
+	leaves [][]byte
+}
+
+func (t *fakeBPFTable) put(key, leaf interface{}) {
+	var keyBuf, leafBuf bytes.Buffer
+	Expect(binary.Write(&keyBuf, utils.DetermineHostByteOrder(), key)).To(Succeed())
+	Expect(binary.Write(&leafBuf, utils.DetermineHostByteOrder(), leaf)).To(Succeed())
+	t.keys = append(t.keys, keyBuf.Bytes())
+	t.leaves = append(t.leaves, leafBuf.Bytes())
+}
+
+func (t *fakeBPFTable) Iter() attacher.BpfTableIterator {
+	return &fakeBPFTableIterator{table: t, index: -1}
+}
+
+func (t *fakeBPFTable) DeleteAll() error {
+	t.keys, t.leaves = nil, nil
+	return nil
+}
+
+type fakeBPFTableIterator struct {
+	table *fakeBPFTable
+	index int
+}
+
+func (it *fakeBPFTableIterator) Next() bool {
+	it.index++
+	return it.index < len(it.table.keys)
+}
+
+func (it *fakeBPFTableIterator) Key() []byte {
+	return it.table.keys[it.index]
+}
+
+func (it *fakeBPFTableIterator) Leaf() []byte {
+	return it.table.leaves[it.index]
+}
+
 var _ = Describe("Test hc collector", func() {
+	It("Update the task events of the container and system process", func() {
+		enableProcessMetrics := config.EnableProcessMetrics
+		availableBPFStatCounters := collector_metric.AvailableBPFStatCounters
+		defer func() {
+			config.EnableProcessMetrics = enableProcessMetrics
+			collector_metric.AvailableBPFStatCounters = availableBPFStatCounters
+		}()
+		setCollectorMetrics()
+		config.EnableProcessMetrics = true
+		collector_metric.AvailableBPFStatCounters = attacher.TaskEventCounters
+
+		c := newMockCollector()
+		c.ContainersMetrics[c.systemProcessName] = collector_metric.NewContainerMetrics(c.systemProcessName, c.systemProcessName, c.systemProcessNamespace)
+		c.createProcessMetricsIfNotExist(1, "command")
+		ct := &ProcessBPFMetrics{
+			PID:                    1,
+			VoluntaryCtxSwitches:   10,
+			InvoluntaryCtxSwitches: 5,
+			CPUMigrations:          3,
+			PageFaultsMajor:        2,
+			PageFaultsMinor:        100,
+		}
+		c.updateBasicBPF(c.systemProcessName, ct, true)
+		c.updateBasicBPF(c.systemProcessName, ct, true)
+
+		container := c.ContainersMetrics[c.systemProcessName]
+		Expect(container.BPFStats[attacher.VoluntaryCtxSwitchLabel].Delta).To(Equal(uint64(20)))
+		Expect(container.BPFStats[attacher.InvoluntaryCtxSwitchLabel].Delta).To(Equal(uint64(10)))
+		Expect(container.BPFStats[attacher.CPUMigrationLabel].Delta).To(Equal(uint64(6)))
+		Expect(container.BPFStats[attacher.PageFaultMajorLabel].Delta).To(Equal(uint64(4)))
+		Expect(container.BPFStats[attacher.PageFaultMinorLabel].Aggr).To(Equal(uint64(200)))
+
+		process := c.ProcessMetrics[1]
+		Expect(process.BPFStats[attacher.CPUMigrationLabel].Delta).To(Equal(uint64(6)))
+		Expect(process.BPFStats[attacher.PageFaultMinorLabel].Aggr).To(Equal(uint64(200)))
+		delta, aggr, err := container.GetDeltaAndAggrValue(attacher.VoluntaryCtxSwitchLabel)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal(uint64(20)))
+		Expect(aggr).To(Equal(uint64(20)))
+	})
+	It("Update the I/O bytes of the container and process", func() {
+		enableProcessMetrics := config.EnableProcessMetrics
+		availableBPFStatCounters := collector_metric.AvailableBPFStatCounters
+		defer func() {
+			config.EnableProcessMetrics = enableProcessMetrics
+			collector_metric.AvailableBPFStatCounters = availableBPFStatCounters
+		}()
+		setCollectorMetrics()
+		config.EnableProcessMetrics = true
+		collector_metric.AvailableBPFStatCounters = attacher.BPFStatCounters
+
+		c := newMockCollector()
+		c.ContainersMetrics[c.systemProcessName] = collector_metric.NewContainerMetrics(c.systemProcessName, c.systemProcessName, c.systemProcessNamespace)
+		c.createProcessMetricsIfNotExist(1, "command")
+		ct := &ProcessBPFMetrics{
+			PID:             1,
+			CPUMigrations:   1,
+			NetTxBytes:      1500,
+			NetRxBytes:      3000,
+			BlockReadBytes:  4096,
+			BlockWriteBytes: 8192,
+		}
+		c.updateBasicBPF(c.systemProcessName, ct, true)
+
+		container := c.ContainersMetrics[c.systemProcessName]
+		Expect(container.BPFStats[attacher.CPUMigrationLabel].Delta).To(Equal(uint64(1)))
+		Expect(container.BPFStats[attacher.NetTxBytesLabel].Delta).To(Equal(uint64(1500)))
+		Expect(container.BPFStats[attacher.NetRxBytesLabel].Delta).To(Equal(uint64(3000)))
+		Expect(container.BPFStats[attacher.BlockReadBytesLabel].Delta).To(Equal(uint64(4096)))
+		Expect(container.BPFStats[attacher.BlockWriteBytesLabel].Aggr).To(Equal(uint64(8192)))
+
+		process := c.ProcessMetrics[1]
+		Expect(process.BPFStats[attacher.NetTxBytesLabel].Delta).To(Equal(uint64(1500)))
+		Expect(process.BPFStats[attacher.BlockWriteBytesLabel].Delta).To(Equal(uint64(8192)))
+	})
+	It("Sum the CPU time of the container and process per CPU package", func() {
+		enableProcessMetrics := config.EnableProcessMetrics
+		cpuPackageMap := collector_metric.NodeCPUPackageMap
+		defer func() {
+			config.EnableProcessMetrics = enableProcessMetrics
+			collector_metric.NodeCPUPackageMap = cpuPackageMap
+		}()
+		setCollectorMetrics()
+		config.EnableProcessMetrics = true
+		collector_metric.NodeCPUPackageMap = map[int32]string{0: "0", 1: "0", 2: "1", 3: "1"}
+
+		c := newMockCollector()
+		c.ContainersMetrics[c.systemProcessName] = collector_metric.NewContainerMetrics(c.systemProcessName, c.systemProcessName, c.systemProcessNamespace)
+		c.createProcessMetricsIfNotExist(1, "command")
+		cpuTimeTable := &fakeBPFTable{}
+		cpuTimeTable.put(ProcessCPUTimeKey{PID: 1, CPU: 0}, uint64(10))
+		cpuTimeTable.put(ProcessCPUTimeKey{PID: 1, CPU: 1}, uint64(20))
+		cpuTimeTable.put(ProcessCPUTimeKey{PID: 1, CPU: 3}, uint64(5))
+		// the cpu is not in the package map, its time is only counted per cpu
+		cpuTimeTable.put(ProcessCPUTimeKey{PID: 1, CPU: 7}, uint64(1))
+		// the process is not found in the processes table
+		cpuTimeTable.put(ProcessCPUTimeKey{PID: 2, CPU: 0}, uint64(100))
+		c.bpfHCMeter = &attacher.BpfModuleTables{CPUTimeTable: cpuTimeTable}
+		c.updateCPUTimePerCPU(map[uint64]string{1: c.systemProcessName})
+
+		container := c.ContainersMetrics[c.systemProcessName]
+		Expect(container.CPUTimePerCPU.Stat).To(HaveLen(4))
+		Expect(container.CPUTimePerPackage.Stat).To(HaveLen(2))
+		Expect(container.CPUTimePerPackage.Stat["0"].Delta).To(Equal(uint64(30)))
+		Expect(container.CPUTimePerPackage.Stat["1"].Delta).To(Equal(uint64(5)))
+		process := c.ProcessMetrics[1]
+		Expect(process.CPUTimePerPackage.Stat["0"].Aggr).To(Equal(uint64(30)))
+		Expect(process.CPUTimePerPackage.Stat["1"].Aggr).To(Equal(uint64(5)))
+	})
 })
@@ -0,0 +1,251 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+
+	"k8s.io/klog/v2"
+)
+
+// energyCheckpointVersion must be increased when the format of the checkpoint changes, older checkpoints are then discarded
+const energyCheckpointVersion = 1
+
+// energyCheckpoint holds the aggregated node and container energy in mJ, so that the energy counters continue after a restart
+type energyCheckpoint struct {
+	Version   int       `json:"version"`
+	NodeName  string    `json:"node_name"`
+	Timestamp time.Time `json:"timestamp"`
+	// Node holds the aggregated energy per node energy stat, e.g. dynamic_pkg, and id, e.g. the package id
+	Node map[string]map[string]uint64 `json:"node"`
+	// Containers holds the aggregated energy of the containers per container ID
+	Containers map[string]containerCheckpoint `json:"containers"`
+}
+
+type containerCheckpoint struct {
+	// Timestamp is the last time the container was seen, the entry is stale once it is older than ENERGY_CHECKPOINT_MAX_AGE
+	Timestamp time.Time `json:"timestamp"`
+	// Energy holds the aggregated energy per container energy stat, e.g. idle_dram
+	Energy map[string]uint64 `json:"energy"`
+}
+
+// nodeEnergyStats returns the node energy stats exported as counters, the total energy is not included since it holds the
+// last reading of the energy counters of the hardware
+func nodeEnergyStats(ne *collector_metric.NodeMetrics) map[string]*collector_metric.UInt64StatCollection {
+	return map[string]*collector_metric.UInt64StatCollection{
+		"dynamic_core":     ne.DynEnergyInCore,
+		"dynamic_dram":     ne.DynEnergyInDRAM,
+		"dynamic_uncore":   ne.DynEnergyInUncore,
+		"dynamic_pkg":      ne.DynEnergyInPkg,
+		"dynamic_gpu":      ne.DynEnergyInGPU,
+		"dynamic_other":    ne.DynEnergyInOther,
+		"dynamic_platform": ne.DynEnergyInPlatform,
+		"idle_core":        ne.IdleEnergyInCore,
+		"idle_dram":        ne.IdleEnergyInDRAM,
+		"idle_uncore":      ne.IdleEnergyInUncore,
+		"idle_pkg":         ne.IdleEnergyInPkg,
+		"idle_gpu":         ne.IdleEnergyInGPU,
+		"idle_other":       ne.IdleEnergyInOther,
+		"idle_platform":    ne.IdleEnergyInPlatform,
+	}
+}
+
+func containerEnergyStats(c *collector_metric.ContainerMetrics) map[string]*collector_metric.UInt64Stat {
+	return map[string]*collector_metric.UInt64Stat{
+		"dynamic_core":   c.DynEnergyInCore,
+		"dynamic_dram":   c.DynEnergyInDRAM,
+		"dynamic_uncore": c.DynEnergyInUncore,
+		"dynamic_pkg":    c.DynEnergyInPkg,
+		"dynamic_gpu":    c.DynEnergyInGPU,
+		"dynamic_other":  c.DynEnergyInOther,
+		"idle_core":      c.IdleEnergyInCore,
+		"idle_dram":      c.IdleEnergyInDRAM,
+		"idle_uncore":    c.IdleEnergyInUncore,
+		"idle_pkg":       c.IdleEnergyInPkg,
+		"idle_gpu":       c.IdleEnergyInGPU,
+		"idle_other":     c.IdleEnergyInOther,
+	}
+}
+
+// aggregatedEnergyStats returns the energy stats of a pod with the names of containerEnergyStats
+func aggregatedEnergyStats(a *collector_metric.AggregatedEnergy) map[string]*collector_metric.UInt64Stat {
+	return map[string]*collector_metric.UInt64Stat{
+		"dynamic_core":   a.DynEnergyInCore,
+		"dynamic_dram":   a.DynEnergyInDRAM,
+		"dynamic_uncore": a.DynEnergyInUncore,
+		"dynamic_pkg":    a.DynEnergyInPkg,
+		"dynamic_gpu":    a.DynEnergyInGPU,
+		"dynamic_other":  a.DynEnergyInOther,
+		"idle_core":      a.IdleEnergyInCore,
+		"idle_dram":      a.IdleEnergyInDRAM,
+		"idle_uncore":    a.IdleEnergyInUncore,
+		"idle_pkg":       a.IdleEnergyInPkg,
+		"idle_gpu":       a.IdleEnergyInGPU,
+		"idle_other":     a.IdleEnergyInOther,
+	}
+}
+
+// readEnergyCheckpoint reads the checkpoint file and discards the stale containers, nil is returned if there is no valid checkpoint.
+// A corrupt checkpoint is renamed so that it is not overwritten before it can be inspected.
+func readEnergyCheckpoint(path string, now time.Time, maxAge time.Duration) *energyCheckpoint {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Infof("failed to read the energy checkpoint: %v", err)
+		}
+		return nil
+	}
+	checkpoint := &energyCheckpoint{}
+	if err = json.Unmarshal(data, checkpoint); err == nil && checkpoint.Version != energyCheckpointVersion {
+		err = fmt.Errorf("unsupported version %d", checkpoint.Version)
+	}
+	if err != nil {
+		klog.Warningf("discarding the invalid energy checkpoint %s: %v", path, err)
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			klog.Infof("failed to rename the invalid energy checkpoint: %v", err)
+		}
+		return nil
+	}
+	if checkpoint.NodeName != collector_metric.NodeName {
+		klog.Infof("discarding the energy checkpoint of the node %s", checkpoint.NodeName)
+		return nil
+	}
+	if now.Sub(checkpoint.Timestamp) > maxAge {
+		klog.Infof("discarding the energy checkpoint saved at %s", checkpoint.Timestamp)
+		return nil
+	}
+	for containerID, container := range checkpoint.Containers {
+		if now.Sub(container.Timestamp) > maxAge {
+			delete(checkpoint.Containers, containerID)
+		}
+	}
+	return checkpoint
+}
+
+// writeEnergyCheckpoint replaces the checkpoint file atomically so that a crash does not leave a partial checkpoint
+func writeEnergyCheckpoint(path string, checkpoint *energyCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// restoreEnergyCheckpoint adds the checkpointed energy to the node, the containers energy is restored when they are found
+func (c *Collector) restoreEnergyCheckpoint(now time.Time) {
+	checkpoint := readEnergyCheckpoint(config.EnergyCheckpointFile, now, config.GetEnergyCheckpointMaxAge())
+	if checkpoint == nil {
+		return
+	}
+	for name, stats := range nodeEnergyStats(&c.NodeMetrics) {
+		for id, aggr := range checkpoint.Node[name] {
+			if _, exist := stats.Stat[id]; !exist {
+				stats.Stat[id] = &collector_metric.UInt64Stat{}
+			}
+			stats.Stat[id].Aggr += aggr
+		}
+	}
+	c.checkpointedContainers = checkpoint.Containers
+	c.restoreContainersEnergy()
+	klog.Infof("restored the energy checkpoint saved at %s with %d containers", checkpoint.Timestamp, len(checkpoint.Containers))
+}
+
+// restoreContainersEnergy adds the checkpointed energy to the containers found since the restart and to their pod,
+// since the pod energy is only accumulated from the container energy of each sample
+func (c *Collector) restoreContainersEnergy() {
+	for containerID, checkpoint := range c.checkpointedContainers {
+		container, found := c.ContainersMetrics[containerID]
+		if !found {
+			continue
+		}
+		for name, stat := range containerEnergyStats(container) {
+			stat.Aggr += checkpoint.Energy[name]
+		}
+		if containerID != c.systemProcessName {
+			podKey := collector_metric.PodKey(container)
+			if _, ok := c.PodsMetrics[podKey]; !ok {
+				c.PodsMetrics[podKey] = collector_metric.NewPodMetrics(container)
+			}
+			for name, stat := range aggregatedEnergyStats(&c.PodsMetrics[podKey].AggregatedEnergy) {
+				stat.Aggr += checkpoint.Energy[name]
+			}
+		}
+		delete(c.checkpointedContainers, containerID)
+	}
+}
+
+// newEnergyCheckpoint returns the current aggregated energy, the checkpointed containers that were not found since the restart
+// are kept until they are stale
+func (c *Collector) newEnergyCheckpoint(now time.Time) *energyCheckpoint {
+	checkpoint := &energyCheckpoint{
+		Version:    energyCheckpointVersion,
+		NodeName:   collector_metric.NodeName,
+		Timestamp:  now,
+		Node:       map[string]map[string]uint64{},
+		Containers: map[string]containerCheckpoint{},
+	}
+	for name, stats := range nodeEnergyStats(&c.NodeMetrics) {
+		checkpoint.Node[name] = map[string]uint64{}
+		for id, stat := range stats.Stat {
+			checkpoint.Node[name][id] = stat.Aggr
+		}
+	}
+	maxAge := config.GetEnergyCheckpointMaxAge()
+	for containerID, container := range c.checkpointedContainers {
+		if now.Sub(container.Timestamp) > maxAge {
+			delete(c.checkpointedContainers, containerID)
+			continue
+		}
+		checkpoint.Containers[containerID] = container
+	}
+	for containerID, container := range c.ContainersMetrics {
+		energy := map[string]uint64{}
+		for name, stat := range containerEnergyStats(container) {
+			energy[name] = stat.Aggr
+		}
+		checkpoint.Containers[containerID] = containerCheckpoint{Timestamp: now, Energy: energy}
+	}
+	return checkpoint
+}
+
+// saveEnergyCheckpoint writes the energy checkpoint, if force is false it is only written once per checkpoint interval
+func (c *Collector) saveEnergyCheckpoint(now time.Time, force bool) {
+	if config.EnergyCheckpointFile == "" {
+		return
+	}
+	if !force && now.Sub(c.lastEnergyCheckpoint) < config.GetEnergyCheckpointInterval() {
+		return
+	}
+	c.lastEnergyCheckpoint = now
+	if err := writeEnergyCheckpoint(config.EnergyCheckpointFile, c.newEnergyCheckpoint(now)); err != nil {
+		klog.Infof("failed to write the energy checkpoint: %v", err)
+	}
+}
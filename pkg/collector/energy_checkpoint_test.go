@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var _ = Describe("Test energy checkpoint", func() {
+	var (
+		checkpointFile string
+		now            time.Time
+	)
+
+	BeforeEach(func() {
+		energyCheckpointFile := config.EnergyCheckpointFile
+		DeferCleanup(func() { config.EnergyCheckpointFile = energyCheckpointFile })
+		setCollectorMetrics()
+		checkpointFile = filepath.Join(GinkgoT().TempDir(), "kepler", "energy_checkpoint.json")
+		config.EnergyCheckpointFile = checkpointFile
+		now = time.Now()
+	})
+
+	It("Restore the node and container energy after a restart", func() {
+		c := newMockCollector()
+		c.NodeMetrics.DynEnergyInPkg.SetDeltaStat("0", 5000)
+		c.NodeMetrics.IdleEnergyInPkg.SetDeltaStat("0", 3000)
+		Expect(c.ContainersMetrics["containerA"].DynEnergyInPkg.AddNewDelta(400)).To(Succeed())
+		Expect(c.ContainersMetrics["containerB"].IdleEnergyInDRAM.AddNewDelta(70)).To(Succeed())
+		c.saveEnergyCheckpoint(now, false)
+		// the checkpoint is not written again before the checkpoint interval
+		Expect(c.ContainersMetrics["containerA"].DynEnergyInPkg.AddNewDelta(100)).To(Succeed())
+		c.saveEnergyCheckpoint(now.Add(time.Second), false)
+
+		// only containerA is found after the restart
+		restarted := NewCollector()
+		restarted.ContainersMetrics = map[string]*collector_metric.ContainerMetrics{
+			"containerA": collector_metric.NewContainerMetrics("containerA", "podA", "test"),
+		}
+		Expect(restarted.ContainersMetrics["containerA"].DynEnergyInPkg.AddNewDelta(10)).To(Succeed())
+		restarted.restoreEnergyCheckpoint(now.Add(time.Minute))
+		Expect(restarted.NodeMetrics.GetAggrDynEnergyPerID(collector_metric.PKG, "0")).To(Equal(uint64(5000)))
+		Expect(restarted.NodeMetrics.GetAggrIdleEnergyPerID(collector_metric.PKG, "0")).To(Equal(uint64(3000)))
+		Expect(restarted.ContainersMetrics["containerA"].DynEnergyInPkg.Aggr).To(Equal(uint64(410)))
+		Expect(restarted.checkpointedContainers).To(HaveKey("containerB"))
+		// the pod energy continues from the restored container energy
+		restarted.updatePodEnergy()
+		Expect(restarted.PodsMetrics).To(HaveKey("test/podA"))
+		Expect(restarted.PodsMetrics["test/podA"].DynEnergyInPkg.Aggr).To(Equal(uint64(410)))
+
+		// the energy of containerB is restored once it is found, and only once
+		restarted.ContainersMetrics["containerB"] = collector_metric.NewContainerMetrics("containerB", "podB", "test")
+		restarted.restoreContainersEnergy()
+		restarted.restoreContainersEnergy()
+		Expect(restarted.ContainersMetrics["containerB"].IdleEnergyInDRAM.Aggr).To(Equal(uint64(70)))
+		Expect(restarted.PodsMetrics["test/podB"].IdleEnergyInDRAM.Aggr).To(Equal(uint64(70)))
+		Expect(restarted.checkpointedContainers).To(BeEmpty())
+	})
+
+	It("Discard the stale checkpointed energy", func() {
+		c := newMockCollector()
+		c.NodeMetrics.DynEnergyInPkg.SetDeltaStat("0", 5000)
+		Expect(c.ContainersMetrics["containerB"].DynEnergyInPkg.AddNewDelta(70)).To(Succeed())
+		c.saveEnergyCheckpoint(now, true)
+
+		// containerB is not found, it is kept in the checkpoint until it is stale
+		restarted := NewCollector()
+		restarted.ContainersMetrics = map[string]*collector_metric.ContainerMetrics{}
+		restarted.restoreEnergyCheckpoint(now.Add(time.Minute))
+		Expect(restarted.checkpointedContainers).To(HaveKey("containerB"))
+		checkpoint := restarted.newEnergyCheckpoint(now.Add(time.Minute))
+		Expect(checkpoint.Containers).To(HaveKey("containerB"))
+		Expect(checkpoint.Containers["containerB"].Timestamp).To(BeTemporally("==", now))
+		checkpoint = restarted.newEnergyCheckpoint(now.Add(config.GetEnergyCheckpointMaxAge() + time.Second))
+		Expect(checkpoint.Containers).NotTo(HaveKey("containerB"))
+		Expect(restarted.checkpointedContainers).To(BeEmpty())
+
+		// the whole checkpoint is stale
+		restarted = NewCollector()
+		restarted.restoreEnergyCheckpoint(now.Add(config.GetEnergyCheckpointMaxAge() + time.Second))
+		Expect(restarted.NodeMetrics.GetAggrDynEnergyPerID(collector_metric.PKG, "0")).To(Equal(uint64(0)))
+		Expect(restarted.checkpointedContainers).To(BeEmpty())
+
+		// the checkpoint of another node
+		nodeName := collector_metric.NodeName
+		defer func() { collector_metric.NodeName = nodeName }()
+		collector_metric.NodeName = "another-node"
+		Expect(readEnergyCheckpoint(checkpointFile, now, config.GetEnergyCheckpointMaxAge())).To(BeNil())
+	})
+
+	It("Discard a corrupt checkpoint", func() {
+		Expect(os.MkdirAll(filepath.Dir(checkpointFile), 0o755)).To(Succeed())
+		Expect(os.WriteFile(checkpointFile, []byte(`{"version": 1, "node": {"dynamic_pkg": {"0": `), 0o644)).To(Succeed())
+		c := NewCollector()
+		c.restoreEnergyCheckpoint(now)
+		Expect(c.NodeMetrics.DynEnergyInPkg.Stat).To(BeEmpty())
+		Expect(checkpointFile + ".corrupt").To(BeAnExistingFile())
+		Expect(checkpointFile).NotTo(BeAnExistingFile())
+
+		Expect(os.WriteFile(checkpointFile, []byte(`{"version": 99}`), 0o644)).To(Succeed())
+		Expect(readEnergyCheckpoint(checkpointFile, now, config.GetEnergyCheckpointMaxAge())).To(BeNil())
+
+		// a new checkpoint replaces the corrupt one
+		c.saveEnergyCheckpoint(now, true)
+		Expect(readEnergyCheckpoint(checkpointFile, now, config.GetEnergyCheckpointMaxAge())).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,103 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metric
+
+import (
+	"fmt"
+)
+
+// AggregatedEnergy holds the energy consumption of a group of containers or processes, e.g. a pod or a systemd unit.
+// The group energy is accumulated from the delta values of its members, so the group counters are not reset when a member exits.
+type AggregatedEnergy struct {
+	DynEnergyInCore   *UInt64Stat
+	DynEnergyInDRAM   *UInt64Stat
+	DynEnergyInUncore *UInt64Stat
+	DynEnergyInPkg    *UInt64Stat
+	DynEnergyInGPU    *UInt64Stat
+	DynEnergyInOther  *UInt64Stat
+
+	IdleEnergyInCore   *UInt64Stat
+	IdleEnergyInDRAM   *UInt64Stat
+	IdleEnergyInUncore *UInt64Stat
+	IdleEnergyInPkg    *UInt64Stat
+	IdleEnergyInGPU    *UInt64Stat
+	IdleEnergyInOther  *UInt64Stat
+}
+
+// NewAggregatedEnergy creates an AggregatedEnergy without energy
+func NewAggregatedEnergy() AggregatedEnergy {
+	return AggregatedEnergy{
+		DynEnergyInCore:    &UInt64Stat{},
+		DynEnergyInDRAM:    &UInt64Stat{},
+		DynEnergyInUncore:  &UInt64Stat{},
+		DynEnergyInPkg:     &UInt64Stat{},
+		DynEnergyInOther:   &UInt64Stat{},
+		DynEnergyInGPU:     &UInt64Stat{},
+		IdleEnergyInCore:   &UInt64Stat{},
+		IdleEnergyInDRAM:   &UInt64Stat{},
+		IdleEnergyInUncore: &UInt64Stat{},
+		IdleEnergyInPkg:    &UInt64Stat{},
+		IdleEnergyInOther:  &UInt64Stat{},
+		IdleEnergyInGPU:    &UInt64Stat{},
+	}
+}
+
+// stats returns the energy stats in the order expected by addEnergy
+func (a *AggregatedEnergy) stats() []*UInt64Stat {
+	return []*UInt64Stat{
+		a.DynEnergyInCore, a.DynEnergyInDRAM, a.DynEnergyInUncore, a.DynEnergyInPkg, a.DynEnergyInOther, a.DynEnergyInGPU,
+		a.IdleEnergyInCore, a.IdleEnergyInDRAM, a.IdleEnergyInUncore, a.IdleEnergyInPkg, a.IdleEnergyInOther, a.IdleEnergyInGPU,
+	}
+}
+
+// ResetDeltaValues reset all current value to 0
+func (a *AggregatedEnergy) ResetDeltaValues() {
+	for _, stat := range a.stats() {
+		stat.ResetDeltaValues()
+	}
+}
+
+// addEnergy adds the delta values of the member stats, given in the order of stats
+func (a *AggregatedEnergy) addEnergy(memberStats ...*UInt64Stat) error {
+	for i, stat := range a.stats() {
+		if err := stat.AddNewDelta(memberStats[i].Delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddContainerEnergy adds the energy consumed by the container in the last sample to the group energy
+func (a *AggregatedEnergy) AddContainerEnergy(c *ContainerMetrics) error {
+	return a.addEnergy(
+		c.DynEnergyInCore, c.DynEnergyInDRAM, c.DynEnergyInUncore, c.DynEnergyInPkg, c.DynEnergyInOther, c.DynEnergyInGPU,
+		c.IdleEnergyInCore, c.IdleEnergyInDRAM, c.IdleEnergyInUncore, c.IdleEnergyInPkg, c.IdleEnergyInOther, c.IdleEnergyInGPU)
+}
+
+// AddProcessEnergy adds the energy consumed by the process in the last sample to the group energy
+func (a *AggregatedEnergy) AddProcessEnergy(p *ProcessMetrics) error {
+	return a.addEnergy(
+		p.DynEnergyInCore, p.DynEnergyInDRAM, p.DynEnergyInUncore, p.DynEnergyInPkg, p.DynEnergyInOther, p.DynEnergyInGPU,
+		p.IdleEnergyInCore, p.IdleEnergyInDRAM, p.IdleEnergyInUncore, p.IdleEnergyInPkg, p.IdleEnergyInOther, p.IdleEnergyInGPU)
+}
+
+func (a *AggregatedEnergy) String() string {
+	return fmt.Sprintf("\tDyn ePkg (mJ): %s (eCore: %s eDram: %s eUncore: %s) eGPU (mJ): %s eOther (mJ): %s \n"+
+		"\tIdle ePkg (mJ): %s (eCore: %s eDram: %s eUncore: %s) eGPU (mJ): %s eOther (mJ): %s \n",
+		a.DynEnergyInPkg, a.DynEnergyInCore, a.DynEnergyInDRAM, a.DynEnergyInUncore, a.DynEnergyInGPU, a.DynEnergyInOther,
+		a.IdleEnergyInPkg, a.IdleEnergyInCore, a.IdleEnergyInDRAM, a.IdleEnergyInUncore, a.IdleEnergyInGPU, a.IdleEnergyInOther)
+}
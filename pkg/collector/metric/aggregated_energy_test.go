@@ -0,0 +1,53 @@
+package metric
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Aggregated Energy", func() {
+	It("Test AddProcessEnergy", func() {
+		p1 := NewProcessMetrics(1, "postgres")
+		p2 := NewProcessMetrics(2, "postgres")
+		// each process stat has a different value to check that it is added to the matching aggregated stat
+		p1Stats := []*UInt64Stat{
+			p1.DynEnergyInCore, p1.DynEnergyInDRAM, p1.DynEnergyInUncore, p1.DynEnergyInPkg, p1.DynEnergyInOther, p1.DynEnergyInGPU,
+			p1.IdleEnergyInCore, p1.IdleEnergyInDRAM, p1.IdleEnergyInUncore, p1.IdleEnergyInPkg, p1.IdleEnergyInOther, p1.IdleEnergyInGPU,
+		}
+		for i, stat := range p1Stats {
+			Expect(stat.AddNewDelta(uint64(i + 1))).To(Succeed())
+		}
+		Expect(p2.DynEnergyInPkg.AddNewDelta(100)).To(Succeed())
+
+		s := NewSystemdUnitMetrics("postgresql.service", "system.slice")
+		Expect(s.AddProcessEnergy(p1)).To(Succeed())
+		Expect(s.AddProcessEnergy(p2)).To(Succeed())
+		for i, stat := range s.stats() {
+			expected := uint64(i + 1)
+			if stat == s.DynEnergyInPkg {
+				expected += 100
+			}
+			Expect(stat.Delta).To(Equal(expected))
+			Expect(stat.Aggr).To(Equal(expected))
+		}
+		Expect(s.DynEnergyInOther.Aggr).To(Equal(uint64(5)))
+		Expect(s.IdleEnergyInGPU.Aggr).To(Equal(uint64(12)))
+
+		s.ResetDeltaValues()
+		Expect(s.DynEnergyInPkg.Delta).To(Equal(uint64(0)))
+		Expect(s.DynEnergyInPkg.Aggr).To(Equal(uint64(104)))
+	})
+
+	It("Test AddContainerEnergy", func() {
+		c := NewContainerMetrics("containerA", "podA", "test")
+		Expect(c.DynEnergyInGPU.AddNewDelta(7)).To(Succeed())
+		Expect(c.IdleEnergyInUncore.AddNewDelta(3)).To(Succeed())
+
+		a := NewAggregatedEnergy()
+		Expect(a.AddContainerEnergy(c)).To(Succeed())
+		Expect(a.AddContainerEnergy(c)).To(Succeed())
+		Expect(a.DynEnergyInGPU.Aggr).To(Equal(uint64(14)))
+		Expect(a.IdleEnergyInUncore.Aggr).To(Equal(uint64(6)))
+		Expect(a.DynEnergyInCore.Aggr).To(Equal(uint64(0)))
+	})
+})
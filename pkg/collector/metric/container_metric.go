@@ -48,6 +48,18 @@ type ContainerMetrics struct {
 	ContainerName string
 	PodName       string
 	Namespace     string
+	// kubernetes metadata of the pod, used to aggregate the container energy per pod
+	PodUID    string
+	QOSClass  string
+	OwnerKind string
+	OwnerName string
+	// PodMetadataLabels are the values of the pod labels and annotations exported as metric labels
+	PodMetadataLabels []string
+	// resources of the container spec, the CPU in millicores and the memory in bytes, used to attribute the idle energy
+	CPURequest    uint64
+	MemoryRequest uint64
+	CPULimit      uint64
+	MemoryLimit   uint64
 
 	CurrProcesses int
 	Disks         int
@@ -66,8 +78,11 @@ func NewContainerMetrics(containerName, podName, podNamespace string) *Container
 		Namespace:     podNamespace,
 		ProcessMetrics: ProcessMetrics{
 			CPUTime:            &UInt64Stat{},
+			CPUTimePerCPU:      &UInt64StatCollection{Stat: make(map[string]*UInt64Stat)},
+			CPUTimePerPackage:  &UInt64StatCollection{Stat: make(map[string]*UInt64Stat)},
 			CounterStats:       make(map[string]*UInt64Stat),
 			SoftIRQCount:       make([]UInt64Stat, config.MaxIRQ),
+			BPFStats:           make(map[string]*UInt64Stat),
 			DynEnergyInCore:    &UInt64Stat{},
 			DynEnergyInDRAM:    &UInt64Stat{},
 			DynEnergyInUncore:  &UInt64Stat{},
@@ -93,6 +108,9 @@ func NewContainerMetrics(containerName, podName, podNamespace string) *Container
 	for _, metricName := range AvailableHWCounters {
 		c.CounterStats[metricName] = &UInt64Stat{}
 	}
+	for _, metricName := range AvailableBPFStatCounters {
+		c.BPFStats[metricName] = &UInt64Stat{}
+	}
 	// TODO: transparently list the other metrics and do not initialize them when they are not supported, e.g. HC
 	if accelerator.IsGPUCollectionSupported() {
 		c.CounterStats[config.GPUSMUtilization] = &UInt64Stat{}
@@ -113,9 +131,14 @@ func NewContainerMetrics(containerName, podName, podNamespace string) *Container
 func (c *ContainerMetrics) ResetDeltaValues() {
 	c.CurrProcesses = 0
 	c.CPUTime.ResetDeltaValues()
+	c.CPUTimePerCPU.ResetDeltaValues()
+	c.CPUTimePerPackage.ResetDeltaValues()
 	for i := 0; i < config.MaxIRQ; i++ {
 		c.SoftIRQCount[i].ResetDeltaValues()
 	}
+	for statKey := range c.BPFStats {
+		c.BPFStats[statKey].ResetDeltaValues()
+	}
 	for counterKey := range c.CounterStats {
 		c.CounterStats[counterKey].ResetDeltaValues()
 	}
@@ -166,11 +189,19 @@ func (c *ContainerMetrics) getFloatCurrAndAggrValue(metric string) (curr, aggr f
 	return 0, 0, nil
 }
 
+// GetDeltaAndAggrValue returns the delta and aggregated values of a container resource usage metric, e.g. a hardware counter or a cgroup stat
+func (c *ContainerMetrics) GetDeltaAndAggrValue(metric string) (delta, aggr uint64, err error) {
+	return c.getIntDeltaAndAggrValue(metric)
+}
+
 // getIntDeltaAndAggrValue return curr, aggr uint64 values of specific uint metric
 func (c *ContainerMetrics) getIntDeltaAndAggrValue(metric string) (curr, aggr uint64, err error) {
 	if val, exists := c.CounterStats[metric]; exists {
 		return val.Delta, val.Aggr, nil
 	}
+	if val, exists := c.BPFStats[metric]; exists {
+		return val.Delta, val.Aggr, nil
+	}
 	if val, exists := c.CgroupFSStats[metric]; exists {
 		return val.SumAllDeltaValues(), val.SumAllAggrValues(), nil
 	}
@@ -20,11 +20,13 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/power/accelerator"
+	"github.com/sustainable-computing-io/kepler/pkg/power/calibration"
 	"github.com/sustainable-computing-io/kepler/pkg/power/components/source"
 )
 
@@ -37,6 +39,9 @@ const (
 	OTHER     = "other"
 	PLATFORM  = "platform"
 	FREQUENCY = "frequency"
+
+	// raplThrottlingThreshold is the ratio of the power limit above which a RAPL domain is estimated to be throttled
+	raplThrottlingThreshold = 0.95
 )
 
 var (
@@ -60,6 +65,8 @@ type NodeMetrics struct {
 	TotalEnergyInGPU      *UInt64StatCollection
 	TotalEnergyInOther    *UInt64StatCollection
 	TotalEnergyInPlatform *UInt64StatCollection
+	// TotalEnergyInCPU holds the core energy per logical CPU, only available when the hardware exposes per-core counters
+	TotalEnergyInCPU *UInt64StatCollection
 
 	DynEnergyInCore     *UInt64StatCollection
 	DynEnergyInDRAM     *UInt64StatCollection
@@ -76,17 +83,39 @@ type NodeMetrics struct {
 	IdleEnergyInGPU      *UInt64StatCollection
 	IdleEnergyInOther    *UInt64StatCollection
 	IdleEnergyInPlatform *UInt64StatCollection
+	// PlatformPowerSource is the source used in the last platform energy update, e.g. acpi, redfish or estimator
+	PlatformPowerSource string
 
 	CPUFrequency map[int32]uint64
 
 	// IdleCPUUtilization is used to determine idle periods
 	IdleCPUUtilization uint64
 	FoundNewIdleState  bool
+
+	// LastUpdateTime is the time of the last metric collection
+	LastUpdateTime time.Time
+	// ElapsedSec is the measured time in seconds between the last two metric collections
+	ElapsedSec float64
+	// idlePower holds the idle power in mW per component and source, the idle energy is this power integrated over the elapsed time
+	idlePower map[string]map[string]float64
+	// IdleCalibrator fits the idle power over the node utilization, the lowest observed power is used if it is nil or not fitted yet
+	IdleCalibrator *calibration.IdlePowerCalibrator
+
+	// RAPLPowerLimits holds the power capping configuration of the RAPL domains
+	RAPLPowerLimits []source.RAPLDomainPowerLimit
+	// RAPLThrottlingCount holds the number of throttling episodes per package and RAPL domain
+	RAPLThrottlingCount map[string]map[string]uint64
+	// raplThrottled holds whether a RAPL domain was throttled in the last sample, per package and domain
+	raplThrottled map[string]map[string]bool
 }
 
 func NewNodeMetrics() *NodeMetrics {
 	return &NodeMetrics{
 		ResourceUsage: make(map[string]float64),
+		idlePower:     make(map[string]map[string]float64),
+
+		RAPLThrottlingCount: make(map[string]map[string]uint64),
+		raplThrottled:       make(map[string]map[string]bool),
 		TotalEnergyInCore: &UInt64StatCollection{
 			Stat: make(map[string]*UInt64Stat),
 		},
@@ -108,6 +137,9 @@ func NewNodeMetrics() *NodeMetrics {
 		TotalEnergyInPlatform: &UInt64StatCollection{
 			Stat: make(map[string]*UInt64Stat),
 		},
+		TotalEnergyInCPU: &UInt64StatCollection{
+			Stat: make(map[string]*UInt64Stat),
+		},
 
 		DynEnergyInCore: &UInt64StatCollection{
 			Stat: make(map[string]*UInt64Stat),
@@ -162,6 +194,7 @@ func (ne *NodeMetrics) ResetDeltaValues() {
 	ne.TotalEnergyInPkg.ResetDeltaValues()
 	ne.TotalEnergyInGPU.ResetDeltaValues()
 	ne.TotalEnergyInPlatform.ResetDeltaValues()
+	ne.TotalEnergyInCPU.ResetDeltaValues()
 	ne.DynEnergyInCore.ResetDeltaValues()
 	ne.DynEnergyInDRAM.ResetDeltaValues()
 	ne.DynEnergyInUncore.ResetDeltaValues()
@@ -195,6 +228,24 @@ func (ne *NodeMetrics) AddNodeResUsageFromContainerResUsage(containersMetrics ma
 	}
 }
 
+// SetUpdateTime records the time of a new metric collection and the time elapsed since the previous one
+func (ne *NodeMetrics) SetUpdateTime(now time.Time) {
+	if !ne.LastUpdateTime.IsZero() && now.After(ne.LastUpdateTime) {
+		ne.ElapsedSec = now.Sub(ne.LastUpdateTime).Seconds()
+	} else {
+		ne.ElapsedSec = config.GetSamplePeriod().Seconds()
+	}
+	ne.LastUpdateTime = now
+}
+
+// GetElapsedSec returns the measured duration of the current sample, or the sample period if it was not measured
+func (ne *NodeMetrics) GetElapsedSec() float64 {
+	if ne.ElapsedSec > 0 {
+		return ne.ElapsedSec
+	}
+	return config.GetSamplePeriod().Seconds()
+}
+
 // SetLastestPlatformEnergy adds the lastest energy consumption from the node sensor
 func (ne *NodeMetrics) SetLastestPlatformEnergy(platformEnergy map[string]float64) {
 	for sensorID, energy := range platformEnergy {
@@ -213,6 +264,63 @@ func (ne *NodeMetrics) SetNodeComponentsEnergy(componentsEnergy map[int]source.N
 	}
 }
 
+// SetNodeCPUEnergy adds the lastest core energy consumption of each CPU
+func (ne *NodeMetrics) SetNodeCPUEnergy(cpuEnergy map[int]uint64) {
+	for cpu, energy := range cpuEnergy {
+		ne.TotalEnergyInCPU.SetAggrStat(strconv.Itoa(cpu), energy)
+	}
+}
+
+// SetRAPLPowerLimits updates the RAPL power limits and counts the estimated throttling episodes.
+// The hardware throttle status is not read, a domain is assumed to be throttled when its average power in the sample
+// reaches raplThrottlingThreshold of the lowest enabled power limit, so short throttling within a sample is missed and
+// a domain running close to its limit without being throttled is counted.
+// A new throttling episode is counted when a domain that was not throttled becomes throttled.
+func (ne *NodeMetrics) SetRAPLPowerLimits(limits []source.RAPLDomainPowerLimit) {
+	ne.RAPLPowerLimits = limits
+	if ne.RAPLThrottlingCount == nil {
+		ne.RAPLThrottlingCount = make(map[string]map[string]uint64)
+	}
+	if ne.raplThrottled == nil {
+		ne.raplThrottled = make(map[string]map[string]bool)
+	}
+	elapsedSec := ne.GetElapsedSec()
+	for _, limit := range limits {
+		if !limit.Enabled || len(limit.Constraints) == 0 {
+			continue
+		}
+		pkgID := strconv.Itoa(limit.PackageID)
+		component := limit.Domain
+		if component == "package" {
+			component = PKG
+		}
+		stat, exist := ne.getTotalEnergyStatCollection(component).Stat[pkgID]
+		if !exist {
+			continue
+		}
+		powerLimit := limit.Constraints[0].PowerLimit
+		for _, constraint := range limit.Constraints {
+			if constraint.PowerLimit > 0 && (powerLimit == 0 || constraint.PowerLimit < powerLimit) {
+				powerLimit = constraint.PowerLimit
+			}
+		}
+		if powerLimit == 0 {
+			continue
+		}
+		if _, exist := ne.RAPLThrottlingCount[pkgID]; !exist {
+			ne.RAPLThrottlingCount[pkgID] = make(map[string]uint64)
+			ne.raplThrottled[pkgID] = make(map[string]bool)
+		}
+		// the energy is in mJ and the power limit in uW
+		power := float64(stat.Delta) / elapsedSec * 1000
+		throttled := power >= raplThrottlingThreshold*float64(powerLimit)
+		if throttled && !ne.raplThrottled[pkgID][limit.Domain] {
+			ne.RAPLThrottlingCount[pkgID][limit.Domain]++
+		}
+		ne.raplThrottled[pkgID][limit.Domain] = throttled
+	}
+}
+
 // AddNodeGPUEnergy adds the lastest energy consumption of each GPU power consumption.
 // Right now we don't support other types of accelerators than GPU, but we will in the future.
 func (ne *NodeMetrics) AddNodeGPUEnergy(gpuEnergy []uint32) {
@@ -236,22 +344,38 @@ func (ne *NodeMetrics) UpdateIdleEnergy() {
 	ne.FoundNewIdleState = false
 }
 
+// CalcIdleEnergy updates the idle energy of a component.
+// The idle power is fitted by the calibrator when available, otherwise it is the lowest power observed while the resource utilization is low,
+// the idle energy is the idle power integrated over the elapsed time, so it does not depend on the sample duration.
 func (ne *NodeMetrics) CalcIdleEnergy(component string) {
 	toalStatCollection := ne.getTotalEnergyStatCollection(component)
 	idleStatCollection := ne.getIdleEnergyStatCollection(component)
+	if ne.idlePower == nil {
+		ne.idlePower = make(map[string]map[string]float64)
+	}
+	if _, exist := ne.idlePower[component]; !exist {
+		ne.idlePower[component] = make(map[string]float64)
+	}
+	elapsedSec := ne.GetElapsedSec()
+	// the node utilization is unknown before the first resource usage update
+	usage, hasUsage := ne.ResourceUsage[config.CoreUsageMetric]
 	for id := range toalStatCollection.Stat {
-		delta := toalStatCollection.Stat[id].Delta
-		if _, exist := idleStatCollection.Stat[id]; !exist {
-			idleStatCollection.SetDeltaStat(id, delta)
-		} else {
-			idleDelta := idleStatCollection.Stat[id].Delta
-			// only updates the idle energy if the resource utilization is low. i.e., ne.FoundNewIdleState == true
-			if ((idleDelta == 0) || (idleDelta > delta)) && ((ne.FoundNewIdleState) || (ne.IdleCPUUtilization == 0)) {
-				idleStatCollection.SetDeltaStat(id, delta)
-			} else {
-				idleStatCollection.SetDeltaStat(id, idleDelta)
+		power := float64(toalStatCollection.Stat[id].Delta) / elapsedSec
+		idlePower, exist := ne.idlePower[component][id]
+		// only updates the idle power if the resource utilization is low. i.e., ne.FoundNewIdleState == true
+		if !exist || (((idlePower == 0) || (idlePower > power)) && ((ne.FoundNewIdleState) || (ne.IdleCPUUtilization == 0))) {
+			idlePower = power
+			ne.idlePower[component][id] = idlePower
+		}
+		if ne.IdleCalibrator != nil {
+			if hasUsage {
+				ne.IdleCalibrator.AddSample(component, id, usage/elapsedSec, power)
+			}
+			if calibratedPower, found := ne.IdleCalibrator.GetIdlePower(component, id); found {
+				idlePower = calibratedPower
 			}
 		}
+		idleStatCollection.SetDeltaStat(id, uint64(math.Round(idlePower*elapsedSec)))
 	}
 }
 
@@ -17,9 +17,12 @@ limitations under the License.
 package metric
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/power/calibration"
 	"github.com/sustainable-computing-io/kepler/pkg/power/components/source"
 )
 
@@ -123,4 +126,81 @@ var _ = Describe("Test Node Metric", func() {
 		val := nodeMetrics.GetNodeResUsagePerResType("")
 		Expect(float64(0)).To(Equal(val))
 	})
+
+	It("test SetUpdateTime", func() {
+		now := time.Now()
+		nodeMetrics.SetUpdateTime(now)
+		Expect(nodeMetrics.GetElapsedSec()).To(Equal(config.GetSamplePeriod().Seconds()))
+		nodeMetrics.SetUpdateTime(now.Add(4500 * time.Millisecond))
+		Expect(nodeMetrics.GetElapsedSec()).To(Equal(4.5))
+	})
+
+	It("test idle energy is integrated over the elapsed time", func() {
+		nodeMetrics.ElapsedSec = 2
+		nodeMetrics.UpdateIdleEnergy()
+		// the first observed pkg delta (8mJ in 2s) is the idle power
+		Expect(nodeMetrics.GetDeltaIdleEnergyPerID(PKG, "0")).To(Equal(uint64(8)))
+
+		// a longer sample with the same power does not change the idle power, but the idle energy
+		nodeMetrics.ResetDeltaValues()
+		nodeMetrics.ElapsedSec = 4
+		nodeMetrics.FoundNewIdleState = true
+		nodeMetrics.SetNodeComponentsEnergy(map[int]source.NodeComponentsEnergy{0: {Pkg: 34, Core: 25, DRAM: 13}})
+		nodeMetrics.UpdateIdleEnergy()
+		Expect(nodeMetrics.GetDeltaIdleEnergyPerID(PKG, "0")).To(Equal(uint64(16)))
+		nodeMetrics.UpdateDynEnergy()
+		Expect(nodeMetrics.GetDeltaDynEnergyPerID(PKG, "0")).To(Equal(uint64(0)))
+	})
+	It("test idle energy uses the calibrated idle power", func() {
+		nm := NewNodeMetrics()
+		nm.IdleCalibrator = calibration.NewIdlePowerCalibrator(20, "", "")
+		nm.ElapsedSec = 2
+		// the pkg consumes 4W idle and 1mW per instruction per second, the idle power is fitted after 10 samples
+		// although the node is never idle
+		for i := 0; i < 10; i++ {
+			instructions := float64(i%5+1) * 1000
+			nm.ResetDeltaValues()
+			nm.ResourceUsage[config.CoreUsageMetric] = instructions * nm.ElapsedSec
+			nm.TotalEnergyInPkg.SetDeltaStat("0", uint64((4000+instructions)*nm.ElapsedSec))
+			nm.UpdateIdleEnergy()
+		}
+		idlePower, found := nm.IdleCalibrator.GetIdlePower(PKG, "0")
+		Expect(found).To(BeTrue())
+		Expect(idlePower).To(BeNumerically("~", 4000, 1e-6))
+		Expect(nm.GetDeltaIdleEnergyPerID(PKG, "0")).To(Equal(uint64(8000)))
+
+		// the lowest observed power (5W) is used without the calibration
+		nm.IdleCalibrator = nil
+		nm.UpdateIdleEnergy()
+		Expect(nm.GetDeltaIdleEnergyPerID(PKG, "0")).To(Equal(uint64(10000)))
+	})
+	It("test SetRAPLPowerLimits counts the throttling episodes", func() {
+		nm := NewNodeMetrics()
+		nm.ElapsedSec = 1
+		// 10W long term and 12W short term package limits
+		limits := []source.RAPLDomainPowerLimit{{
+			PackageID: 0,
+			Domain:    "package",
+			Enabled:   true,
+			Constraints: []source.RAPLConstraint{
+				{Name: "long_term", PowerLimit: 10000000, TimeWindow: 1000000},
+				{Name: "short_term", PowerLimit: 12000000, TimeWindow: 2440},
+			},
+		}}
+		for _, energy := range []uint64{5000, 9800, 9900, 6000, 10000} {
+			nm.TotalEnergyInPkg.SetDeltaStat("0", energy)
+			nm.SetRAPLPowerLimits(limits)
+		}
+		Expect(nm.RAPLPowerLimits).To(Equal(limits))
+		// the domain was throttled twice: at 9.8W (and still at 9.9W), and again at 10W
+		Expect(nm.RAPLThrottlingCount["0"]["package"]).To(Equal(uint64(2)))
+
+		// disabled limits are not enforced
+		limits[0].Enabled = false
+		nm.TotalEnergyInPkg.SetDeltaStat("0", 5000)
+		nm.SetRAPLPowerLimits(limits)
+		nm.TotalEnergyInPkg.SetDeltaStat("0", 10000)
+		nm.SetRAPLPowerLimits(limits)
+		Expect(nm.RAPLThrottlingCount["0"]["package"]).To(Equal(uint64(2)))
+	})
 })
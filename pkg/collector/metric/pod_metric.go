@@ -0,0 +1,81 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metric
+
+import (
+	"fmt"
+)
+
+// PodMetrics holds the energy consumption of a pod, which is the sum of the energy of its containers
+type PodMetrics struct {
+	PodUID    string
+	PodName   string
+	Namespace string
+	QOSClass  string
+	OwnerKind string
+	OwnerName string
+	// PodMetadataLabels are the values of the pod labels and annotations exported as metric labels
+	PodMetadataLabels []string
+
+	AggregatedEnergy
+}
+
+// PodKey returns the key identifying the pod of a container.
+// The pod UID is used when available, since a pod can be recreated with the same name.
+func PodKey(c *ContainerMetrics) string {
+	if c.PodUID != "" {
+		return c.PodUID
+	}
+	return c.Namespace + "/" + c.PodName
+}
+
+// NewPodMetrics creates a new PodMetrics instance with the pod metadata of the given container
+func NewPodMetrics(c *ContainerMetrics) *PodMetrics {
+	return &PodMetrics{
+		PodUID:            c.PodUID,
+		PodName:           c.PodName,
+		Namespace:         c.Namespace,
+		QOSClass:          c.QOSClass,
+		OwnerKind:         c.OwnerKind,
+		OwnerName:         c.OwnerName,
+		PodMetadataLabels: c.PodMetadataLabels,
+		AggregatedEnergy:  NewAggregatedEnergy(),
+	}
+}
+
+// AddContainerEnergy adds the energy consumed by the container in the last sample to the pod energy
+func (p *PodMetrics) AddContainerEnergy(c *ContainerMetrics) error {
+	if err := p.AggregatedEnergy.AddContainerEnergy(c); err != nil {
+		return err
+	}
+	// the metadata can be updated after the container was created, e.g. when the pod cache is refreshed
+	if p.QOSClass == "" {
+		p.QOSClass = c.QOSClass
+	}
+	if p.OwnerKind == "" {
+		p.OwnerKind = c.OwnerKind
+		p.OwnerName = c.OwnerName
+	}
+	if len(p.PodMetadataLabels) == 0 {
+		p.PodMetadataLabels = c.PodMetadataLabels
+	}
+	return nil
+}
+
+func (p *PodMetrics) String() string {
+	return fmt.Sprintf("energy from pod name: %s namespace: %s uid: %s\n%s", p.PodName, p.Namespace, p.PodUID, &p.AggregatedEnergy)
+}
@@ -0,0 +1,37 @@
+package metric
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Pod Metric", func() {
+	It("Test PodKey", func() {
+		c := NewContainerMetrics("containerA", "podA", "test")
+		Expect(PodKey(c)).To(Equal("test/podA"))
+		c.PodUID = "uidA"
+		Expect(PodKey(c)).To(Equal("uidA"))
+	})
+
+	It("Test AddContainerEnergy", func() {
+		c1 := NewContainerMetrics("containerA", "podA", "test")
+		c1.QOSClass = "Guaranteed"
+		c2 := NewContainerMetrics("containerB", "podA", "test")
+		Expect(c1.DynEnergyInCore.AddNewDelta(10)).To(Succeed())
+		Expect(c1.IdleEnergyInDRAM.AddNewDelta(2)).To(Succeed())
+		Expect(c2.DynEnergyInCore.AddNewDelta(5)).To(Succeed())
+
+		p := NewPodMetrics(c2)
+		Expect(p.AddContainerEnergy(c1)).To(Succeed())
+		Expect(p.AddContainerEnergy(c2)).To(Succeed())
+		Expect(p.DynEnergyInCore.Delta).To(Equal(uint64(15)))
+		Expect(p.DynEnergyInCore.Aggr).To(Equal(uint64(15)))
+		Expect(p.IdleEnergyInDRAM.Aggr).To(Equal(uint64(2)))
+		// the metadata missing in the first container is taken from the others
+		Expect(p.QOSClass).To(Equal("Guaranteed"))
+
+		p.ResetDeltaValues()
+		Expect(p.DynEnergyInCore.Delta).To(Equal(uint64(0)))
+		Expect(p.DynEnergyInCore.Aggr).To(Equal(uint64(15)))
+	})
+})
@@ -42,9 +42,17 @@ type ProcessMetrics struct {
 	PID          uint64
 	Command      string
 	CounterStats map[string]*UInt64Stat
+	// SystemdUnit and SystemdSlice identify the systemd unit running the process, empty if it is not resolved
+	SystemdUnit  string
+	SystemdSlice string
+	// SystemdUnitPath is the cgroup path of the systemd unit
+	SystemdUnitPath string
 	// ebpf metrics
 	CPUTime           *UInt64Stat
+	CPUTimePerCPU     *UInt64StatCollection // CPU time per logical CPU on which the process ran
+	CPUTimePerPackage *UInt64StatCollection // CPU time per CPU package (socket) on which the process ran
 	SoftIRQCount      []UInt64Stat
+	BPFStats          map[string]*UInt64Stat // task events and I/O bytes counted by the eBPF program, e.g. cpu migrations
 	GPUStats          map[string]*UInt64Stat
 	DynEnergyInCore   *UInt64Stat
 	DynEnergyInDRAM   *UInt64Stat
@@ -67,8 +75,11 @@ func NewProcessMetrics(pid uint64, command string) *ProcessMetrics {
 		PID:                pid,
 		Command:            command,
 		CPUTime:            &UInt64Stat{},
+		CPUTimePerCPU:      &UInt64StatCollection{Stat: make(map[string]*UInt64Stat)},
+		CPUTimePerPackage:  &UInt64StatCollection{Stat: make(map[string]*UInt64Stat)},
 		CounterStats:       make(map[string]*UInt64Stat),
 		SoftIRQCount:       make([]UInt64Stat, config.MaxIRQ),
+		BPFStats:           make(map[string]*UInt64Stat),
 		DynEnergyInCore:    &UInt64Stat{},
 		DynEnergyInDRAM:    &UInt64Stat{},
 		DynEnergyInUncore:  &UInt64Stat{},
@@ -86,6 +97,9 @@ func NewProcessMetrics(pid uint64, command string) *ProcessMetrics {
 	for _, metricName := range AvailableHWCounters {
 		p.CounterStats[metricName] = &UInt64Stat{}
 	}
+	for _, metricName := range AvailableBPFStatCounters {
+		p.BPFStats[metricName] = &UInt64Stat{}
+	}
 	// TODO: transparently list the other metrics and do not initialize them when they are not supported, e.g. HC
 	if accelerator.IsGPUCollectionSupported() {
 		p.CounterStats[config.GPUSMUtilization] = &UInt64Stat{}
@@ -97,12 +111,17 @@ func NewProcessMetrics(pid uint64, command string) *ProcessMetrics {
 // ResetCurr reset all current value to 0
 func (p *ProcessMetrics) ResetDeltaValues() {
 	p.CPUTime.ResetDeltaValues()
+	p.CPUTimePerCPU.ResetDeltaValues()
+	p.CPUTimePerPackage.ResetDeltaValues()
 	for counterKey := range p.CounterStats {
 		p.CounterStats[counterKey].ResetDeltaValues()
 	}
 	for i := 0; i < config.MaxIRQ; i++ {
 		p.SoftIRQCount[i].ResetDeltaValues()
 	}
+	for statKey := range p.BPFStats {
+		p.BPFStats[statKey].ResetDeltaValues()
+	}
 	p.DynEnergyInCore.ResetDeltaValues()
 	p.DynEnergyInDRAM.ResetDeltaValues()
 	p.DynEnergyInUncore.ResetDeltaValues()
@@ -128,6 +147,9 @@ func (p *ProcessMetrics) getIntDeltaAndAggrValue(metric string) (curr, aggr uint
 	if val, exists := p.CounterStats[metric]; exists {
 		return val.Delta, val.Aggr, nil
 	}
+	if val, exists := p.BPFStats[metric]; exists {
+		return val.Delta, val.Aggr, nil
+	}
 
 	switch metric {
 	// ebpf metrics
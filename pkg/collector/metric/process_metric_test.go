@@ -3,6 +3,8 @@ package metric
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
 )
 
 var _ = Describe("ProcessMetric", func() {
@@ -20,6 +22,20 @@ var _ = Describe("ProcessMetric", func() {
 		Expect(p.CPUTime.Delta).To(Equal(uint64(0)))
 	})
 
+	It("Test task event values", func() {
+		p := NewProcessMetrics(0, "command")
+		p.BPFStats[config.CPUMigration] = &UInt64Stat{}
+		err := p.BPFStats[config.CPUMigration].AddNewDelta(3)
+		Expect(err).NotTo(HaveOccurred())
+		delta, aggr, err := p.getIntDeltaAndAggrValue(config.CPUMigration)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(delta).To(Equal(uint64(3)))
+		Expect(aggr).To(Equal(uint64(3)))
+		p.ResetDeltaValues()
+		Expect(p.BPFStats[config.CPUMigration].Delta).To(Equal(uint64(0)))
+		Expect(p.BPFStats[config.CPUMigration].Aggr).To(Equal(uint64(3)))
+	})
+
 	It("Test SumAllDynDeltaValues", func() {
 		p := NewProcessMetrics(0, "command")
 		exp := p.DynEnergyInPkg.Delta + p.DynEnergyInGPU.Delta + p.DynEnergyInOther.Delta
@@ -24,6 +24,8 @@ const (
 var (
 	// AvailableEBPFCounters holds a list of eBPF counters that might be collected
 	AvailableEBPFCounters []string
+	// AvailableBPFStatCounters holds the eBPF counters of the task events (e.g. cpu migrations) and of the I/O bytes
+	AvailableBPFStatCounters []string
 	// AvailableHWCounters holds a list of hardware counters that might be collected
 	AvailableHWCounters []string
 	// AvailableCgroupMetrics holds a list of cgroup metrics exposed by the cgroup that might be collected
@@ -38,6 +40,7 @@ var (
 func InitAvailableParamAndMetrics() {
 	AvailableHWCounters = attacher.GetEnabledHWCounters()
 	AvailableEBPFCounters = attacher.GetEnabledBPFCounters()
+	AvailableBPFStatCounters = getBPFStatCounters(AvailableEBPFCounters)
 	AvailableCgroupMetrics = cgroup.GetAvailableCgroupMetrics()
 	AvailableKubeletMetrics = cgroup.GetAvailableKubeletMetrics()
 	CPUHardwareCounterEnabled = isCounterStatEnabled(attacher.CPUInstructionLabel)
@@ -46,6 +49,19 @@ func InitAvailableParamAndMetrics() {
 	setEnabledMetrics()
 }
 
+// getBPFStatCounters returns the task event and I/O counters in the list of eBPF counters
+func getBPFStatCounters(ebpfCounters []string) []string {
+	counters := []string{}
+	for _, counter := range ebpfCounters {
+		for _, statCounter := range attacher.BPFStatCounters {
+			if counter == statCounter {
+				counters = append(counters, counter)
+			}
+		}
+	}
+	return counters
+}
+
 type UInt64Stat struct {
 	Aggr  uint64
 	Delta uint64
@@ -3,6 +3,7 @@ package metric
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/sustainable-computing-io/kepler/pkg/bpfassets/attacher"
 	"github.com/sustainable-computing-io/kepler/pkg/cgroup"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 )
@@ -18,4 +19,10 @@ var _ = Describe("Stats", func() {
 		exp := []string{"bytes_read", "bytes_writes", "block_devices_used"}
 		Expect(len(ContainerMetricNames) >= len(exp)).To(BeTrue())
 	})
+
+	It("Test getBPFStatCounters", func() {
+		counters := getBPFStatCounters([]string{config.CPUTime, config.IRQNetTXLabel, attacher.CPUMigrationLabel, attacher.PageFaultMajorLabel, attacher.NetRxBytesLabel})
+		Expect(counters).To(Equal([]string{attacher.CPUMigrationLabel, attacher.PageFaultMajorLabel, attacher.NetRxBytesLabel}))
+		Expect(getBPFStatCounters([]string{config.CPUTime})).To(BeEmpty())
+	})
 })
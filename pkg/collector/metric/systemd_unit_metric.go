@@ -0,0 +1,44 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metric
+
+import (
+	"fmt"
+)
+
+// SystemdUnitMetrics holds the energy consumption of a systemd unit, which is the sum of the energy of its processes
+type SystemdUnitMetrics struct {
+	Unit  string
+	Slice string
+	// CgroupPath is the cgroup path of the unit, the unit is kept while its cgroup exists
+	CgroupPath string
+
+	AggregatedEnergy
+}
+
+// NewSystemdUnitMetrics creates a new SystemdUnitMetrics instance
+func NewSystemdUnitMetrics(unit, slice string) *SystemdUnitMetrics {
+	return &SystemdUnitMetrics{
+		Unit:             unit,
+		Slice:            slice,
+		AggregatedEnergy: NewAggregatedEnergy(),
+	}
+}
+
+func (s *SystemdUnitMetrics) String() string {
+	return fmt.Sprintf("energy from systemd unit: %s slice: %s\n%s", s.Unit, s.Slice, &s.AggregatedEnergy)
+}
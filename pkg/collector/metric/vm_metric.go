@@ -0,0 +1,44 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metric
+
+import (
+	"fmt"
+)
+
+// VMMetrics holds the energy consumption of a virtual machine, which is the sum of the energy of its QEMU processes
+type VMMetrics struct {
+	Name string
+	UUID string
+	// CgroupPath is the cgroup path of the QEMU scope, the VM is kept while its cgroup exists
+	CgroupPath string
+
+	AggregatedEnergy
+}
+
+// NewVMMetrics creates a new VMMetrics instance
+func NewVMMetrics(name, uuid string) *VMMetrics {
+	return &VMMetrics{
+		Name:             name,
+		UUID:             uuid,
+		AggregatedEnergy: NewAggregatedEnergy(),
+	}
+}
+
+func (v *VMMetrics) String() string {
+	return fmt.Sprintf("energy from vm name: %s uuid: %s\n%s", v.Name, v.UUID, &v.AggregatedEnergy)
+}
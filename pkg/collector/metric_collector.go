@@ -25,6 +25,8 @@ import (
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/power/accelerator"
 	"github.com/sustainable-computing-io/kepler/pkg/power/acpi"
+	"github.com/sustainable-computing-io/kepler/pkg/power/calibration"
+	"github.com/sustainable-computing-io/kepler/pkg/power/redfish"
 	"github.com/sustainable-computing-io/kepler/pkg/utils"
 
 	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
@@ -43,6 +45,8 @@ type Collector struct {
 	bpfHCMeter *attacher.BpfModuleTables
 	// instance that collects the node energy consumption
 	acpiPowerMeter *acpi.ACPI
+	// instance that collects the node energy consumption from the BMC
+	redfishPowerMeter *redfish.Redfish
 
 	// NodeMetrics holds all node energy and resource usage metrics
 	NodeMetrics collector_metric.NodeMetrics
@@ -53,6 +57,20 @@ type Collector struct {
 	// ProcessMetrics hold all process energy and resource usage metrics
 	ProcessMetrics map[uint64]*collector_metric.ProcessMetrics
 
+	// SystemdUnitsMetrics holds the energy of the systemd units, aggregated from the process metrics
+	SystemdUnitsMetrics map[string]*collector_metric.SystemdUnitMetrics
+
+	// VMsMetrics holds the energy of the virtual machines, aggregated from the QEMU process metrics
+	VMsMetrics map[string]*collector_metric.VMMetrics
+
+	// PodsMetrics holds the energy of all pods, aggregated from the energy of their containers
+	PodsMetrics map[string]*collector_metric.PodMetrics
+
+	// checkpointedContainers holds the energy restored from the checkpoint of the containers not found since the restart
+	checkpointedContainers map[string]containerCheckpoint
+	// lastEnergyCheckpoint is the time of the last write of the energy checkpoint
+	lastEnergyCheckpoint time.Time
+
 	// generic names to be used for process that are not within a pod
 	systemProcessName      string
 	systemProcessNamespace string
@@ -60,33 +78,56 @@ type Collector struct {
 
 func NewCollector() *Collector {
 	c := &Collector{
-		acpiPowerMeter:         acpi.NewACPIPowerMeter(),
+		acpiPowerMeter: acpi.NewACPIPowerMeter(),
+		redfishPowerMeter: redfish.NewRedfishPowerMeter(config.RedfishEndpoint, config.RedfishUsername, config.RedfishPassword,
+			config.RedfishSkipSSLVerify, time.Duration(config.RedfishProbeIntervalInSeconds)*time.Second),
 		NodeMetrics:            *collector_metric.NewNodeMetrics(),
 		ContainersMetrics:      map[string]*collector_metric.ContainerMetrics{},
 		ProcessMetrics:         map[uint64]*collector_metric.ProcessMetrics{},
+		PodsMetrics:            map[string]*collector_metric.PodMetrics{},
+		SystemdUnitsMetrics:    map[string]*collector_metric.SystemdUnitMetrics{},
+		VMsMetrics:             map[string]*collector_metric.VMMetrics{},
 		systemProcessName:      utils.SystemProcessName,
 		systemProcessNamespace: utils.SystemProcessNamespace,
 	}
+	c.NodeMetrics.PlatformPowerSource = acpiPlatformSource
 	return c
 }
 
 func (c *Collector) Initialize() error {
 	m, err := attacher.AttachBPFAssets()
 	if err != nil {
-		return fmt.Errorf("failed to attach bpf assets: %v", err)
+		if !config.EnableCgroupDiscovery {
+			return fmt.Errorf("failed to attach bpf assets: %v", err)
+		}
+		// on locked-down kernels the containers are found by walking the cgroup hierarchy and their usage is read from the cgroup stats
+		klog.Warningf("failed to attach bpf assets, discovering the containers from the cgroups: %v", err)
+		selectCgroupUsageMetrics()
 	}
 	c.bpfHCMeter = m
 
 	pods, err := cgroup.Init()
-	if err != nil && !config.EnableProcessMetrics {
+	// without kubelet the containers can still be resolved from the container runtime
+	if err != nil && !config.EnableProcessMetrics && !cgroup.HasContainerRuntimeResolver() {
 		klog.V(5).Infoln(err)
 		return err
 	}
 
 	c.prePopulateContainerMetrics(pods)
+	if config.EnableIdlePowerCalibration {
+		c.NodeMetrics.IdleCalibrator = calibration.NewIdlePowerCalibrator(config.IdlePowerCalibrationWindow,
+			config.IdlePowerCalibrationFile, config.IdlePowerOverrideFile)
+	}
+	c.NodeMetrics.SetUpdateTime(time.Now())
+	if config.EnergyCheckpointFile != "" {
+		c.restoreEnergyCheckpoint(time.Now())
+	}
 	c.updateNodeEnergyMetrics()
-	c.acpiPowerMeter.Run(attacher.HardwareCountersEnabled)
-	c.resetBPFTables()
+	c.acpiPowerMeter.Run(attacher.HardwareCountersEnabled && c.bpfHCMeter != nil)
+	c.redfishPowerMeter.Run()
+	if c.bpfHCMeter != nil {
+		c.resetBPFTables()
+	}
 
 	return nil
 }
@@ -95,11 +136,20 @@ func (c *Collector) Destroy() {
 	if c.bpfHCMeter != nil {
 		attacher.DetachBPFModules(c.bpfHCMeter)
 	}
+	c.redfishPowerMeter.Stop()
+	c.saveEnergyCheckpoint(time.Now(), true)
+	if c.NodeMetrics.IdleCalibrator != nil {
+		if err := c.NodeMetrics.IdleCalibrator.Save(); err != nil {
+			klog.Infof("failed to save the idle power calibration state: %v", err)
+		}
+	}
 }
 
 // Update updates the node and container energy and resource usage metrics
 func (c *Collector) Update() {
 	start := time.Now()
+	// the energy is integrated over the measured elapsed time since the ticker can be delayed
+	c.NodeMetrics.SetUpdateTime(start)
 
 	// reset the previous collected value because not all containers will have new data
 	// that is, a container that was inactive will not have any update but we need to set its metrics to 0
@@ -108,9 +158,12 @@ func (c *Collector) Update() {
 	// update container metrics regarding the resource utilization to be used to calculate the energy consumption
 	// we first updates the bpf which is resposible to include new containers in the ContainersMetrics collection
 	// the bpf collects metrics per processes and then map the process ids to container ids
-	// TODO: when bpf is not running, the ContainersMetrics will not be updated with new containers.
-	// The ContainersMetrics will only have the containers that were identified during the initialization (initContainersMetrics)
-	c.updateBPFMetrics() // collect new hardware counter metrics if possible
+	// when bpf is not running, the containers are discovered from the cgroup hierarchy instead
+	if c.bpfHCMeter != nil {
+		c.updateBPFMetrics() // collect new hardware counter metrics if possible
+	} else if config.EnableCgroupDiscovery {
+		c.updateCgroupDiscovery()
+	}
 
 	// TODO: collect cgroup metrics only from cgroup to avoid unnecessary overhead to kubelet
 	c.updateCgroupMetrics()  // collect new cgroup metrics from cgroup
@@ -126,10 +179,20 @@ func (c *Collector) Update() {
 
 	// calculate the container energy consumption using its resource utilization and the node components energy consumption
 	c.updateContainerEnergy()
+	if len(c.checkpointedContainers) > 0 {
+		c.restoreContainersEnergy()
+	}
+	c.updatePodEnergy()
 
 	// calculate the process energy consumption using its resource utilization and the node components energy consumption
 	if config.EnableProcessMetrics {
 		c.updateProcessEnergy()
+		if config.ExposeSystemdUnitMetrics {
+			c.updateSystemdUnitEnergy()
+		}
+		if config.ExposeVMMetrics {
+			c.updateVMEnergy()
+		}
 	}
 
 	// check the log verbosity level before iterating in all container
@@ -139,6 +202,7 @@ func (c *Collector) Update() {
 		}
 		klog.V(3).Infoln(c.NodeMetrics.String())
 	}
+	c.saveEnergyCheckpoint(start, false)
 	klog.V(2).Infof("Collector Update elapsed time: %s", time.Since(start))
 }
 
@@ -150,6 +214,15 @@ func (c *Collector) resetDeltaValue() {
 	for _, v := range c.ProcessMetrics {
 		v.ResetDeltaValues()
 	}
+	for _, v := range c.PodsMetrics {
+		v.ResetDeltaValues()
+	}
+	for _, v := range c.SystemdUnitsMetrics {
+		v.ResetDeltaValues()
+	}
+	for _, v := range c.VMsMetrics {
+		v.ResetDeltaValues()
+	}
 	c.NodeMetrics.ResetDeltaValues()
 }
 
@@ -166,16 +239,45 @@ func (c *Collector) prePopulateContainerMetrics(pods *[]corev1.Pod) {
 			container := pod.Status.InitContainerStatuses[j]
 			containerID := cgroup.ParseContainerIDFromPodStatus(container.ContainerID)
 			c.ContainersMetrics[containerID] = collector_metric.NewContainerMetrics(container.Name, pod.Name, pod.Namespace)
+			setContainerPodInfo(c.ContainersMetrics[containerID], cgroup.NewContainerInfo(containerID, container.Name, &pod))
 		}
 		for j := 0; j < len(pod.Status.ContainerStatuses); j++ {
 			container := pod.Status.ContainerStatuses[j]
 			containerID := cgroup.ParseContainerIDFromPodStatus(container.ContainerID)
 			c.ContainersMetrics[containerID] = collector_metric.NewContainerMetrics(container.Name, pod.Name, pod.Namespace)
+			setContainerPodInfo(c.ContainersMetrics[containerID], cgroup.NewContainerInfo(containerID, container.Name, &pod))
 		}
 		for j := 0; j < len(pod.Status.EphemeralContainerStatuses); j++ {
 			container := pod.Status.EphemeralContainerStatuses[j]
 			containerID := cgroup.ParseContainerIDFromPodStatus(container.ContainerID)
 			c.ContainersMetrics[containerID] = collector_metric.NewContainerMetrics(container.Name, pod.Name, pod.Namespace)
+			setContainerPodInfo(c.ContainersMetrics[containerID], cgroup.NewContainerInfo(containerID, container.Name, &pod))
+		}
+	}
+}
+
+// selectCgroupUsageMetrics replaces the usage metrics of the ratio model that are collected by bpf with the cgroup metrics
+func selectCgroupUsageMetrics() {
+	if !isCgroupMetric(config.CoreUsageMetric) && isCgroupMetric(config.CgroupfsCPU) {
+		klog.Infof("using %s instead of %s as core usage metric", config.CgroupfsCPU, config.CoreUsageMetric)
+		config.CoreUsageMetric = config.CgroupfsCPU
+	}
+	if !isCgroupMetric(config.DRAMUsageMetric) && isCgroupMetric(config.CgroupfsMemory) {
+		klog.Infof("using %s instead of %s as dram usage metric", config.CgroupfsMemory, config.DRAMUsageMetric)
+		config.DRAMUsageMetric = config.CgroupfsMemory
+	}
+}
+
+func isCgroupMetric(metric string) bool {
+	for _, m := range collector_metric.AvailableCgroupMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	for _, m := range collector_metric.AvailableKubeletMetrics {
+		if m == metric {
+			return true
 		}
 	}
+	return false
 }
@@ -1,6 +1,10 @@
 package collector
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
@@ -169,4 +173,212 @@ var _ = Describe("Test Collector Unit", func() {
 		Expect(len(metricCollector.ContainersMetrics)).Should(Equal(2))
 	})
 
+	It("Aggregate the container energy per pod across container restarts", func() {
+		metricCollector = newMockCollector()
+		metricCollector.ContainersMetrics["containerA"].PodUID = "uidA"
+		metricCollector.ContainersMetrics["containerA"].QOSClass = "Burstable"
+		metricCollector.ContainersMetrics["containerA"].OwnerKind = "ReplicaSet"
+		metricCollector.ContainersMetrics["containerA"].OwnerName = "rsA"
+		err := metricCollector.ContainersMetrics["containerA"].DynEnergyInPkg.AddNewDelta(10)
+		Expect(err).NotTo(HaveOccurred())
+		// the system processes are not exported as a pod
+		metricCollector.ContainersMetrics[metricCollector.systemProcessName] = createMockContainerMetrics(metricCollector.systemProcessName, metricCollector.systemProcessName, metricCollector.systemProcessNamespace)
+		metricCollector.updatePodEnergy()
+		Expect(len(metricCollector.PodsMetrics)).Should(Equal(2))
+		podA := metricCollector.PodsMetrics["uidA"]
+		Expect(podA).NotTo(BeNil())
+		Expect(podA.PodName).To(Equal("podA"))
+		Expect(podA.QOSClass).To(Equal("Burstable"))
+		Expect(podA.OwnerKind).To(Equal("ReplicaSet"))
+		Expect(podA.DynEnergyInPkg.Aggr).To(Equal(uint64(10)))
+		// containers without pod UID are aggregated by namespace and pod name
+		Expect(metricCollector.PodsMetrics["test/podB"]).NotTo(BeNil())
+
+		// the restarted container has a new ID and starts with zero energy, the pod energy keeps increasing
+		metricCollector.resetDeltaValue()
+		delete(metricCollector.ContainersMetrics, "containerA")
+		restarted := createMockContainerMetrics("containerA", "podA", "test")
+		restarted.PodUID = "uidA"
+		err = restarted.DynEnergyInPkg.AddNewDelta(5)
+		Expect(err).NotTo(HaveOccurred())
+		metricCollector.ContainersMetrics["containerA-restarted"] = restarted
+		metricCollector.updatePodEnergy()
+		Expect(podA.DynEnergyInPkg.Delta).To(Equal(uint64(5)))
+		Expect(podA.DynEnergyInPkg.Aggr).To(Equal(uint64(15)))
+
+		// the pod is removed when it has no container anymore
+		delete(metricCollector.ContainersMetrics, "containerB")
+		metricCollector.updatePodEnergy()
+		Expect(metricCollector.PodsMetrics).NotTo(HaveKey("test/podB"))
+		Expect(metricCollector.PodsMetrics).To(HaveKey("uidA"))
+	})
+
+	It("Aggregate the process energy per systemd unit", func() {
+		metricCollector = newMockCollector()
+		sshd := collector_metric.NewProcessMetrics(1, "sshd")
+		sshd.SystemdUnit, sshd.SystemdSlice = "sshd.service", "system.slice"
+		session := collector_metric.NewProcessMetrics(2, "bash")
+		session.SystemdUnit, session.SystemdSlice = "session-2.scope", "user-1000.slice"
+		// the processes whose unit is not resolved are not aggregated
+		unknown := collector_metric.NewProcessMetrics(3, "exited")
+		Expect(sshd.DynEnergyInPkg.AddNewDelta(10)).To(Succeed())
+		Expect(session.DynEnergyInPkg.AddNewDelta(5)).To(Succeed())
+		metricCollector.ProcessMetrics[1] = sshd
+		metricCollector.ProcessMetrics[2] = session
+		metricCollector.ProcessMetrics[3] = unknown
+		metricCollector.updateSystemdUnitEnergy()
+		Expect(metricCollector.SystemdUnitsMetrics).To(HaveLen(2))
+		Expect(metricCollector.SystemdUnitsMetrics["sshd.service"].DynEnergyInPkg.Aggr).To(Equal(uint64(10)))
+		Expect(metricCollector.SystemdUnitsMetrics["session-2.scope"].Slice).To(Equal("user-1000.slice"))
+
+		// a new process of the unit keeps increasing the unit energy
+		metricCollector.resetDeltaValue()
+		delete(metricCollector.ProcessMetrics, 1)
+		sshdChild := collector_metric.NewProcessMetrics(4, "sshd")
+		sshdChild.SystemdUnit, sshdChild.SystemdSlice = "sshd.service", "system.slice"
+		Expect(sshdChild.DynEnergyInPkg.AddNewDelta(3)).To(Succeed())
+		metricCollector.ProcessMetrics[4] = sshdChild
+		metricCollector.updateSystemdUnitEnergy()
+		Expect(metricCollector.SystemdUnitsMetrics["sshd.service"].DynEnergyInPkg.Aggr).To(Equal(uint64(13)))
+
+		// the unit is removed when it has no process anymore and its cgroup is removed
+		delete(metricCollector.ProcessMetrics, 2)
+		metricCollector.updateSystemdUnitEnergy()
+		Expect(metricCollector.SystemdUnitsMetrics).NotTo(HaveKey("session-2.scope"))
+	})
+
+	It("Keep the systemd unit energy while the unit cgroup exists", func() {
+		defer func() { systemdUnitExists = cgroup.SystemdUnitExists }()
+		runningUnits := map[string]bool{"/system.slice/sshd.service": true}
+		systemdUnitExists = func(path string) bool { return runningUnits[path] }
+
+		metricCollector = newMockCollector()
+		sshd := collector_metric.NewProcessMetrics(1, "sshd")
+		sshd.SystemdUnit, sshd.SystemdSlice, sshd.SystemdUnitPath = "sshd.service", "system.slice", "/system.slice/sshd.service"
+		Expect(sshd.DynEnergyInPkg.AddNewDelta(10)).To(Succeed())
+		metricCollector.ProcessMetrics[1] = sshd
+		metricCollector.updateSystemdUnitEnergy()
+
+		// the idle process is removed from the process metrics, the unit keeps its energy
+		metricCollector.resetDeltaValue()
+		delete(metricCollector.ProcessMetrics, 1)
+		metricCollector.updateSystemdUnitEnergy()
+		Expect(metricCollector.SystemdUnitsMetrics).To(HaveKey("sshd.service"))
+
+		sshd = collector_metric.NewProcessMetrics(1, "sshd")
+		sshd.SystemdUnit, sshd.SystemdSlice, sshd.SystemdUnitPath = "sshd.service", "system.slice", "/system.slice/sshd.service"
+		Expect(sshd.DynEnergyInPkg.AddNewDelta(5)).To(Succeed())
+		metricCollector.ProcessMetrics[1] = sshd
+		metricCollector.updateSystemdUnitEnergy()
+		Expect(metricCollector.SystemdUnitsMetrics["sshd.service"].DynEnergyInPkg.Aggr).To(Equal(uint64(15)))
+
+		// the stopped unit is removed
+		metricCollector.resetDeltaValue()
+		delete(metricCollector.ProcessMetrics, 1)
+		delete(runningUnits, "/system.slice/sshd.service")
+		metricCollector.updateSystemdUnitEnergy()
+		Expect(metricCollector.SystemdUnitsMetrics).NotTo(HaveKey("sshd.service"))
+	})
+
+	It("Aggregate the QEMU process energy per virtual machine", func() {
+		metricCollector = newMockCollector()
+		vcpu0 := collector_metric.NewProcessMetrics(1, "CPU 0/KVM")
+		vcpu0.SystemdUnit, vcpu0.SystemdSlice = `machine-qemu\x2d1\x2ddb\x2d01.scope`, "machine.slice"
+		vcpu1 := collector_metric.NewProcessMetrics(2, "CPU 1/KVM")
+		vcpu1.SystemdUnit, vcpu1.SystemdSlice = `machine-qemu\x2d1\x2ddb\x2d01.scope`, "machine.slice"
+		sshd := collector_metric.NewProcessMetrics(3, "sshd")
+		sshd.SystemdUnit, sshd.SystemdSlice = "sshd.service", "system.slice"
+		Expect(vcpu0.DynEnergyInPkg.AddNewDelta(10)).To(Succeed())
+		Expect(vcpu1.DynEnergyInPkg.AddNewDelta(5)).To(Succeed())
+		Expect(sshd.DynEnergyInPkg.AddNewDelta(1)).To(Succeed())
+		metricCollector.ProcessMetrics[1] = vcpu0
+		metricCollector.ProcessMetrics[2] = vcpu1
+		metricCollector.ProcessMetrics[3] = sshd
+		metricCollector.updateVMEnergy()
+		Expect(metricCollector.VMsMetrics).To(HaveLen(1))
+		Expect(metricCollector.VMsMetrics).To(HaveKey("db-01"))
+		Expect(metricCollector.VMsMetrics["db-01"].DynEnergyInPkg.Aggr).To(Equal(uint64(15)))
+
+		// the VM is removed when its QEMU processes are gone
+		delete(metricCollector.ProcessMetrics, 1)
+		delete(metricCollector.ProcessMetrics, 2)
+		metricCollector.resetDeltaValue()
+		metricCollector.updateVMEnergy()
+		Expect(metricCollector.VMsMetrics).To(BeEmpty())
+	})
+
+	It("Keep the virtual machine energy while the QEMU scope cgroup exists", func() {
+		scope := `/machine.slice/machine-qemu\x2d1\x2ddb\x2d01.scope`
+		defer func() { systemdUnitExists = cgroup.SystemdUnitExists }()
+		runningUnits := map[string]bool{scope: true}
+		systemdUnitExists = func(path string) bool { return runningUnits[path] }
+
+		metricCollector = newMockCollector()
+		newVCPU := func(energy uint64) *collector_metric.ProcessMetrics {
+			vcpu := collector_metric.NewProcessMetrics(1, "CPU 0/KVM")
+			vcpu.SystemdUnit, vcpu.SystemdSlice, vcpu.SystemdUnitPath = `machine-qemu\x2d1\x2ddb\x2d01.scope`, "machine.slice", scope
+			Expect(vcpu.DynEnergyInPkg.AddNewDelta(energy)).To(Succeed())
+			return vcpu
+		}
+		metricCollector.ProcessMetrics[1] = newVCPU(10)
+		metricCollector.updateVMEnergy()
+
+		// the idle QEMU process is pruned from the process metrics, the VM keeps its energy
+		metricCollector.resetDeltaValue()
+		delete(metricCollector.ProcessMetrics, 1)
+		metricCollector.updateVMEnergy()
+		Expect(metricCollector.VMsMetrics).To(HaveKey("db-01"))
+
+		metricCollector.ProcessMetrics[1] = newVCPU(5)
+		metricCollector.updateVMEnergy()
+		Expect(metricCollector.VMsMetrics["db-01"].DynEnergyInPkg.Aggr).To(Equal(uint64(15)))
+
+		// the VM is removed once it is shut down
+		metricCollector.resetDeltaValue()
+		delete(metricCollector.ProcessMetrics, 1)
+		delete(runningUnits, scope)
+		metricCollector.updateVMEnergy()
+		Expect(metricCollector.VMsMetrics).To(BeEmpty())
+	})
+
+	It("Discover the containers from the cgroups without bpf", func() {
+		metricCollector = newMockCollector()
+		metricCollector.ContainersMetrics[metricCollector.systemProcessName] = createMockContainerMetrics(metricCollector.systemProcessName, metricCollector.systemProcessName, metricCollector.systemProcessNamespace)
+		dir := GinkgoT().TempDir()
+		unknownID := strings.Repeat("f", 64)
+		Expect(os.MkdirAll(filepath.Join(dir, "kubepods-pod1.slice", "cri-containerd-"+unknownID+".scope"), 0o755)).To(Succeed())
+		sliceHandler := cgroup.SliceHandlerInstance
+		defer func() { cgroup.SliceHandlerInstance = sliceHandler }()
+		cgroup.SliceHandlerInstance = &cgroup.SliceHandler{CPUTopPath: dir}
+
+		metricCollector.updateCgroupDiscovery()
+		// the containers whose cgroup was removed are deleted and the unknown containers are left to the system processes
+		Expect(metricCollector.ContainersMetrics).To(HaveLen(1))
+		Expect(metricCollector.ContainersMetrics).To(HaveKey(metricCollector.systemProcessName))
+
+		// the container is added once its pod is known
+		defer func() { getDiscoveredContainerInfo = cgroup.GetContainerInfoByID }()
+		getDiscoveredContainerInfo = func(containerID string) *cgroup.ContainerInfo {
+			return &cgroup.ContainerInfo{ContainerID: containerID, ContainerName: "app", PodName: "pod1", Namespace: "default"}
+		}
+		metricCollector.updateCgroupDiscovery()
+		Expect(metricCollector.ContainersMetrics).To(HaveLen(2))
+		Expect(metricCollector.ContainersMetrics).To(HaveKey(unknownID))
+		Expect(metricCollector.ContainersMetrics[unknownID].PodName).To(Equal("pod1"))
+	})
+
+	It("Select the cgroup usage metrics without bpf", func() {
+		coreUsageMetric, dramUsageMetric := config.CoreUsageMetric, config.DRAMUsageMetric
+		defer func() { config.CoreUsageMetric, config.DRAMUsageMetric = coreUsageMetric, dramUsageMetric }()
+		config.CoreUsageMetric, config.DRAMUsageMetric = config.CPUInstruction, config.CacheMiss
+		selectCgroupUsageMetrics()
+		Expect(config.CoreUsageMetric).To(Equal(config.CgroupfsCPU))
+		Expect(config.DRAMUsageMetric).To(Equal(config.CgroupfsMemory))
+
+		// the configured cgroup metrics are kept
+		config.CoreUsageMetric = config.KubeletContainerCPU
+		selectCgroupUsageMetrics()
+		Expect(config.CoreUsageMetric).To(Equal(config.KubeletContainerCPU))
+	})
+
 })
@@ -18,6 +18,7 @@ package collector
 
 import (
 	"encoding/binary"
+	"math"
 	"sync"
 
 	"github.com/sustainable-computing-io/kepler/pkg/bpfassets/attacher"
@@ -30,6 +31,13 @@ import (
 	"k8s.io/klog/v2"
 )
 
+const (
+	// platform power sources, used as the source label of the node platform energy metric
+	acpiPlatformSource      = "acpi"
+	redfishPlatformSource   = "redfish"
+	estimatorPlatformSource = "estimator"
+)
+
 // updateNodeResourceUsage updates node resource usage with the total container resource usage
 // The container metrics are for the kubernetes containers and system/OS processes
 // TODO: verify if the cgroup metrics are also accounting for the OS, not only containers
@@ -41,10 +49,16 @@ func (c *Collector) updateNodeResourceUsage() {
 func (c *Collector) updatePlatformEnergy(wg *sync.WaitGroup) {
 	defer wg.Done()
 	nodePlatformEnergy := map[string]float64{}
-	if c.acpiPowerMeter.IsPowerSupported() {
+	// the BMC is only used if it was explicitly configured, so it has precedence over ACPI
+	if c.redfishPowerMeter.IsPowerSupported() {
+		nodePlatformEnergy, _ = c.redfishPowerMeter.GetEnergyFromHost()
+		c.NodeMetrics.PlatformPowerSource = redfishPlatformSource
+	} else if c.acpiPowerMeter.IsPowerSupported() {
 		nodePlatformEnergy, _ = c.acpiPowerMeter.GetEnergyFromHost()
+		c.NodeMetrics.PlatformPowerSource = acpiPlatformSource
 	} else if model.IsNodePlatformPowerModelEnabled() {
 		nodePlatformEnergy = model.GetEstimatedNodePlatformPower(&c.NodeMetrics)
+		c.NodeMetrics.PlatformPowerSource = estimatorPlatformSource
 	}
 	c.NodeMetrics.SetLastestPlatformEnergy(nodePlatformEnergy)
 }
@@ -56,6 +70,7 @@ func (c *Collector) updateNodeComponentsEnergy(wg *sync.WaitGroup) {
 	if components.IsSystemCollectionSupported() {
 		klog.V(5).Info("System energy collection is supported")
 		nodeComponentsEnergy = components.GetNodeComponentsEnergy()
+		c.NodeMetrics.SetNodeCPUEnergy(components.GetPerCPUEnergy())
 	} else if model.IsNodeComponentPowerModelEnabled() {
 		klog.V(5).Info("Node components power model collection is supported")
 		nodeComponentsEnergy = model.GetNodeComponentPowers(&c.NodeMetrics)
@@ -69,7 +84,12 @@ func (c *Collector) updateNodeComponentsEnergy(wg *sync.WaitGroup) {
 func (c *Collector) updateNodeGPUEnergy(wg *sync.WaitGroup) {
 	defer wg.Done()
 	if config.EnabledGPU {
-		gpuEnergy := accelerator.GetGpuEnergyPerGPU()
+		// the GPU reports the current power in mW, the energy in mJ is the power integrated over the elapsed time
+		elapsedSec := c.NodeMetrics.GetElapsedSec()
+		gpuEnergy := []uint32{}
+		for _, power := range accelerator.GetGpuEnergyPerGPU() {
+			gpuEnergy = append(gpuEnergy, uint32(math.Round(float64(power)*elapsedSec)))
+		}
 		c.NodeMetrics.AddNodeGPUEnergy(gpuEnergy)
 	}
 }
@@ -78,7 +98,7 @@ func (c *Collector) updateNodeGPUEnergy(wg *sync.WaitGroup) {
 func (c *Collector) updateNodeAvgCPUFrequency(wg *sync.WaitGroup) {
 	defer wg.Done()
 	// update the cpu frequency using hardware counters when available because reading files can be very expensive
-	if attacher.HardwareCountersEnabled {
+	if attacher.HardwareCountersEnabled && c.bpfHCMeter != nil {
 		cpuFreq := map[int32]uint64{}
 		for it := c.bpfHCMeter.CPUFreqTable.Iter(); it.Next(); {
 			cpu := int32(binary.LittleEndian.Uint32(it.Key()))
@@ -105,4 +125,7 @@ func (c *Collector) updateNodeEnergyMetrics() {
 	c.NodeMetrics.UpdateIdleEnergy()
 	c.NodeMetrics.UpdateDynEnergy()
 	c.NodeMetrics.SetNodeOtherComponentsEnergy()
+	if components.IsSystemCollectionSupported() {
+		c.NodeMetrics.SetRAPLPowerLimits(components.GetPowerLimits())
+	}
 }
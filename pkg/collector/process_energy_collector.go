@@ -17,10 +17,78 @@ limitations under the License.
 package collector
 
 import (
+	"github.com/sustainable-computing-io/kepler/pkg/cgroup"
+	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
 	"github.com/sustainable-computing-io/kepler/pkg/model"
+	"k8s.io/klog/v2"
 )
 
 // updateProcessEnergy matches the process resource usage with the node energy consumption
 func (c *Collector) updateProcessEnergy() {
-	model.UpdateProcessEnergy(c.ProcessMetrics, c.ContainersMetrics[c.systemProcessName])
+	model.UpdateProcessEnergy(c.ProcessMetrics, c.ContainersMetrics[c.systemProcessName], c.NodeMetrics.GetElapsedSec())
+}
+
+// systemdUnitExists is replaced in the tests, which have no systemd cgroups
+var systemdUnitExists = cgroup.SystemdUnitExists
+
+// updateSystemdUnitEnergy aggregates the process energy per systemd unit.
+// The idle processes are removed from the ProcessMetrics, so a unit without process is only removed when its cgroup
+// does not exist anymore, i.e. when the unit is stopped.
+func (c *Collector) updateSystemdUnitEnergy() {
+	aliveUnits := map[string]bool{}
+	for _, process := range c.ProcessMetrics {
+		if process.SystemdUnit == "" {
+			continue
+		}
+		unit := process.SystemdUnit
+		aliveUnits[unit] = true
+		if _, ok := c.SystemdUnitsMetrics[unit]; !ok {
+			c.SystemdUnitsMetrics[unit] = collector_metric.NewSystemdUnitMetrics(unit, process.SystemdSlice)
+			c.SystemdUnitsMetrics[unit].CgroupPath = process.SystemdUnitPath
+		}
+		if err := c.SystemdUnitsMetrics[unit].AddProcessEnergy(process); err != nil {
+			klog.V(5).Infof("failed to add the energy of pid %d to systemd unit %s: %v", process.PID, unit, err)
+		}
+	}
+	for unit, metrics := range c.SystemdUnitsMetrics {
+		if !aliveUnits[unit] && (metrics.CgroupPath == "" || !systemdUnitExists(metrics.CgroupPath)) {
+			delete(c.SystemdUnitsMetrics, unit)
+		}
+	}
+}
+
+// updateVMEnergy aggregates the energy of the QEMU processes per virtual machine.
+// The VMs are identified by their UUID when the libvirt domain is found, otherwise by their name.
+// Like the systemd units, a VM without process is only removed when the cgroup of its QEMU scope does not exist anymore.
+func (c *Collector) updateVMEnergy() {
+	aliveVMs := map[string]bool{}
+	aliveUnits := map[string]bool{}
+	for _, process := range c.ProcessMetrics {
+		if process.SystemdUnit == "" {
+			continue
+		}
+		aliveUnits[process.SystemdUnit] = true
+		vm := cgroup.GetVMInfo(process.SystemdUnit)
+		if vm == nil {
+			continue
+		}
+		vmKey := vm.UUID
+		if vmKey == "" {
+			vmKey = vm.Name
+		}
+		aliveVMs[vmKey] = true
+		if _, ok := c.VMsMetrics[vmKey]; !ok {
+			c.VMsMetrics[vmKey] = collector_metric.NewVMMetrics(vm.Name, vm.UUID)
+			c.VMsMetrics[vmKey].CgroupPath = process.SystemdUnitPath
+		}
+		if err := c.VMsMetrics[vmKey].AddProcessEnergy(process); err != nil {
+			klog.V(5).Infof("failed to add the energy of pid %d to VM %s: %v", process.PID, vm.Name, err)
+		}
+	}
+	for vmKey, metrics := range c.VMsMetrics {
+		if !aliveVMs[vmKey] && (metrics.CgroupPath == "" || !systemdUnitExists(metrics.CgroupPath)) {
+			delete(c.VMsMetrics, vmKey)
+		}
+	}
+	cgroup.RetainVMs(aliveUnits)
 }
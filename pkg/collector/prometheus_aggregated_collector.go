@@ -0,0 +1,144 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+var (
+	systemdUnitEnergyLabels = []string{"unit", "slice", "mode"}
+	vmEnergyLabels          = []string{"vm_name", "vm_uuid", "mode"}
+)
+
+// aggregatedEnergyDesc describes the energy (counter) of a group of containers or processes, e.g. the pods or the systemd units
+type aggregatedEnergyDesc struct {
+	coreJoulesTotal            *prometheus.Desc
+	uncoreJoulesTotal          *prometheus.Desc
+	dramJoulesTotal            *prometheus.Desc
+	packageJoulesTotal         *prometheus.Desc
+	otherComponentsJoulesTotal *prometheus.Desc
+	gpuJoulesTotal             *prometheus.Desc
+	joulesTotal                *prometheus.Desc
+}
+
+// newAggregatedEnergyDesc creates the kepler_<subsystem>_*_joules_total metrics, members completes the help text, e.g. "all containers in the pod"
+func newAggregatedEnergyDesc(subsystem, members string, labels []string) *aggregatedEnergyDesc {
+	newDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help+" of "+members, labels, nil)
+	}
+	return &aggregatedEnergyDesc{
+		coreJoulesTotal:            newDesc("core_joules_total", "Aggregated RAPL value in core in joules"),
+		uncoreJoulesTotal:          newDesc("uncore_joules_total", "Aggregated RAPL value in uncore in joules"),
+		dramJoulesTotal:            newDesc("dram_joules_total", "Aggregated RAPL value in dram in joules"),
+		packageJoulesTotal:         newDesc("package_joules_total", "Aggregated RAPL value in package (socket) in joules"),
+		otherComponentsJoulesTotal: newDesc("other_host_components_joules_total", "Aggregated value in other host components (platform - package - dram) in joules"),
+		gpuJoulesTotal:             newDesc("gpu_joules_total", "Aggregated GPU value in joules"),
+		joulesTotal:                newDesc("joules_total", "Aggregated RAPL Package + Uncore + DRAM + GPU + other host components (platform - package - dram) in joules"),
+	}
+}
+
+// describe is called by Describe to implement the prometheus.Collector interface
+func (d *aggregatedEnergyDesc) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.coreJoulesTotal
+	ch <- d.uncoreJoulesTotal
+	ch <- d.dramJoulesTotal
+	ch <- d.packageJoulesTotal
+	ch <- d.otherComponentsJoulesTotal
+	if config.EnabledGPU {
+		ch <- d.gpuJoulesTotal
+	}
+	ch <- d.joulesTotal
+}
+
+// collect sends the dynamic and idle energy of a group, with the label values of each mode
+func (d *aggregatedEnergyDesc) collect(ch chan<- prometheus.Metric, energy *collector_metric.AggregatedEnergy, dynLabels, idleLabels []string) {
+	energies := []struct {
+		desc      *prometheus.Desc
+		dyn, idle *collector_metric.UInt64Stat
+		gpuMetric bool
+	}{
+		{d.coreJoulesTotal, energy.DynEnergyInCore, energy.IdleEnergyInCore, false},
+		{d.uncoreJoulesTotal, energy.DynEnergyInUncore, energy.IdleEnergyInUncore, false},
+		{d.dramJoulesTotal, energy.DynEnergyInDRAM, energy.IdleEnergyInDRAM, false},
+		{d.packageJoulesTotal, energy.DynEnergyInPkg, energy.IdleEnergyInPkg, false},
+		{d.otherComponentsJoulesTotal, energy.DynEnergyInOther, energy.IdleEnergyInOther, false},
+		{d.gpuJoulesTotal, energy.DynEnergyInGPU, energy.IdleEnergyInGPU, true},
+	}
+	for _, e := range energies {
+		if e.gpuMetric && !config.EnabledGPU {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(e.desc, prometheus.CounterValue, float64(e.dyn.Aggr)/miliJouleToJoule, dynLabels...)
+		ch <- prometheus.MustNewConstMetric(e.desc, prometheus.CounterValue, float64(e.idle.Aggr)/miliJouleToJoule, idleLabels...)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		d.joulesTotal,
+		prometheus.CounterValue,
+		(float64(energy.DynEnergyInPkg.Aggr)/miliJouleToJoule +
+			float64(energy.DynEnergyInUncore.Aggr)/miliJouleToJoule +
+			float64(energy.DynEnergyInDRAM.Aggr)/miliJouleToJoule +
+			float64(energy.DynEnergyInGPU.Aggr)/miliJouleToJoule +
+			float64(energy.DynEnergyInOther.Aggr)/miliJouleToJoule),
+		dynLabels...,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		d.joulesTotal,
+		prometheus.CounterValue,
+		(float64(energy.IdleEnergyInPkg.Aggr)/miliJouleToJoule +
+			float64(energy.IdleEnergyInUncore.Aggr)/miliJouleToJoule +
+			float64(energy.IdleEnergyInDRAM.Aggr)/miliJouleToJoule +
+			float64(energy.IdleEnergyInGPU.Aggr)/miliJouleToJoule +
+			float64(energy.IdleEnergyInOther.Aggr)/miliJouleToJoule),
+		idleLabels...,
+	)
+}
+
+// updateSystemdUnitMetrics send the energy of each systemd unit to prometheus
+func (p *PrometheusCollector) updateSystemdUnitMetrics(wg *sync.WaitGroup, ch chan<- prometheus.Metric) {
+	if p.SystemdUnitsMetrics == nil || !config.ExposeSystemdUnitMetrics {
+		return
+	}
+	for _, unit := range *p.SystemdUnitsMetrics {
+		wg.Add(1)
+		go func(unit *collector_metric.SystemdUnitMetrics) {
+			defer wg.Done()
+			p.systemdDesc.collect(ch, &unit.AggregatedEnergy,
+				[]string{unit.Unit, unit.Slice, "dynamic"}, []string{unit.Unit, unit.Slice, "idle"})
+		}(unit)
+	}
+}
+
+// updateVMMetrics send the energy of each virtual machine to prometheus
+func (p *PrometheusCollector) updateVMMetrics(wg *sync.WaitGroup, ch chan<- prometheus.Metric) {
+	if p.VMsMetrics == nil || !config.ExposeVMMetrics {
+		return
+	}
+	for _, vm := range *p.VMsMetrics {
+		wg.Add(1)
+		go func(vm *collector_metric.VMMetrics) {
+			defer wg.Done()
+			p.vmDesc.collect(ch, &vm.AggregatedEnergy,
+				[]string{vm.Name, vm.UUID, "dynamic"}, []string{vm.Name, vm.UUID, "idle"})
+		}(vm)
+	}
+}
@@ -19,6 +19,7 @@ package collector
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,6 +28,7 @@ import (
 	"github.com/sustainable-computing-io/kepler/pkg/cgroup"
 	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
 	"github.com/sustainable-computing-io/kepler/pkg/config"
+	"github.com/sustainable-computing-io/kepler/pkg/power/components"
 )
 
 const (
@@ -84,6 +86,16 @@ var (
 		"total_irq_net_tx",
 		"curr_irq_block",
 		"total_irq_block"}
+	podEnergyLabels = []string{
+		"pod_name",
+		"pod_namespace",
+		"pod_uid",
+		"qos_class",
+		"owner_kind",
+		"owner_name",
+		"mode"}
+	// podMetadataLabelNames are the metric labels of the allowed pod labels and annotations, set when the exporter is created
+	podMetadataLabelNames = []string{}
 )
 
 type NodeDesc struct {
@@ -103,6 +115,16 @@ type NodeDesc struct {
 	// TODO: review if we really need to expose this metric.
 	NodeCPUFrequency *prometheus.Desc
 
+	// RAPL power capping (gauge) and throttling (counter)
+	nodeRAPLPowerLimitWatts   *prometheus.Desc
+	nodeRAPLTimeWindowSeconds *prometheus.Desc
+	nodeRAPLPowerLimitEnabled *prometheus.Desc
+	nodeRAPLThrottlingTotal   *prometheus.Desc
+
+	// Idle power calibration (gauge)
+	nodeIdlePowerWatts       *prometheus.Desc
+	nodeIdlePowerFitRSquared *prometheus.Desc
+
 	// Old metric
 	// TODO: remove these metrics in the next release. The dependent components must stop to use this.
 	nodePackageMiliJoulesTotal *prometheus.Desc // deprecated
@@ -124,6 +146,8 @@ type ContainerDesc struct {
 	containerCPUCyclesTotal *prometheus.Desc
 	containerCPUInstrTotal  *prometheus.Desc
 	containerCacheMissTotal *prometheus.Desc
+	// Hardware Counters selected in addition to the default counters (counter), indexed by the counter
+	containerHWCounterTotal map[string]*prometheus.Desc
 
 	// cGroups (counter)
 	containerCgroupCPUUsageUsTotal       *prometheus.Desc
@@ -138,11 +162,16 @@ type ContainerDesc struct {
 	// Additional metrics (gauge)
 	// TODO: review if we really need to expose this metric. cgroup also has some sortof cpuTime metric
 	containerCPUTime *prometheus.Desc
+	// CPU time per CPU package (counter)
+	containerPackageCPUTime *prometheus.Desc
 
 	// IRQ metrics
 	containerNetTxIRQTotal *prometheus.Desc
 	containerNetRxIRQTotal *prometheus.Desc
 	containerBlockIRQTotal *prometheus.Desc
+
+	// Task event and I/O metrics counted by BPF (counter), indexed by the counter
+	containerBPFStatTotal map[string]*prometheus.Desc
 }
 
 // metric used by the model server to train the model
@@ -153,6 +182,9 @@ type PodDesc struct {
 	// Hardware Counters (counter)
 	// The clever dashboard use the pod_cpu_instructions but should be updated later to container_cpu_instructions
 	podCPUInstrTotal *prometheus.Desc
+
+	// Energy (counter) aggregated from the pod containers
+	podEnergy *aggregatedEnergyDesc
 }
 
 // PrometheusCollector holds the list of prometheus metrics for both node and pod context
@@ -161,6 +193,8 @@ type PrometheusCollector struct {
 	containerDesc *ContainerDesc
 	podDesc       *PodDesc
 	processDesc   *processDesc
+	systemdDesc   *aggregatedEnergyDesc
+	vmDesc        *aggregatedEnergyDesc
 
 	// NodeMetrics holds all node energy and resource usage metrics
 	NodeMetrics *collector_metric.NodeMetrics
@@ -171,8 +205,14 @@ type PrometheusCollector struct {
 	// ProcessMetrics hold all process energy and resource usage metrics
 	ProcessMetrics *map[uint64]*collector_metric.ProcessMetrics
 
-	// SamplePeriodSec the collector metric collection interval
-	SamplePeriodSec float64
+	// PodsMetrics holds the energy of all pods
+	PodsMetrics *map[string]*collector_metric.PodMetrics
+
+	// SystemdUnitsMetrics holds the energy of all systemd units
+	SystemdUnitsMetrics *map[string]*collector_metric.SystemdUnitMetrics
+
+	// VMsMetrics holds the energy of all virtual machines
+	VMsMetrics *map[string]*collector_metric.VMMetrics
 
 	// Lock to syncronize the collector update with prometheus exporter
 	Mx sync.Mutex
@@ -191,7 +231,11 @@ func NewPrometheusExporter() *PrometheusCollector {
 		nodeDesc:    &NodeDesc{},
 		podDesc:     &PodDesc{},
 		processDesc: &processDesc{},
+		// Energy (counter) aggregated from the processes
+		systemdDesc: newAggregatedEnergyDesc("systemd_unit", "all processes in the systemd unit", systemdUnitEnergyLabels),
+		vmDesc:      newAggregatedEnergyDesc("vm", "all QEMU processes of the virtual machine", vmEnergyLabels),
 	}
+	podMetadataLabelNames = cgroup.GetPodMetadataLabelNames()
 	exporter.newNodeMetrics()
 	exporter.newContainerMetrics()
 	exporter.newPodMetrics()
@@ -218,6 +262,16 @@ func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 	// Additional Node metrics (gauge)
 	ch <- p.nodeDesc.NodeCPUFrequency
 
+	// Node RAPL power capping
+	ch <- p.nodeDesc.nodeRAPLPowerLimitWatts
+	ch <- p.nodeDesc.nodeRAPLTimeWindowSeconds
+	ch <- p.nodeDesc.nodeRAPLPowerLimitEnabled
+	ch <- p.nodeDesc.nodeRAPLThrottlingTotal
+
+	// Node idle power calibration
+	ch <- p.nodeDesc.nodeIdlePowerWatts
+	ch <- p.nodeDesc.nodeIdlePowerFitRSquared
+
 	// Old Node metric
 	ch <- p.nodeDesc.nodePackageMiliJoulesTotal
 	ch <- p.nodeDesc.NodeMetricsStat
@@ -239,6 +293,13 @@ func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 		ch <- p.containerDesc.containerCPUInstrTotal
 		ch <- p.containerDesc.containerCacheMissTotal
 	}
+	if config.ExposeHardwareCounterMetrics {
+		for _, counter := range collector_metric.AvailableHWCounters {
+			if desc, exists := p.containerDesc.containerHWCounterTotal[counter]; exists {
+				ch <- desc
+			}
+		}
+	}
 
 	// container cGroups Counters (counter)
 	if config.ExposeCgroupMetrics {
@@ -262,8 +323,12 @@ func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 		}
 	}
 
+	// Pod Energy (counter)
+	p.podDesc.podEnergy.describe(ch)
+
 	// Old Node metric
 	ch <- p.containerDesc.containerCPUTime
+	ch <- p.containerDesc.containerPackageCPUTime
 	ch <- p.podDesc.podEnergyStat
 
 	// IRQ counter
@@ -272,14 +337,24 @@ func (p *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 		ch <- p.containerDesc.containerNetRxIRQTotal
 		ch <- p.containerDesc.containerBlockIRQTotal
 	}
+	// task event counters
+	for _, counter := range collector_metric.AvailableBPFStatCounters {
+		ch <- p.containerDesc.containerBPFStatTotal[counter]
+	}
 	p.describeProcess(ch)
+	if config.ExposeSystemdUnitMetrics {
+		p.systemdDesc.describe(ch)
+	}
+	if config.ExposeVMMetrics {
+		p.vmDesc.describe(ch)
+	}
 }
 
 func (p *PrometheusCollector) newNodeMetrics() {
 	nodeInfo := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "node", "nodeInfo"),
 		"Labeled node information",
-		[]string{"cpu_architecture"}, nil,
+		[]string{"cpu_architecture", "power_source"}, nil,
 	)
 	// Energy (counter)
 	// TODO: separate the energy consumption per CPU, including the label cpu
@@ -326,6 +401,40 @@ func (p *PrometheusCollector) newNodeMetrics() {
 		[]string{"cpu", "instance"}, nil,
 	)
 
+	// RAPL power capping
+	nodeRAPLPowerLimitWatts := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "rapl_power_limit_watts"),
+		"Current RAPL power limit in watts",
+		[]string{"package", "domain", "constraint", "instance"}, nil,
+	)
+	nodeRAPLTimeWindowSeconds := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "rapl_time_window_seconds"),
+		"Current time window in seconds over which the RAPL power limit is enforced",
+		[]string{"package", "domain", "constraint", "instance"}, nil,
+	)
+	nodeRAPLPowerLimitEnabled := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "rapl_power_limit_enabled"),
+		"Whether the RAPL power limit is enforced (1) or not (0)",
+		[]string{"package", "domain", "instance"}, nil,
+	)
+	nodeRAPLThrottlingTotal := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "rapl_throttling_total"),
+		"Aggregated number of estimated throttling episodes, i.e. samples in which the RAPL domain average power reached 95% of its lowest enabled power limit, the hardware throttle status is not read",
+		[]string{"package", "domain", "instance"}, nil,
+	)
+
+	// Idle power calibration
+	nodeIdlePowerWatts := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "idle_power_watts"),
+		"Idle power in watts of the node component, fitted over the node utilization or set in the override file",
+		[]string{"component", "id", "instance", "source"}, nil,
+	)
+	nodeIdlePowerFitRSquared := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "idle_power_fit_r_squared"),
+		"Coefficient of determination of the regression of the node component power over the node utilization",
+		[]string{"component", "id", "instance"}, nil,
+	)
+
 	// Old metrics
 	nodePackageMiliJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "node", "package_energy_millijoule"),
@@ -348,6 +457,12 @@ func (p *PrometheusCollector) newNodeMetrics() {
 		nodeOtherComponentsJoulesTotal: nodeOtherComponentsJoulesTotal,
 		nodeGPUJoulesTotal:             nodeGPUJoulesTotal,
 		NodeCPUFrequency:               NodeCPUFrequency,
+		nodeRAPLPowerLimitWatts:        nodeRAPLPowerLimitWatts,
+		nodeRAPLTimeWindowSeconds:      nodeRAPLTimeWindowSeconds,
+		nodeRAPLPowerLimitEnabled:      nodeRAPLPowerLimitEnabled,
+		nodeRAPLThrottlingTotal:        nodeRAPLThrottlingTotal,
+		nodeIdlePowerWatts:             nodeIdlePowerWatts,
+		nodeIdlePowerFitRSquared:       nodeIdlePowerFitRSquared,
 		nodePackageMiliJoulesTotal:     nodePackageMiliJoulesTotal, // deprecated
 		NodeMetricsStat:                NodeMetricsStat,
 	}
@@ -358,118 +473,126 @@ func (p *PrometheusCollector) newContainerMetrics() {
 	containerCoreJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "core_joules_total"),
 		"Aggregated RAPL value in core in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 	containerUncoreJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "uncore_joules_total"),
 		"Aggregated RAPL value in uncore in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 	containerDramJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "dram_joules_total"),
 		"Aggregated RAPL value in dram in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 	containerPackageJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "package_joules_total"),
 		"Aggregated RAPL value in package (socket) in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 	containerOtherComponentsJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "other_host_components_joules_total"),
 		"Aggregated value in other host components (platform - package - dram) in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 	containerGPUJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "gpu_joules_total"),
 		"Aggregated GPU value in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 	containerJoulesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "joules_total"),
 		"Aggregated RAPL Package + Uncore + DRAM + GPU + other host components (platform - package - dram) in joules",
-		[]string{"pod_name", "container_name", "container_namespace", "command", "mode"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command", "mode"), nil,
 	)
 
 	// Hardware Counters (counter)
 	containerCPUCyclesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cpu_cycles_total"),
 		"Aggregated CPU cycle value",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 	containerCPUInstrTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cpu_instructions_total"),
 		"Aggregated CPU instruction value",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 	containerCacheMissTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cache_miss_total"),
 		"Aggregated cache miss value",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	// cGroups Counters (counter)
 	containerCgroupCPUUsageUsTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cgroupfs_cpu_usage_us_total"),
 		"Aggregated cpu usage obtained from cGroups",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	containerCgroupMemoryUsageBytesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cgroupfs_memory_usage_bytes_total"),
 		"Aggregated memory bytes obtained from cGroups",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	containerCgroupSystemCPUUsageUsTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cgroupfs_system_cpu_usage_us_total"),
 		"Aggregated system cpu usage obtained from cGroups",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	containerCgroupUserCPUUsageUsTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "cgroupfs_user_cpu_usage_us_total"),
 		"Aggregated user cpu usage obtained from cGroups",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	// Kubelet Counters (counter)
 	containerKubeletCPUUsageTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "kubelet_cpu_usage_total"),
 		"Aggregated cpu usage obtained from kubelet",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	containerKubeletMemoryBytesTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "kubelet_memory_bytes_total"),
 		"Aggregated memory bytes obtained from kubelet",
-		[]string{"pod_name", "container_name", "container_namespace", "command"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"), nil,
 	)
 
 	// Additional metrics (gauge)
 	containerCPUTime := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "bpf_cpu_time_us_total"),
 		"Aggregated CPU time obtained from BPF",
-		[]string{"pod_name", "container_name", "container_namespace"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace"), nil,
+	)
+	containerPackageCPUTime := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "container", "bpf_package_cpu_time_us_total"),
+		"Aggregated CPU time per CPU package (socket) obtained from BPF, its sum over the packages is the container CPU time",
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace", "package"), nil,
 	)
 
 	// network irq metrics
 	containerNetTxIRQTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "bpf_net_tx_irq_total"),
 		"Aggregated network tx irq value obtained from BPF",
-		[]string{"pod_name", "container_name", "container_namespace"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace"), nil,
 	)
 	containerNetRxIRQTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "bpf_net_rx_irq_total"),
 		"Aggregated network rx irq value obtained from BPF",
-		[]string{"pod_name", "container_name", "container_namespace"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace"), nil,
 	)
 	containerBlockIRQTotal := prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "container", "bpf_block_irq_total"),
 		"Aggregated block irq value obtained from BPF",
-		[]string{"pod_name", "container_name", "container_namespace"}, nil,
+		withPodMetadataLabels("pod_name", "container_name", "container_namespace"), nil,
 	)
 
+	containerHWCounterTotal := newHWCounterDescs("container", withPodMetadataLabels("pod_name", "container_name", "container_namespace", "command"))
+	containerBPFStatTotal := newBPFStatDescs("container", withPodMetadataLabels("pod_name", "container_name", "container_namespace"))
+
 	p.containerDesc = &ContainerDesc{
 		containerCoreJoulesTotal:             containerCoreJoulesTotal,
 		containerUncoreJoulesTotal:           containerUncoreJoulesTotal,
@@ -481,6 +604,7 @@ func (p *PrometheusCollector) newContainerMetrics() {
 		containerCPUCyclesTotal:              containerCPUCyclesTotal,
 		containerCPUInstrTotal:               containerCPUInstrTotal,
 		containerCacheMissTotal:              containerCacheMissTotal,
+		containerHWCounterTotal:              containerHWCounterTotal,
 		containerCgroupCPUUsageUsTotal:       containerCgroupCPUUsageUsTotal,
 		containerCgroupMemoryUsageBytesTotal: containerCgroupMemoryUsageBytesTotal,
 		containerCgroupSystemCPUUsageUsTotal: containerCgroupSystemCPUUsageUsTotal,
@@ -488,12 +612,41 @@ func (p *PrometheusCollector) newContainerMetrics() {
 		containerKubeletMemoryBytesTotal:     containerKubeletMemoryBytesTotal,
 		containerKubeletCPUUsageTotal:        containerKubeletCPUUsageTotal,
 		containerCPUTime:                     containerCPUTime,
+		containerPackageCPUTime:              containerPackageCPUTime,
 		containerNetTxIRQTotal:               containerNetTxIRQTotal,
 		containerNetRxIRQTotal:               containerNetRxIRQTotal,
 		containerBlockIRQTotal:               containerBlockIRQTotal,
+		containerBPFStatTotal:                containerBPFStatTotal,
 	}
 }
 
+// newHWCounterDescs creates the descriptions of the hardware counters selected in addition to the default counters,
+// e.g. kepler_container_llc_references_total
+func newHWCounterDescs(resource string, labels []string) map[string]*prometheus.Desc {
+	descs := map[string]*prometheus.Desc{}
+	for _, counter := range attacher.GetAdditionalHWCounters() {
+		descs[counter] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, resource, counter+"_total"),
+			fmt.Sprintf("Aggregated %s hardware counter value", counter),
+			labels, nil,
+		)
+	}
+	return descs
+}
+
+// newBPFStatDescs creates the descriptions of the task event and I/O counters of a resource, e.g. kepler_container_bpf_cpu_migrations_total
+func newBPFStatDescs(resource string, labels []string) map[string]*prometheus.Desc {
+	descs := map[string]*prometheus.Desc{}
+	for _, counter := range attacher.BPFStatCounters {
+		descs[counter] = prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, resource, "bpf_"+counter+"_total"),
+			fmt.Sprintf("Aggregated %s obtained from BPF", strings.ReplaceAll(counter, "_", " ")),
+			labels, nil,
+		)
+	}
+	return descs
+}
+
 func (p *PrometheusCollector) newPodMetrics() {
 	// Old metrics
 	podEnergyStat := prometheus.NewDesc(
@@ -510,6 +663,8 @@ func (p *PrometheusCollector) newPodMetrics() {
 	p.podDesc = &PodDesc{
 		podEnergyStat:    podEnergyStat,
 		podCPUInstrTotal: podCPUInstrTotal,
+		// Energy (counter)
+		podEnergy: newAggregatedEnergyDesc("pod", "all containers in the pod", withPodMetadataLabels(podEnergyLabels...)),
 	}
 }
 
@@ -520,7 +675,10 @@ func (p *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
 	wg := sync.WaitGroup{}
 	p.updateNodeMetrics(&wg, ch)
 	p.updatePodMetrics(&wg, ch)
+	p.updatePodEnergyMetrics(&wg, ch)
 	p.updateProcessMetrics(&wg, ch)
+	p.updateSystemdUnitMetrics(&wg, ch)
+	p.updateVMMetrics(&wg, ch)
 	wg.Wait()
 }
 
@@ -539,6 +697,8 @@ func (p *PrometheusCollector) updateNodeMetrics(wg *sync.WaitGroup, ch chan<- pr
 				fmt.Sprintf("%d", cpuID), collector_metric.NodeName,
 			)
 		}
+		p.updateNodeRAPLPowerLimitMetrics(ch)
+		p.updateNodeIdlePowerMetrics(ch)
 		for pkgID, val := range p.NodeMetrics.TotalEnergyInPkg.Stat {
 			coreEnergy := strconv.FormatUint(p.NodeMetrics.TotalEnergyInCore.Stat[pkgID].Delta, 10)
 			dramEnergy := strconv.FormatUint(p.NodeMetrics.TotalEnergyInDRAM.Stat[pkgID].Delta, 10)
@@ -560,14 +720,14 @@ func (p *PrometheusCollector) updateNodeMetrics(wg *sync.WaitGroup, ch chan<- pr
 		ch <- prometheus.MustNewConstMetric(
 			p.nodeDesc.NodeMetricsStat,
 			prometheus.CounterValue,
-			(float64(p.NodeMetrics.TotalEnergyInPlatform.SumAllDeltaValues())/miliJouleToJoule)/p.SamplePeriodSec,
+			(float64(p.NodeMetrics.TotalEnergyInPlatform.SumAllDeltaValues())/miliJouleToJoule)/p.NodeMetrics.GetElapsedSec(),
 			NodeMetricsStatusLabelValues...,
 		)
 		ch <- prometheus.MustNewConstMetric(
 			p.nodeDesc.nodeInfo,
 			prometheus.CounterValue,
 			1,
-			collector_metric.NodeCPUArchitecture,
+			collector_metric.NodeCPUArchitecture, components.GetPowerSourceName(),
 		)
 		// Node metrics in joules (counter)
 		for pkgID := range p.NodeMetrics.TotalEnergyInCore.Stat {
@@ -651,14 +811,14 @@ func (p *PrometheusCollector) updateNodeMetrics(wg *sync.WaitGroup, ch chan<- pr
 			p.nodeDesc.nodePlatformJoulesTotal,
 			prometheus.CounterValue,
 			dynPower,
-			collector_metric.NodeName, "acpi", "dynamic",
+			collector_metric.NodeName, p.NodeMetrics.PlatformPowerSource, "dynamic",
 		)
 		idlePower = (float64(p.NodeMetrics.GetSumAggrDynEnergyFromAllSources(collector_metric.PLATFORM)) / miliJouleToJoule)
 		ch <- prometheus.MustNewConstMetric(
 			p.nodeDesc.nodePlatformJoulesTotal,
 			prometheus.CounterValue,
 			idlePower,
-			collector_metric.NodeName, "acpi", "idle",
+			collector_metric.NodeName, p.NodeMetrics.PlatformPowerSource, "idle",
 		)
 
 		if config.EnabledGPU {
@@ -682,6 +842,76 @@ func (p *PrometheusCollector) updateNodeMetrics(wg *sync.WaitGroup, ch chan<- pr
 	}()
 }
 
+// updateNodeRAPLPowerLimitMetrics send the RAPL power capping metrics to prometheus
+func (p *PrometheusCollector) updateNodeRAPLPowerLimitMetrics(ch chan<- prometheus.Metric) {
+	for _, limit := range p.NodeMetrics.RAPLPowerLimits {
+		pkgID := strconv.Itoa(limit.PackageID)
+		enabled := 0.0
+		if limit.Enabled {
+			enabled = 1
+		}
+		ch <- prometheus.MustNewConstMetric(
+			p.nodeDesc.nodeRAPLPowerLimitEnabled,
+			prometheus.GaugeValue,
+			enabled,
+			pkgID, limit.Domain, collector_metric.NodeName,
+		)
+		for _, constraint := range limit.Constraints {
+			ch <- prometheus.MustNewConstMetric(
+				p.nodeDesc.nodeRAPLPowerLimitWatts,
+				prometheus.GaugeValue,
+				float64(constraint.PowerLimit)/1000000, /*uW to W*/
+				pkgID, limit.Domain, constraint.Name, collector_metric.NodeName,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				p.nodeDesc.nodeRAPLTimeWindowSeconds,
+				prometheus.GaugeValue,
+				float64(constraint.TimeWindow)/1000000, /*us to s*/
+				pkgID, limit.Domain, constraint.Name, collector_metric.NodeName,
+			)
+		}
+	}
+	for pkgID, domains := range p.NodeMetrics.RAPLThrottlingCount {
+		for domain, count := range domains {
+			ch <- prometheus.MustNewConstMetric(
+				p.nodeDesc.nodeRAPLThrottlingTotal,
+				prometheus.CounterValue,
+				float64(count),
+				pkgID, domain, collector_metric.NodeName,
+			)
+		}
+	}
+}
+
+// updateNodeIdlePowerMetrics send the calibrated idle power of the node components to prometheus
+func (p *PrometheusCollector) updateNodeIdlePowerMetrics(ch chan<- prometheus.Metric) {
+	if p.NodeMetrics.IdleCalibrator == nil {
+		return
+	}
+	for component, ids := range p.NodeMetrics.IdleCalibrator.GetFits() {
+		for id, fit := range ids {
+			source := "calibration"
+			if fit.Override {
+				source = "override"
+			}
+			ch <- prometheus.MustNewConstMetric(
+				p.nodeDesc.nodeIdlePowerWatts,
+				prometheus.GaugeValue,
+				fit.IdlePower/1000, /*mW to W*/
+				component, id, collector_metric.NodeName, source,
+			)
+			if !fit.Override {
+				ch <- prometheus.MustNewConstMetric(
+					p.nodeDesc.nodeIdlePowerFitRSquared,
+					prometheus.GaugeValue,
+					fit.RSquared,
+					component, id, collector_metric.NodeName,
+				)
+			}
+		}
+	}
+}
+
 // updatePodMetrics send pod metrics to prometheus
 func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- prometheus.Metric) {
 	const commandLenLimit = 10
@@ -709,80 +939,88 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 				p.containerDesc.containerCPUTime,
 				prometheus.CounterValue,
 				float64(container.CPUTime.Aggr),
-				container.PodName, container.ContainerName, container.Namespace,
+				containerLabelValues(container)...,
 			)
+			for pkg, stat := range container.CPUTimePerPackage.Stat {
+				ch <- prometheus.MustNewConstMetric(
+					p.containerDesc.containerPackageCPUTime,
+					prometheus.CounterValue,
+					float64(stat.Aggr),
+					containerLabelValues(container, pkg)...,
+				)
+			}
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerCoreJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.DynEnergyInCore.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+				containerLabelValues(container, containerCommand, "dynamic")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerCoreJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.IdleEnergyInCore.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+				containerLabelValues(container, containerCommand, "idle")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerUncoreJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.DynEnergyInUncore.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+				containerLabelValues(container, containerCommand, "dynamic")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerUncoreJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.IdleEnergyInUncore.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+				containerLabelValues(container, containerCommand, "idle")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerDramJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.DynEnergyInDRAM.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+				containerLabelValues(container, containerCommand, "dynamic")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerDramJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.IdleEnergyInDRAM.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+				containerLabelValues(container, containerCommand, "idle")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerPackageJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.DynEnergyInPkg.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+				containerLabelValues(container, containerCommand, "dynamic")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerPackageJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.IdleEnergyInPkg.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+				containerLabelValues(container, containerCommand, "idle")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerOtherComponentsJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.DynEnergyInOther.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+				containerLabelValues(container, containerCommand, "dynamic")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerOtherComponentsJoulesTotal,
 				prometheus.CounterValue,
 				float64(container.IdleEnergyInOther.Aggr)/miliJouleToJoule,
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+				containerLabelValues(container, containerCommand, "idle")...,
 			)
 			if config.EnabledGPU {
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerGPUJoulesTotal,
 					prometheus.CounterValue,
 					float64(container.DynEnergyInGPU.Aggr)/miliJouleToJoule,
-					container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+					containerLabelValues(container, containerCommand, "dynamic")...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerGPUJoulesTotal,
 					prometheus.CounterValue,
 					float64(container.IdleEnergyInGPU.Aggr)/miliJouleToJoule,
-					container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+					containerLabelValues(container, containerCommand, "idle")...,
 				)
 			}
 			ch <- prometheus.MustNewConstMetric(
@@ -793,7 +1031,7 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 					float64(container.DynEnergyInDRAM.Aggr)/miliJouleToJoule +
 					float64(container.DynEnergyInGPU.Aggr)/miliJouleToJoule +
 					float64(container.DynEnergyInOther.Aggr)/miliJouleToJoule),
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "dynamic",
+				containerLabelValues(container, containerCommand, "dynamic")...,
 			)
 			ch <- prometheus.MustNewConstMetric(
 				p.containerDesc.containerJoulesTotal,
@@ -803,7 +1041,7 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 					float64(container.IdleEnergyInDRAM.Aggr)/miliJouleToJoule +
 					float64(container.IdleEnergyInGPU.Aggr)/miliJouleToJoule +
 					float64(container.IdleEnergyInOther.Aggr)/miliJouleToJoule),
-				container.PodName, container.ContainerName, container.Namespace, containerCommand, "idle",
+				containerLabelValues(container, containerCommand, "idle")...,
 			)
 			if config.ExposeHardwareCounterMetrics && collector_metric.CPUHardwareCounterEnabled {
 				if container.CounterStats[attacher.CPUCycleLabel] != nil {
@@ -811,7 +1049,7 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 						p.containerDesc.containerCPUCyclesTotal,
 						prometheus.CounterValue,
 						float64(container.CounterStats[attacher.CPUCycleLabel].Aggr),
-						container.PodName, container.ContainerName, container.Namespace, containerCommand,
+						containerLabelValues(container, containerCommand)...,
 					)
 				}
 				if container.CounterStats[attacher.CPUInstructionLabel] != nil {
@@ -819,7 +1057,7 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 						p.containerDesc.containerCPUInstrTotal,
 						prometheus.CounterValue,
 						float64(container.CounterStats[attacher.CPUInstructionLabel].Aggr),
-						container.PodName, container.ContainerName, container.Namespace, containerCommand,
+						containerLabelValues(container, containerCommand)...,
 					)
 				}
 				if container.CounterStats[attacher.CacheMissLabel] != nil {
@@ -827,35 +1065,47 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 						p.containerDesc.containerCacheMissTotal,
 						prometheus.CounterValue,
 						float64(container.CounterStats[attacher.CacheMissLabel].Aggr),
-						container.PodName, container.ContainerName, container.Namespace, containerCommand,
+						containerLabelValues(container, containerCommand)...,
 					)
 				}
 			}
+			if config.ExposeHardwareCounterMetrics {
+				for counter, desc := range p.containerDesc.containerHWCounterTotal {
+					if stat, exists := container.CounterStats[counter]; exists {
+						ch <- prometheus.MustNewConstMetric(
+							desc,
+							prometheus.CounterValue,
+							float64(stat.Aggr),
+							containerLabelValues(container, containerCommand)...,
+						)
+					}
+				}
+			}
 
 			if config.ExposeCgroupMetrics && p.HavecGroupsMetric {
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerCgroupCPUUsageUsTotal,
 					prometheus.CounterValue,
 					float64(container.CgroupFSStats[config.CgroupfsCPU].SumAllAggrValues()),
-					container.PodName, container.ContainerName, container.Namespace, containerCommand,
+					containerLabelValues(container, containerCommand)...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerCgroupMemoryUsageBytesTotal,
 					prometheus.CounterValue,
 					float64(container.CgroupFSStats[config.CgroupfsMemory].SumAllAggrValues()),
-					container.PodName, container.ContainerName, container.Namespace, containerCommand,
+					containerLabelValues(container, containerCommand)...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerCgroupSystemCPUUsageUsTotal,
 					prometheus.CounterValue,
 					float64(container.CgroupFSStats[config.CgroupfsSystemCPU].SumAllAggrValues()),
-					container.PodName, container.ContainerName, container.Namespace, containerCommand,
+					containerLabelValues(container, containerCommand)...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerCgroupUserCPUUsageUsTotal,
 					prometheus.CounterValue,
 					float64(container.CgroupFSStats[config.CgroupfsUserCPU].SumAllAggrValues()),
-					container.PodName, container.ContainerName, container.Namespace, containerCommand,
+					containerLabelValues(container, containerCommand)...,
 				)
 			}
 
@@ -864,13 +1114,13 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 					p.containerDesc.containerKubeletCPUUsageTotal,
 					prometheus.CounterValue,
 					float64(container.KubeletStats[config.KubeletContainerCPU].Aggr),
-					container.PodName, container.ContainerName, container.Namespace, containerCommand,
+					containerLabelValues(container, containerCommand)...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerKubeletMemoryBytesTotal,
 					prometheus.CounterValue,
 					float64(container.KubeletStats[config.KubeletContainerMemory].Aggr),
-					container.PodName, container.ContainerName, container.Namespace, containerCommand,
+					containerLabelValues(container, containerCommand)...,
 				)
 			}
 
@@ -879,21 +1129,72 @@ func (p *PrometheusCollector) updatePodMetrics(wg *sync.WaitGroup, ch chan<- pro
 					p.containerDesc.containerNetTxIRQTotal,
 					prometheus.CounterValue,
 					float64(container.SoftIRQCount[attacher.IRQNetTX].Aggr),
-					container.PodName, container.ContainerName, container.Namespace,
+					containerLabelValues(container)...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerNetRxIRQTotal,
 					prometheus.CounterValue,
 					float64(container.SoftIRQCount[attacher.IRQNetRX].Aggr),
-					container.PodName, container.ContainerName, container.Namespace,
+					containerLabelValues(container)...,
 				)
 				ch <- prometheus.MustNewConstMetric(
 					p.containerDesc.containerBlockIRQTotal,
 					prometheus.CounterValue,
 					float64(container.SoftIRQCount[attacher.IRQBlock].Aggr),
-					container.PodName, container.ContainerName, container.Namespace,
+					containerLabelValues(container)...,
 				)
 			}
+			for _, counter := range collector_metric.AvailableBPFStatCounters {
+				if stat, exists := container.BPFStats[counter]; exists {
+					ch <- prometheus.MustNewConstMetric(
+						p.containerDesc.containerBPFStatTotal[counter],
+						prometheus.CounterValue,
+						float64(stat.Aggr),
+						containerLabelValues(container)...,
+					)
+				}
+			}
 		}(container)
 	}
 }
+
+// updatePodEnergyMetrics send the energy of each pod to prometheus
+func (p *PrometheusCollector) updatePodEnergyMetrics(wg *sync.WaitGroup, ch chan<- prometheus.Metric) {
+	if p.PodsMetrics == nil {
+		return
+	}
+	for _, pod := range *p.PodsMetrics {
+		wg.Add(1)
+		go func(pod *collector_metric.PodMetrics) {
+			defer wg.Done()
+			labels := []string{pod.PodName, pod.Namespace, pod.PodUID, pod.QOSClass, pod.OwnerKind, pod.OwnerName}
+			dynLabels := podMetadataLabelValues(append(append([]string{}, labels...), "dynamic"), pod.PodMetadataLabels)
+			idleLabels := podMetadataLabelValues(append(append([]string{}, labels...), "idle"), pod.PodMetadataLabels)
+			p.podDesc.podEnergy.collect(ch, &pod.AggregatedEnergy, dynLabels, idleLabels)
+		}(pod)
+	}
+}
+
+// withPodMetadataLabels appends the metric labels of the allowed pod labels and annotations
+func withPodMetadataLabels(labels ...string) []string {
+	return append(append([]string{}, labels...), podMetadataLabelNames...)
+}
+
+// podMetadataLabelValues appends the values of the allowed pod labels and annotations.
+// Containers that are not in a pod, e.g. the system processes, have empty values.
+func podMetadataLabelValues(values, metadataValues []string) []string {
+	for i := range podMetadataLabelNames {
+		value := ""
+		if i < len(metadataValues) {
+			value = metadataValues[i]
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// containerLabelValues returns the label values of a container metric followed by the given values
+func containerLabelValues(container *collector_metric.ContainerMetrics, values ...string) []string {
+	labels := append([]string{container.PodName, container.ContainerName, container.Namespace}, values...)
+	return podMetadataLabelValues(labels, container.PodMetadataLabels)
+}
@@ -43,6 +43,7 @@ const (
 	nodeEnergyMetric             = "kepler_node_platform_joules_total"
 	nodePackageEnergyMetric      = "kepler_node_package_joules_total"
 	containerCPUCoreEnergyMetric = "kepler_container_package_joules_total"
+	podPackageEnergyMetric       = "kepler_pod_package_joules_total"
 
 	SampleCurr = 100
 	SampleAggr = 1000
@@ -81,11 +82,26 @@ func newMockPrometheusExporter() *PrometheusCollector {
 	exporter.NodeMetrics = collector_metric.NewNodeMetrics()
 	exporter.ContainersMetrics = &map[string]*collector_metric.ContainerMetrics{}
 	exporter.ProcessMetrics = &map[uint64]*collector_metric.ProcessMetrics{}
-	exporter.SamplePeriodSec = 3.0
+	exporter.PodsMetrics = &map[string]*collector_metric.PodMetrics{}
+	exporter.SystemdUnitsMetrics = &map[string]*collector_metric.SystemdUnitMetrics{}
+	exporter.VMsMetrics = &map[string]*collector_metric.VMMetrics{}
 	collector_metric.ContainerMetricNames = []string{config.CoreUsageMetric}
 	return exporter
 }
 
+// scrapeMetrics registers the exporter in a new registry and returns the metrics served by the registry
+func scrapeMetrics(exporter *PrometheusCollector) string {
+	registry := prometheus.NewRegistry()
+	Expect(registry.Register(exporter)).To(Succeed())
+	req, _ := http.NewRequest("GET", "", http.NoBody)
+	res := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(res, req)
+	Expect(res.Code).To(Equal(http.StatusOK))
+	body, err := io.ReadAll(res.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return string(body)
+}
+
 var _ = Describe("Test Prometheus Collector Unit", func() {
 	It("Init and Run", func() {
 		exporter := newMockPrometheusExporter()
@@ -144,6 +160,12 @@ var _ = Describe("Test Prometheus Collector Unit", func() {
 		exporter.NodeMetrics.UpdateIdleEnergy()
 		exporter.NodeMetrics.UpdateDynEnergy()
 		model.UpdateContainerEnergyByRatioPowerModel(*exporter.ContainersMetrics, exporter.NodeMetrics)
+		for _, container := range *exporter.ContainersMetrics {
+			pod := collector_metric.NewPodMetrics(container)
+			err = pod.AddContainerEnergy(container)
+			Expect(err).NotTo(HaveOccurred())
+			(*exporter.PodsMetrics)[collector_metric.PodKey(container)] = pod
+		}
 
 		// get metrics from prometheus
 		err = prometheus.Register(exporter)
@@ -180,5 +202,48 @@ var _ = Describe("Test Prometheus Collector Unit", func() {
 		Expect(err).NotTo(HaveOccurred())
 		// The pkg dynamic energy is 5mJ, the container cpu usage is 50%, so the dynamic energy is 2.5mJ = ~3mJ
 		Expect(val).To(Equal(0.003)) //J
+
+		// check the pod energy aggregated from its containers
+		val, err = convertPromToValue(body, podPackageEnergyMetric)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(val).To(Equal(0.003)) //J
+	})
+	It("Export the allowed pod labels and annotations", func() {
+		cgroup.SetPodMetadataLabels([]string{"team"}, []string{"example.com/cost-center"}, 5)
+		defer cgroup.SetPodMetadataLabels(nil, nil, 0)
+		exporter := newMockPrometheusExporter()
+		containerA := collector_metric.NewContainerMetrics("containerA", "podA", "test")
+		containerA.PodUID = "uidA"
+		containerA.PodMetadataLabels = []string{"energy", "cc-42"}
+		(*exporter.ContainersMetrics)["containerA"] = containerA
+		// the system processes are not in a pod and have no pod labels
+		(*exporter.ContainersMetrics)["system_processes"] = collector_metric.NewContainerMetrics("system_processes", "system_processes", "system")
+		(*exporter.PodsMetrics)[collector_metric.PodKey(containerA)] = collector_metric.NewPodMetrics(containerA)
+
+		body := scrapeMetrics(exporter)
+		Expect(body).To(MatchRegexp(`kepler_container_package_joules_total{annotation_example_com_cost_center="cc-42",command="",container_name="containerA",container_namespace="test",label_team="energy",mode="dynamic",pod_name="podA"}`))
+		Expect(body).To(MatchRegexp(`kepler_pod_package_joules_total{annotation_example_com_cost_center="cc-42",label_team="energy",mode="dynamic",owner_kind="",owner_name="",pod_name="podA",pod_namespace="test",pod_uid="uidA",qos_class=""}`))
+		Expect(body).To(MatchRegexp(`kepler_container_package_joules_total{annotation_example_com_cost_center="",command="",container_name="system_processes"`))
+		Expect(body).NotTo(MatchRegexp(`(?m)^kepler_pod_[a-z_]+_joules_total{.*pod_name="system_processes"`))
+	})
+	It("Export the systemd unit energy", func() {
+		exporter := newMockPrometheusExporter()
+		unit := collector_metric.NewSystemdUnitMetrics("postgresql.service", "system.slice")
+		err := unit.DynEnergyInPkg.AddNewDelta(3000)
+		Expect(err).NotTo(HaveOccurred())
+		(*exporter.SystemdUnitsMetrics)["postgresql.service"] = unit
+
+		body := scrapeMetrics(exporter)
+		Expect(body).To(ContainSubstring(`kepler_systemd_unit_package_joules_total{mode="dynamic",slice="system.slice",unit="postgresql.service"} 3`))
+	})
+	It("Export the virtual machine energy", func() {
+		exporter := newMockPrometheusExporter()
+		vm := collector_metric.NewVMMetrics("db-01", "6c0e3f2a-1b2c-4d5e-8f90-1234567890ab")
+		err := vm.DynEnergyInCore.AddNewDelta(2000)
+		Expect(err).NotTo(HaveOccurred())
+		(*exporter.VMsMetrics)[vm.UUID] = vm
+
+		body := scrapeMetrics(exporter)
+		Expect(body).To(ContainSubstring(`kepler_vm_core_joules_total{mode="dynamic",vm_name="db-01",vm_uuid="6c0e3f2a-1b2c-4d5e-8f90-1234567890ab"} 2`))
 	})
 })
@@ -41,14 +41,21 @@ type processDesc struct {
 	processCPUCyclesTotal *prometheus.Desc
 	processCPUInstrTotal  *prometheus.Desc
 	processCacheMissTotal *prometheus.Desc
+	// Hardware Counters selected in addition to the default counters (counter), indexed by the counter
+	processHWCounterTotal map[string]*prometheus.Desc
 
 	// Additional metrics (gauge)
 	processCPUTime *prometheus.Desc
+	// CPU time per CPU package (counter)
+	processPackageCPUTime *prometheus.Desc
 
 	// IRQ metrics
 	processNetTxIRQTotal *prometheus.Desc
 	processNetRxIRQTotal *prometheus.Desc
 	processBlockIRQTotal *prometheus.Desc
+
+	// Task event and I/O metrics counted by BPF (counter), indexed by the counter
+	processBPFStatTotal map[string]*prometheus.Desc
 }
 
 // describeProcess is called by Describe to implement the prometheus.Collector interface
@@ -63,6 +70,7 @@ func (p *PrometheusCollector) describeProcess(ch chan<- *prometheus.Desc) {
 		ch <- p.processDesc.processGPUJoulesTotal
 	}
 	ch <- p.processDesc.processJoulesTotal
+	ch <- p.processDesc.processPackageCPUTime
 
 	// process Hardware Counters (counter)
 	if collector_metric.CPUHardwareCounterEnabled {
@@ -70,12 +78,23 @@ func (p *PrometheusCollector) describeProcess(ch chan<- *prometheus.Desc) {
 		ch <- p.processDesc.processCPUInstrTotal
 		ch <- p.processDesc.processCacheMissTotal
 	}
+	if config.ExposeHardwareCounterMetrics {
+		for _, counter := range collector_metric.AvailableHWCounters {
+			if desc, exists := p.processDesc.processHWCounterTotal[counter]; exists {
+				ch <- desc
+			}
+		}
+	}
 
 	if config.ExposeIRQCounterMetrics {
 		ch <- p.processDesc.processNetTxIRQTotal
 		ch <- p.processDesc.processNetRxIRQTotal
 		ch <- p.processDesc.processBlockIRQTotal
 	}
+
+	for _, counter := range collector_metric.AvailableBPFStatCounters {
+		ch <- p.processDesc.processBPFStatTotal[counter]
+	}
 }
 
 func (p *PrometheusCollector) newprocessMetrics() {
@@ -137,6 +156,10 @@ func (p *PrometheusCollector) newprocessMetrics() {
 		prometheus.BuildFQName(namespace, "process", "cpu_cpu_time_us"),
 		"Aggregated CPU time",
 		[]string{"pid", "command"}, nil)
+	processPackageCPUTime := prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "process", "bpf_package_cpu_time_us_total"),
+		"Aggregated CPU time per CPU package (socket) obtained from BPF, its sum over the packages is the process CPU time",
+		[]string{"pid", "command", "package"}, nil)
 
 	// network irq metrics
 	processNetTxIRQTotal := prometheus.NewDesc(
@@ -155,6 +178,9 @@ func (p *PrometheusCollector) newprocessMetrics() {
 		[]string{"pid", "command"}, nil,
 	)
 
+	processHWCounterTotal := newHWCounterDescs("process", []string{"pid", "command"})
+	processBPFStatTotal := newBPFStatDescs("process", []string{"pid", "command"})
+
 	p.processDesc = &processDesc{
 		processCoreJoulesTotal:            processCoreJoulesTotal,
 		processUncoreJoulesTotal:          processUncoreJoulesTotal,
@@ -166,10 +192,13 @@ func (p *PrometheusCollector) newprocessMetrics() {
 		processCPUCyclesTotal:             processCPUCyclesTotal,
 		processCPUInstrTotal:              processCPUInstrTotal,
 		processCacheMissTotal:             processCacheMissTotal,
+		processHWCounterTotal:             processHWCounterTotal,
 		processCPUTime:                    processCPUTime,
+		processPackageCPUTime:             processPackageCPUTime,
 		processNetTxIRQTotal:              processNetTxIRQTotal,
 		processNetRxIRQTotal:              processNetRxIRQTotal,
 		processBlockIRQTotal:              processBlockIRQTotal,
+		processBPFStatTotal:               processBPFStatTotal,
 	}
 }
 
@@ -191,6 +220,14 @@ func (p *PrometheusCollector) updateProcessMetrics(wg *sync.WaitGroup, ch chan<-
 				float64(process.CPUTime.Aggr),
 				pidStr, processCommand,
 			)
+			for pkg, stat := range process.CPUTimePerPackage.Stat {
+				ch <- prometheus.MustNewConstMetric(
+					p.processDesc.processPackageCPUTime,
+					prometheus.CounterValue,
+					float64(stat.Aggr),
+					pidStr, processCommand, pkg,
+				)
+			}
 			ch <- prometheus.MustNewConstMetric(
 				p.processDesc.processCoreJoulesTotal,
 				prometheus.CounterValue,
@@ -311,6 +348,18 @@ func (p *PrometheusCollector) updateProcessMetrics(wg *sync.WaitGroup, ch chan<-
 					)
 				}
 			}
+			if config.ExposeHardwareCounterMetrics {
+				for counter, desc := range p.processDesc.processHWCounterTotal {
+					if stat, exists := process.CounterStats[counter]; exists {
+						ch <- prometheus.MustNewConstMetric(
+							desc,
+							prometheus.CounterValue,
+							float64(stat.Aggr),
+							pidStr, processCommand,
+						)
+					}
+				}
+			}
 			if config.ExposeIRQCounterMetrics {
 				ch <- prometheus.MustNewConstMetric(
 					p.processDesc.processNetTxIRQTotal,
@@ -331,6 +380,16 @@ func (p *PrometheusCollector) updateProcessMetrics(wg *sync.WaitGroup, ch chan<-
 					pidStr, processCommand,
 				)
 			}
+			for _, counter := range collector_metric.AvailableBPFStatCounters {
+				if stat, exists := process.BPFStats[counter]; exists {
+					ch <- prometheus.MustNewConstMetric(
+						p.processDesc.processBPFStatTotal[counter],
+						prometheus.CounterValue,
+						float64(stat.Aggr),
+						pidStr, processCommand,
+					)
+				}
+			}
 		}(pid, process)
 	}
 }
@@ -19,6 +19,7 @@ package collector
 import (
 	"github.com/sustainable-computing-io/kepler/pkg/cgroup"
 	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"k8s.io/klog/v2"
 )
 
@@ -44,13 +45,48 @@ func (c *Collector) createContainersMetricsIfNotExist(containerID string, cGroup
 		}
 
 		c.ContainersMetrics[containerID] = collector_metric.NewContainerMetrics(containerName, podName, namespace)
+		if containerID != c.systemProcessName {
+			if info, err := cgroup.GetContainerInfo(cGroupID, pid, withCGroupID); err == nil {
+				setContainerPodInfo(c.ContainersMetrics[containerID], info)
+			}
+		}
 	}
 }
 
+// setContainerPodInfo sets the kubernetes metadata of the container pod
+func setContainerPodInfo(c *collector_metric.ContainerMetrics, info *cgroup.ContainerInfo) {
+	c.PodUID = info.PodUID
+	c.QOSClass = info.QOSClass
+	c.OwnerKind = info.OwnerKind
+	c.OwnerName = info.OwnerName
+	c.PodMetadataLabels = info.PodMetadataLabels
+	c.CPURequest = info.CPURequest
+	c.MemoryRequest = info.MemoryRequest
+	c.CPULimit = info.CPULimit
+	c.MemoryLimit = info.MemoryLimit
+}
+
 func (c *Collector) createProcessMetricsIfNotExist(pid uint64, command string) {
 	if p, ok := c.ProcessMetrics[pid]; !ok {
 		c.ProcessMetrics[pid] = collector_metric.NewProcessMetrics(pid, command)
+		// the virtual machines are identified by the systemd scope of the QEMU processes
+		if config.ExposeSystemdUnitMetrics || config.ExposeVMMetrics {
+			setProcessSystemdUnit(c.ProcessMetrics[pid])
+		}
 	} else if p.Command == "" {
 		p.Command = command
 	}
 }
+
+// setProcessSystemdUnit sets the systemd unit running the process
+func setProcessSystemdUnit(p *collector_metric.ProcessMetrics) {
+	unit, err := cgroup.GetSystemdUnit(p.PID)
+	if err != nil {
+		// the process might have already finished
+		klog.V(5).Infof("failed to resolve the systemd unit of pid %d: %v", p.PID, err)
+		return
+	}
+	p.SystemdUnit = unit.Unit
+	p.SystemdSlice = unit.Slice
+	p.SystemdUnitPath = unit.Path
+}
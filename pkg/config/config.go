@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/sys/unix"
 	"k8s.io/klog/v2"
@@ -51,6 +52,22 @@ const (
 	defaultNamespace        = "kepler"
 	defaultModelServerPort  = "8100"
 	defaultModelRequestPath = "/model"
+	// defaultSamplePeriodSec is the default time in seconds between two metric collections
+	defaultSamplePeriodSec = 3
+	// defaultMaxPodMetadataLabels is the default maximum number of pod labels and annotations exported as metric labels
+	defaultMaxPodMetadataLabels = 5
+	// defaultLibvirtDomainXMLPath is where libvirt writes the XML of the running QEMU domains
+	defaultLibvirtDomainXMLPath = "/run/libvirt/qemu"
+	// defaultRedfishProbeIntervalSec is the default interval to read the BMC power, BMCs are usually slow to answer
+	defaultRedfishProbeIntervalSec = 60
+	// defaultIdlePowerCalibrationWindow is the default number of samples used to fit the idle power
+	defaultIdlePowerCalibrationWindow = 100
+	// defaultIdlePowerCalibrationFile is where the fitted idle power is kept across restarts
+	defaultIdlePowerCalibrationFile = "/var/lib/kepler/state/idle_power.json"
+	// defaultEnergyCheckpointIntervalSec is the default time in seconds between two writes of the energy checkpoint
+	defaultEnergyCheckpointIntervalSec = 60
+	// defaultEnergyCheckpointMaxAgeSec is the default age in seconds after which the checkpointed energy is discarded
+	defaultEnergyCheckpointMaxAgeSec = 3600
 	// MaxIRQ is the maximum number of IRQs to be monitored
 	MaxIRQ = 10
 )
@@ -68,9 +85,43 @@ var (
 	ExposeCgroupMetrics          = getBoolConfig("EXPOSE_CGROUP_METRICS", true)
 	ExposeKubeletMetrics         = getBoolConfig("EXPOSE_KUBELET_METRICS", true)
 	ExposeIRQCounterMetrics      = getBoolConfig("EXPOSE_IRQ_COUNTER_METRICS", true)
+	ExposeTaskEventMetrics       = getBoolConfig("EXPOSE_TASK_EVENT_METRICS", true)
+	ExposeBPFIOMetrics           = getBoolConfig("EXPOSE_BPF_IO_METRICS", true)
 	MetricPathKey                = "METRIC_PATH"
 	BindAddressKey               = "BIND_ADDRESS"
 	CPUArchOverride              = getConfig("CPU_ARCH_OVERRIDE", "")
+	PowerSource                  = strings.TrimSpace(getConfig("POWER_SOURCE", "")) // auto-select
+	SamplePeriodSec              = getSamplePeriodConfig()
+	// ExposeSystemdUnitMetrics aggregates the process energy per systemd unit, it requires ENABLE_PROCESS_METRICS
+	ExposeSystemdUnitMetrics = getBoolConfig("EXPOSE_SYSTEMD_UNIT_METRICS", true)
+	// ExposeVMMetrics aggregates the energy of the libvirt QEMU processes per virtual machine, it requires ENABLE_PROCESS_METRICS
+	ExposeVMMetrics = getBoolConfig("EXPOSE_VM_METRICS", true)
+	// LibvirtDomainXMLPath is the directory of the libvirt domain XML files used to read the VM UUID, empty to disable the lookup
+	LibvirtDomainXMLPath = strings.TrimSpace(getConfig("LIBVIRT_DOMAIN_XML_PATH", defaultLibvirtDomainXMLPath))
+	// ResolvePodOwner enables the lookup of the top controller of the pods (e.g. Deployment) in the kubernetes API, it is opt-in
+	// because the initialization waits up to 30s for the first list of the controllers
+	ResolvePodOwner = getBoolConfig("RESOLVE_POD_OWNER", false)
+	// EnablePodInformer watches the node pods in the kubernetes API instead of polling the kubelet, requires NODE_NAME, it is opt-in
+	// because the initialization waits up to 30s for the first list of the pods
+	EnablePodInformer = getBoolConfig("ENABLE_POD_INFORMER", false)
+	NodeName          = strings.TrimSpace(getConfig("NODE_NAME", ""))
+	// EnableContainerRuntimeResolver reads the metadata of the containers unknown to the kubelet from the container runtime
+	EnableContainerRuntimeResolver = getBoolConfig("ENABLE_CONTAINER_RUNTIME_RESOLVER", true)
+	// ContainerRuntimeEndpoint is the CRI or Docker API socket, e.g. unix:///run/containerd/containerd.sock, the default sockets are probed if empty
+	ContainerRuntimeEndpoint = strings.TrimSpace(getConfig("CONTAINER_RUNTIME_ENDPOINT", ""))
+	// BPFBackend selects the loader of the eBPF program: libbpf (pre-compiled CO-RE program), bcc (compiled at runtime) or auto
+	BPFBackend = strings.TrimSpace(getConfig("BPF_BACKEND", "auto"))
+	// EnableCgroupDiscovery finds the containers by walking the cgroup hierarchy when the BPF programs cannot be attached
+	EnableCgroupDiscovery = getBoolConfig("ENABLE_CGROUP_DISCOVERY", true)
+	// HardwareCounters lists the hardware counters collected by the eBPF program, e.g. cpu_cycles,cpu_ref_cycles,llc_references,cpu/topdown-fetch-bubbles,
+	// the default counters (cpu cycles, ref cycles, instructions and cache misses) are collected if empty
+	HardwareCounters = getListConfig("HARDWARE_COUNTERS")
+
+	// Pod labels and annotations copied into the container and pod metric labels, e.g. team,cost-center
+	PodLabelsAllowlist      = getListConfig("POD_LABELS_ALLOWLIST")
+	PodAnnotationsAllowlist = getListConfig("POD_ANNOTATIONS_ALLOWLIST")
+	// MaxPodMetadataLabels limits the number of pod labels and annotations added to the metrics to bound their cardinality
+	MaxPodMetadataLabels = getIntConfig("MAX_POD_METADATA_LABELS", defaultMaxPodMetadataLabels)
 
 	EstimatorModel        = getConfig("ESTIMATOR_MODEL", defaultMetricValue)         // auto-select
 	EstimatorSelectFilter = getConfig("ESTIMATOR_SELECT_FILTER", defaultMetricValue) // no filter
@@ -79,6 +130,33 @@ var (
 	UncoreUsageMetric     = getConfig("UNCORE_USAGE_METRIC", defaultMetricValue) // no metric (evenly divided)
 	GpuUsageMetric        = getConfig("GPU_USAGE_METRIC", GPUSMUtilization)      // no metric (evenly divided)
 	GeneralUsageMetric    = getConfig("GENERAL_USAGE_METRIC", CPUInstruction)    // for uncategorized energy; pkg - core - uncore
+	// IdlePowerAttribution selects how the node idle energy is attributed to the containers: even, requests or limits (by the CPU
+	// and memory requests or limits of the containers), or node (the idle energy is only reported in the node metrics)
+	IdlePowerAttribution = getIdlePowerAttributionConfig()
+	// EnableIdlePowerCalibration fits the idle power of the node components with a regression of their power over the node utilization,
+	// the lowest observed power is used until the fit is available. It is opt-in, the fit is kept in IdlePowerCalibrationFile which must
+	// be on a host path to survive restarts, see manifests/config/exporter/patch/patch-idle-power-calibration.yaml
+	EnableIdlePowerCalibration = getBoolConfig("ENABLE_IDLE_POWER_CALIBRATION", false)
+	// IdlePowerCalibrationWindow is the number of the latest samples used to fit the idle power
+	IdlePowerCalibrationWindow = getIntConfig("IDLE_POWER_CALIBRATION_WINDOW", defaultIdlePowerCalibrationWindow)
+	// IdlePowerCalibrationFile persists the fitted idle power across restarts, empty to disable the persistence
+	IdlePowerCalibrationFile = strings.TrimSpace(getConfig("IDLE_POWER_CALIBRATION_FILE", defaultIdlePowerCalibrationFile))
+	// IdlePowerOverrideFile sets the idle power in watts of the node components, e.g. {"pkg": {"0": 20.5}}, it takes precedence over the fit
+	IdlePowerOverrideFile = strings.TrimSpace(getConfig("IDLE_POWER_OVERRIDE_FILE", ""))
+	// EnergyCheckpointFile persists the aggregated node and container energy so that the energy counters continue after a restart,
+	// e.g. /var/lib/kepler/state/energy_checkpoint.json, empty to disable the checkpoint
+	EnergyCheckpointFile = strings.TrimSpace(getConfig("ENERGY_CHECKPOINT_FILE", ""))
+	// EnergyCheckpointIntervalSec is the time in seconds between two writes of the energy checkpoint, it is also written at shutdown
+	EnergyCheckpointIntervalSec = getIntConfig("ENERGY_CHECKPOINT_INTERVAL", defaultEnergyCheckpointIntervalSec)
+	// EnergyCheckpointMaxAgeSec is the age in seconds after which the checkpoint, or a container not seen since, is discarded
+	EnergyCheckpointMaxAgeSec = getIntConfig("ENERGY_CHECKPOINT_MAX_AGE", defaultEnergyCheckpointMaxAgeSec)
+
+	// Redfish BMC used as platform power source, disabled when the endpoint is empty
+	RedfishEndpoint               = strings.TrimSpace(getConfig("REDFISH_ENDPOINT", "")) // e.g. https://<bmc-address>
+	RedfishUsername               = strings.TrimSuffix(getConfig("REDFISH_USERNAME", ""), "\n")
+	RedfishPassword               = strings.TrimSuffix(getConfig("REDFISH_PASSWORD", ""), "\n")
+	RedfishSkipSSLVerify          = getBoolConfig("REDFISH_SKIP_SSL_VERIFY", false)
+	RedfishProbeIntervalInSeconds = getRedfishProbeIntervalConfig()
 
 	versionRegex = regexp.MustCompile(`^(\d+)\.(\d+).`)
 
@@ -114,11 +192,42 @@ func logBoolConfigs() {
 		klog.V(5).Infof("EXPOSE_CGROUP_METRICS: %t", ExposeCgroupMetrics)
 		klog.V(5).Infof("EXPOSE_KUBELET_METRICS: %t", ExposeKubeletMetrics)
 		klog.V(5).Infof("EXPOSE_IRQ_COUNTER_METRICS: %t", ExposeIRQCounterMetrics)
+		klog.V(5).Infof("EXPOSE_TASK_EVENT_METRICS: %t", ExposeTaskEventMetrics)
+		klog.V(5).Infof("EXPOSE_BPF_IO_METRICS: %t", ExposeBPFIOMetrics)
+		klog.V(5).Infof("EXPOSE_SYSTEMD_UNIT_METRICS: %t", ExposeSystemdUnitMetrics)
+		klog.V(5).Infof("EXPOSE_VM_METRICS: %t", ExposeVMMetrics)
+		klog.V(5).Infof("REDFISH_SKIP_SSL_VERIFY: %t", RedfishSkipSSLVerify)
+		klog.V(5).Infof("RESOLVE_POD_OWNER: %t", ResolvePodOwner)
+		klog.V(5).Infof("ENABLE_POD_INFORMER: %t", EnablePodInformer)
+		klog.V(5).Infof("ENABLE_CONTAINER_RUNTIME_RESOLVER: %t", EnableContainerRuntimeResolver)
+		klog.V(5).Infof("ENABLE_CGROUP_DISCOVERY: %t", EnableCgroupDiscovery)
+		klog.V(5).Infof("ENABLE_IDLE_POWER_CALIBRATION: %t", EnableIdlePowerCalibration)
 	}
 }
 
 func LogConfigs() {
 	logBoolConfigs()
+	if ExposeSystemdUnitMetrics && !EnableProcessMetrics {
+		klog.Warningf("EXPOSE_SYSTEMD_UNIT_METRICS requires ENABLE_PROCESS_METRICS, the systemd unit metrics are not exported")
+	}
+	if ExposeVMMetrics && !EnableProcessMetrics {
+		klog.Warningf("EXPOSE_VM_METRICS requires ENABLE_PROCESS_METRICS, the virtual machine metrics are not exported")
+	}
+	klog.V(5).Infof("SAMPLE_PERIOD: %d", SamplePeriodSec)
+	klog.V(5).Infof("POD_LABELS_ALLOWLIST: %v", PodLabelsAllowlist)
+	klog.V(5).Infof("POD_ANNOTATIONS_ALLOWLIST: %v", PodAnnotationsAllowlist)
+	klog.V(5).Infof("MAX_POD_METADATA_LABELS: %d", MaxPodMetadataLabels)
+	klog.V(5).Infof("CONTAINER_RUNTIME_ENDPOINT: %s", ContainerRuntimeEndpoint)
+	klog.V(5).Infof("LIBVIRT_DOMAIN_XML_PATH: %s", LibvirtDomainXMLPath)
+	klog.V(5).Infof("BPF_BACKEND: %s", BPFBackend)
+	klog.V(5).Infof("HARDWARE_COUNTERS: %v", HardwareCounters)
+	klog.V(5).Infof("IDLE_POWER_ATTRIBUTION: %s", IdlePowerAttribution)
+	klog.V(5).Infof("IDLE_POWER_CALIBRATION_WINDOW: %d", IdlePowerCalibrationWindow)
+	klog.V(5).Infof("IDLE_POWER_CALIBRATION_FILE: %s", IdlePowerCalibrationFile)
+	klog.V(5).Infof("IDLE_POWER_OVERRIDE_FILE: %s", IdlePowerOverrideFile)
+	klog.V(5).Infof("ENERGY_CHECKPOINT_FILE: %s", EnergyCheckpointFile)
+	klog.V(5).Infof("ENERGY_CHECKPOINT_INTERVAL: %d", EnergyCheckpointIntervalSec)
+	klog.V(5).Infof("ENERGY_CHECKPOINT_MAX_AGE: %d", EnergyCheckpointMaxAgeSec)
 }
 
 func getBoolConfig(configKey string, defaultBool bool) bool {
@@ -129,6 +238,57 @@ func getBoolConfig(configKey string, defaultBool bool) bool {
 	return strings.ToLower(getConfig(configKey, defaultValue)) == "true"
 }
 
+func getIntConfig(configKey string, defaultInt int) int {
+	defaultValue := strconv.Itoa(defaultInt)
+	value, err := strconv.Atoi(strings.TrimSpace(getConfig(configKey, defaultValue)))
+	if err != nil {
+		klog.Warningf("invalid value for %s, using default %d: %v", configKey, defaultInt, err)
+		return defaultInt
+	}
+	return value
+}
+
+// getListConfig returns the non-empty values of a comma-separated list
+func getListConfig(configKey string) []string {
+	values := []string{}
+	for _, value := range strings.Split(getConfig(configKey, ""), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getSamplePeriodConfig() int {
+	periodSec := getIntConfig("SAMPLE_PERIOD", defaultSamplePeriodSec)
+	if periodSec <= 0 {
+		klog.Warningf("invalid SAMPLE_PERIOD %d, using default %d", periodSec, defaultSamplePeriodSec)
+		return defaultSamplePeriodSec
+	}
+	return periodSec
+}
+
+func getRedfishProbeIntervalConfig() int {
+	intervalSec := getIntConfig("REDFISH_PROBE_INTERVAL_IN_SECONDS", defaultRedfishProbeIntervalSec)
+	if intervalSec <= 0 {
+		klog.Warningf("invalid REDFISH_PROBE_INTERVAL_IN_SECONDS %d, using default %d", intervalSec, defaultRedfishProbeIntervalSec)
+		return defaultRedfishProbeIntervalSec
+	}
+	return intervalSec
+}
+
+func getIdlePowerAttributionConfig() string {
+	attribution := strings.ToLower(strings.TrimSpace(getConfig("IDLE_POWER_ATTRIBUTION", IdleAttributionEven)))
+	switch attribution {
+	case IdleAttributionEven, IdleAttributionRequests, IdleAttributionLimits, IdleAttributionNode:
+		return attribution
+	}
+	klog.Warningf("invalid IDLE_POWER_ATTRIBUTION %q, expected %s, %s, %s or %s, using default %s", attribution,
+		IdleAttributionEven, IdleAttributionRequests, IdleAttributionLimits, IdleAttributionNode, IdleAttributionEven)
+	return IdleAttributionEven
+}
+
 func getConfig(configKey, defaultValue string) (result string) {
 	result = string([]byte(defaultValue))
 	key := string([]byte(configKey))
@@ -181,6 +341,30 @@ func SetEnabledHardwareCounterMetrics(enabled bool) {
 	ExposeHardwareCounterMetrics = enabled && ExposeHardwareCounterMetrics
 }
 
+// SetSamplePeriodSec sets the time in seconds between two metric collections
+func SetSamplePeriodSec(periodSec int) {
+	if periodSec <= 0 {
+		klog.Warningf("invalid sample period %d, keeping %d seconds", periodSec, SamplePeriodSec)
+		return
+	}
+	SamplePeriodSec = periodSec
+}
+
+// GetSamplePeriod returns the time between two metric collections
+func GetSamplePeriod() time.Duration {
+	return time.Duration(SamplePeriodSec) * time.Second
+}
+
+// GetEnergyCheckpointInterval returns the time between two writes of the energy checkpoint
+func GetEnergyCheckpointInterval() time.Duration {
+	return time.Duration(EnergyCheckpointIntervalSec) * time.Second
+}
+
+// GetEnergyCheckpointMaxAge returns the age after which the checkpointed energy is discarded
+func GetEnergyCheckpointMaxAge() time.Duration {
+	return time.Duration(EnergyCheckpointMaxAgeSec) * time.Second
+}
+
 // SetEnabledGPU enables the exposure of gpu metrics
 func SetEnabledGPU(enabled bool) {
 	// set to true if any config source set it to true
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -126,4 +127,44 @@ var _ = Describe("Test Configuration", func() {
 		Expect(initModelURL).NotTo(Equal(""))
 
 	})
+	It("Test sample period", func() {
+		os.Setenv("SAMPLE_PERIOD", "15")
+		defer os.Unsetenv("SAMPLE_PERIOD")
+		Expect(getSamplePeriodConfig()).To(Equal(15))
+
+		os.Setenv("SAMPLE_PERIOD", "0")
+		Expect(getSamplePeriodConfig()).To(Equal(defaultSamplePeriodSec))
+		os.Setenv("SAMPLE_PERIOD", "abc")
+		Expect(getSamplePeriodConfig()).To(Equal(defaultSamplePeriodSec))
+
+		SetSamplePeriodSec(1)
+		Expect(GetSamplePeriod()).To(Equal(time.Second))
+		SetSamplePeriodSec(-1)
+		Expect(SamplePeriodSec).To(Equal(1))
+		SetSamplePeriodSec(defaultSamplePeriodSec)
+	})
+	It("Test redfish probe interval", func() {
+		Expect(getRedfishProbeIntervalConfig()).To(Equal(defaultRedfishProbeIntervalSec))
+
+		os.Setenv("REDFISH_PROBE_INTERVAL_IN_SECONDS", "30")
+		defer os.Unsetenv("REDFISH_PROBE_INTERVAL_IN_SECONDS")
+		Expect(getRedfishProbeIntervalConfig()).To(Equal(30))
+		os.Setenv("REDFISH_PROBE_INTERVAL_IN_SECONDS", "-5")
+		Expect(getRedfishProbeIntervalConfig()).To(Equal(defaultRedfishProbeIntervalSec))
+	})
+	It("Test idle power attribution", func() {
+		Expect(getIdlePowerAttributionConfig()).To(Equal(IdleAttributionEven))
+
+		os.Setenv("IDLE_POWER_ATTRIBUTION", " Requests ")
+		defer os.Unsetenv("IDLE_POWER_ATTRIBUTION")
+		Expect(getIdlePowerAttributionConfig()).To(Equal(IdleAttributionRequests))
+		os.Setenv("IDLE_POWER_ATTRIBUTION", "request")
+		Expect(getIdlePowerAttributionConfig()).To(Equal(IdleAttributionEven))
+	})
+	It("Test list config", func() {
+		os.Setenv("POD_LABELS_ALLOWLIST", " team, cost-center,,")
+		defer os.Unsetenv("POD_LABELS_ALLOWLIST")
+		Expect(getListConfig("POD_LABELS_ALLOWLIST")).To(Equal([]string{"team", "cost-center"}))
+		Expect(getListConfig("POD_ANNOTATIONS_ALLOWLIST")).To(BeEmpty())
+	})
 })
@@ -28,6 +28,16 @@ const (
 	IRQNetTXLabel = "irq_net_tx"
 	IRQNetRXLabel = "irq_net_rx"
 	IRQBlockLabel = "irq_block"
+	// task events counted per process by the eBPF program
+	VoluntaryCtxSwitch   = "voluntary_context_switches"
+	InvoluntaryCtxSwitch = "involuntary_context_switches"
+	CPUMigration         = "cpu_migrations"
+	PageFaultMajor       = "page_faults_major"
+	PageFaultMinor       = "page_faults_minor"
+	NetTxBytes           = "net_tx_bytes"
+	NetRxBytes           = "net_rx_bytes"
+	BlockReadBytes       = "block_read_bytes"
+	BlockWriteBytes      = "block_write_bytes"
 
 	// cgroup - cgroup package
 	CgroupfsMemory       = "cgroupfs_memory_usage_bytes"
@@ -53,4 +63,10 @@ const (
 	// GPU
 	GPUSMUtilization  = "gpu_sm_util"
 	GPUMemUtilization = "gpu_mem_util"
+
+	// idle power attribution policies
+	IdleAttributionEven     = "even"
+	IdleAttributionRequests = "requests"
+	IdleAttributionLimits   = "limits"
+	IdleAttributionNode     = "node"
 )
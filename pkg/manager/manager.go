@@ -20,12 +20,7 @@ import (
 	"time"
 
 	"github.com/sustainable-computing-io/kepler/pkg/collector"
-)
-
-const (
-	// SamplePeriodSec is the time in seconds that the reader will wait before reading the metrics again
-	SamplePeriodSec = 3
-	samplePeriod    = SamplePeriodSec * 1000 * time.Millisecond
+	"github.com/sustainable-computing-io/kepler/pkg/config"
 )
 
 type CollectorManager struct {
@@ -34,6 +29,9 @@ type CollectorManager struct {
 
 	// PrometheusCollector implements the external Collector interface provided by the Prometheus client
 	PrometheusCollector *collector.PrometheusCollector
+
+	// stopped is set by Stop, it is guarded by the PrometheusCollector lock
+	stopped bool
 }
 
 func New() *CollectorManager {
@@ -44,7 +42,9 @@ func New() *CollectorManager {
 	manager.PrometheusCollector.NodeMetrics = &manager.MetricCollector.NodeMetrics
 	manager.PrometheusCollector.ContainersMetrics = &manager.MetricCollector.ContainersMetrics
 	manager.PrometheusCollector.ProcessMetrics = &manager.MetricCollector.ProcessMetrics
-	manager.PrometheusCollector.SamplePeriodSec = SamplePeriodSec
+	manager.PrometheusCollector.PodsMetrics = &manager.MetricCollector.PodsMetrics
+	manager.PrometheusCollector.SystemdUnitsMetrics = &manager.MetricCollector.SystemdUnitsMetrics
+	manager.PrometheusCollector.VMsMetrics = &manager.MetricCollector.VMsMetrics
 	return manager
 }
 
@@ -54,13 +54,19 @@ func (m *CollectorManager) Start() error {
 	}
 
 	go func() {
-		ticker := time.NewTicker(samplePeriod)
+		// the reader will wait the sample period before reading the metrics again
+		ticker := time.NewTicker(config.GetSamplePeriod())
 		for {
 			// wait x seconds before updating the metrics
 			<-ticker.C
 
 			// acquire the lock to wait prometheus finish the metric collection before updating the metrics
 			m.PrometheusCollector.Mx.Lock()
+			if m.stopped {
+				m.PrometheusCollector.Mx.Unlock()
+				ticker.Stop()
+				return
+			}
 			m.MetricCollector.Update()
 			m.PrometheusCollector.Mx.Unlock()
 		}
@@ -68,3 +74,15 @@ func (m *CollectorManager) Start() error {
 
 	return nil
 }
+
+// Stop waits for the running metric update to finish and releases the collector, the metrics are no longer updated
+// but the last values can still be scraped
+func (m *CollectorManager) Stop() {
+	m.PrometheusCollector.Mx.Lock()
+	defer m.PrometheusCollector.Mx.Unlock()
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	m.MetricCollector.Destroy()
+}
@@ -12,10 +12,18 @@ var _ = Describe("Manager", func() {
 
 	It("Should work properly", func() {
 		CollectorManager := New()
-		Expect(float64(SamplePeriodSec)).To(Equal(CollectorManager.PrometheusCollector.SamplePeriodSec))
 		err := CollectorManager.Start()
 		// for no bcc tag in CI
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("Should release the lock when stopped", func() {
+		CollectorManager := New()
+		CollectorManager.Stop()
+		// the collector is only released once
+		CollectorManager.Stop()
+		Expect(CollectorManager.PrometheusCollector.Mx.TryLock()).To(BeTrue())
+		CollectorManager.PrometheusCollector.Mx.Unlock()
+	})
+
 })
@@ -34,7 +34,7 @@ func benchmarkNtesting(b *testing.B, continerNumber int) {
 		_ = containersMetrics["container"+strconv.Itoa(n)].CounterStats[config.CoreUsageMetric].AddNewDelta(100)
 	}
 	b.ResetTimer()
-	model.UpdateContainerEnergyByTrainedPowerModel(containersMetrics)
+	model.UpdateContainerEnergyByTrainedPowerModel(containersMetrics, float64(config.SamplePeriodSec))
 	b.StopTimer()
 }
 
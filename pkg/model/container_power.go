@@ -80,11 +80,12 @@ func UpdateContainerEnergy(containersMetrics map[string]*collector_metric.Contai
 	if components.IsSystemCollectionSupported() {
 		local.UpdateContainerEnergyByRatioPowerModel(containersMetrics, nodeMetrics)
 	} else {
-		UpdateContainerEnergyByTrainedPowerModel(containersMetrics)
+		UpdateContainerEnergyByTrainedPowerModel(containersMetrics, nodeMetrics.GetElapsedSec())
 	}
 }
 
-func UpdateContainerEnergyByTrainedPowerModel(containersMetrics map[string]*collector_metric.ContainerMetrics) {
+// UpdateContainerEnergyByTrainedPowerModel estimates the containers power and integrates it over elapsedSec
+func UpdateContainerEnergyByTrainedPowerModel(containersMetrics map[string]*collector_metric.ContainerMetrics, elapsedSec float64) {
 	var enabled bool
 	// convert the container metrics map to an array since the model server does not receive structured data
 	// TODO: send data to model server via protobuf instead of no structured data
@@ -92,7 +93,7 @@ func UpdateContainerEnergyByTrainedPowerModel(containersMetrics map[string]*coll
 
 	totalPowerValid, totalContainerPowers := getContainerTotalPower(containerMetricValuesOnly)
 
-	enabled, containerComponentPowers := getContainerComponentPowers(containerMetricValuesOnly)
+	enabled, containerComponentPowers := getContainerComponentPowers(containerMetricValuesOnly, elapsedSec)
 	if !enabled {
 		klog.V(5).Infoln("No ContainerComponentPower Model")
 		return
@@ -101,7 +102,7 @@ func UpdateContainerEnergyByTrainedPowerModel(containersMetrics map[string]*coll
 	if totalPowerValid {
 		for index, componentPower := range containerComponentPowers {
 			// TODO: include GPU into consideration
-			containerOtherPowers[index] = uint64(totalContainerPowers[index]) - componentPower.Pkg - componentPower.DRAM
+			containerOtherPowers[index] = powerToEnergy(totalContainerPowers[index], elapsedSec) - componentPower.Pkg - componentPower.DRAM
 		}
 	}
 
@@ -142,7 +143,7 @@ func getContainerTotalPower(containerMetricValuesOnly [][]float64) (valid bool,
 }
 
 // getContainerComponentPowers returns estimated pods' RAPL power
-func getContainerComponentPowers(containerMetricValuesOnly [][]float64) (bool, []source.NodeComponentsEnergy) {
+func getContainerComponentPowers(containerMetricValuesOnly [][]float64, elapsedSec float64) (bool, []source.NodeComponentsEnergy) {
 	podNumber := len(containerMetricValuesOnly)
 	if ContainerComponentPowerModelValid {
 		powers, err := ContainerComponentPowerModelFunc(containerMetricValuesOnly, collector_metric.NodeMetadataValues)
@@ -151,10 +152,10 @@ func getContainerComponentPowers(containerMetricValuesOnly [][]float64) (bool, [
 		}
 		raplPowers := make([]source.NodeComponentsEnergy, podNumber)
 		for index := 0; index < podNumber; index++ {
-			pkgPower := getComponentPower(powers, "pkg", index)
-			corePower := getComponentPower(powers, "core", index)
-			uncorePower := getComponentPower(powers, "uncore", index)
-			dramPower := getComponentPower(powers, "dram", index)
+			pkgPower := getComponentPower(powers, "pkg", index, elapsedSec)
+			corePower := getComponentPower(powers, "core", index, elapsedSec)
+			uncorePower := getComponentPower(powers, "uncore", index, elapsedSec)
+			dramPower := getComponentPower(powers, "dram", index, elapsedSec)
 			raplPowers[index] = fillRAPLPower(pkgPower, corePower, uncorePower, dramPower)
 		}
 		return true, raplPowers
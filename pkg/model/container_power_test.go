@@ -135,6 +135,7 @@ var _ = Describe("ContainerPower", func() {
 	Context("with edge case", func() {
 		BeforeEach(func() {
 			source.SystemCollectionSupported = false // disable the system power collection to use the prediction power model
+			config.SamplePeriodSec = 1               // the expected energy is the estimated power during one second
 			setCollectorMetrics()
 			containersMetrics = createMockContainersMetrics()
 			nodeMetrics = createMockNodeMetrics(containersMetrics)
@@ -23,6 +23,7 @@ package local
 
 import (
 	"math"
+	"sort"
 
 	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/power/accelerator"
@@ -55,6 +56,221 @@ func getEnergyRatio(unitResUsage, totalResUsage, resEnergyUtilization, totalNumb
 	return uint64(math.Ceil(power))
 }
 
+// isGaugeMetric returns true for the usage metrics that are read as a current value, e.g. the memory usage, instead of a counter
+func isGaugeMetric(metric string) bool {
+	switch metric {
+	case config.CgroupfsMemory, config.CgroupfsKernelMemory, config.CgroupfsTCPMemory, config.KubeletContainerMemory:
+		return true
+	}
+	return false
+}
+
+// getContainerResUsage returns the container usage of a resource, which is the current value for the gauge metrics and the delta otherwise.
+// It returns false if the container does not collect the metric.
+func getContainerResUsage(container *collector_metric.ContainerMetrics, metric string) (float64, bool) {
+	delta, aggr, err := container.GetDeltaAndAggrValue(metric)
+	if err != nil {
+		return 0, false
+	}
+	if isGaugeMetric(metric) {
+		return float64(aggr), true
+	}
+	return float64(delta), true
+}
+
+// getNodeResUsage returns the node usage of a resource, the node usage of the gauge metrics is the sum of the current container values
+func getNodeResUsage(containersMetrics map[string]*collector_metric.ContainerMetrics, nodeMetrics *collector_metric.NodeMetrics, metric string) float64 {
+	if !isGaugeMetric(metric) {
+		return nodeMetrics.GetNodeResUsagePerResType(metric)
+	}
+	var usage float64
+	for _, container := range containersMetrics {
+		containerUsage, _ := getContainerResUsage(container, metric)
+		usage += containerUsage
+	}
+	return usage
+}
+
+// getContainerCoreEnergyByCPU distributes the core dynamic energy among the containers based on the energy consumption of each CPU
+// and on the time that each container ran on each CPU. It returns nil if the per CPU energy or the per CPU time is not available.
+func getContainerCoreEnergyByCPU(containersMetrics map[string]*collector_metric.ContainerMetrics, nodeMetrics *collector_metric.NodeMetrics, coreDynPower float64) map[string]uint64 {
+	cpuEnergy := nodeMetrics.TotalEnergyInCPU.Stat
+	if len(cpuEnergy) == 0 {
+		return nil
+	}
+	cpuTime := map[string]uint64{}
+	for _, container := range containersMetrics {
+		for cpu, stat := range container.CPUTimePerCPU.Stat {
+			cpuTime[cpu] += stat.Delta
+		}
+	}
+	// the weight of a container is the sum of the energy of each CPU proportionally to the container CPU time on it
+	weights := map[string]float64{}
+	for containerID, container := range containersMetrics {
+		for cpu, stat := range container.CPUTimePerCPU.Stat {
+			energy, found := cpuEnergy[cpu]
+			if !found || stat.Delta == 0 {
+				continue
+			}
+			weights[containerID] += float64(energy.Delta) * float64(stat.Delta) / float64(cpuTime[cpu])
+		}
+	}
+	// the total weight is the energy of the CPUs used by the containers, which is summed as integers
+	// so that the result does not depend on the map iteration order
+	totalWeight := float64(0)
+	for cpu, t := range cpuTime {
+		if energy, found := cpuEnergy[cpu]; found && t > 0 {
+			totalWeight += float64(energy.Delta)
+		}
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	// the per CPU energy is only used to split the node core dynamic energy, which excludes the idle energy
+	containerCoreEnergy := map[string]uint64{}
+	for containerID, weight := range weights {
+		containerCoreEnergy[containerID] = uint64(math.Ceil(coreDynPower * weight / totalWeight))
+	}
+	return containerCoreEnergy
+}
+
+// getContainerPackageShares returns the fraction of the CPU time that each container ran on each CPU package.
+// The containers which did not report CPU time in the sample are spread like the node CPU time.
+// It returns nil if the CPU time per package is not available.
+func getContainerPackageShares(containersMetrics map[string]*collector_metric.ContainerMetrics) map[string]map[string]float64 {
+	nodeTime := map[string]uint64{}
+	var totalTime uint64
+	for _, container := range containersMetrics {
+		for pkg, stat := range container.CPUTimePerPackage.Stat {
+			nodeTime[pkg] += stat.Delta
+			totalTime += stat.Delta
+		}
+	}
+	if totalTime == 0 {
+		return nil
+	}
+	shares := map[string]map[string]float64{}
+	for containerID, container := range containersMetrics {
+		var containerTime uint64
+		for _, stat := range container.CPUTimePerPackage.Stat {
+			containerTime += stat.Delta
+		}
+		shares[containerID] = map[string]float64{}
+		for pkg, t := range nodeTime {
+			if containerTime == 0 {
+				shares[containerID][pkg] = float64(t) / float64(totalTime)
+			} else if stat, found := container.CPUTimePerPackage.Stat[pkg]; found {
+				shares[containerID][pkg] = float64(stat.Delta) / float64(containerTime)
+			}
+		}
+	}
+	return shares
+}
+
+// getContainerEnergyByPackage distributes the dynamic energy of each CPU package among the containers based on their usage
+// on the package, which is the container usage weighted by the fraction of its CPU time on the package, so that a container
+// pinned to a socket is not charged for the energy of the other sockets. The package energy and the CPU time per package are both
+// keyed by the physical_package_id of the CPUs. It returns nil if the node has a single package or if the CPU time per package
+// is not available.
+func getContainerEnergyByPackage(containersMetrics map[string]*collector_metric.ContainerMetrics, packageShares map[string]map[string]float64,
+	packageEnergy *collector_metric.UInt64StatCollection, metric string) map[string]uint64 {
+	if packageShares == nil || len(packageEnergy.Stat) < 2 {
+		return nil
+	}
+	// the containers are sorted so that the split does not depend on the map iteration order
+	containerIDs := []string{}
+	usages := map[string]float64{}
+	for containerID, container := range containersMetrics {
+		if usage, ok := getContainerResUsage(container, metric); ok {
+			containerIDs = append(containerIDs, containerID)
+			usages[containerID] = usage
+		}
+	}
+	sort.Strings(containerIDs)
+	containerEnergy := map[string]uint64{}
+	weights := make([]float64, len(containerIDs))
+	for pkg, energy := range packageEnergy.Stat {
+		for i, containerID := range containerIDs {
+			weights[i] = usages[containerID] * packageShares[containerID][pkg]
+		}
+		for i, e := range splitEnergy(energy.Delta, weights) {
+			containerEnergy[containerIDs[i]] += e
+		}
+	}
+	return containerEnergy
+}
+
+// splitEnergy divides the energy proportionally to the weights, or evenly if all weights are 0.
+// The shares are the differences of the rounded cumulative shares, so that they sum up to the energy.
+func splitEnergy(energy uint64, weights []float64) []uint64 {
+	var totalWeight float64
+	for _, w := range weights {
+		totalWeight += w
+	}
+	evenly := totalWeight == 0
+	if evenly {
+		totalWeight = float64(len(weights))
+	}
+	shares := make([]uint64, len(weights))
+	var cumulativeWeight float64
+	var attributed uint64
+	for i, w := range weights {
+		if evenly {
+			w = 1
+		}
+		cumulativeWeight += w
+		cumulativeEnergy := uint64(math.Round(float64(energy) * cumulativeWeight / totalWeight))
+		shares[i] = cumulativeEnergy - attributed
+		attributed = cumulativeEnergy
+	}
+	return shares
+}
+
+// containerResource returns a resource of the container spec used to weight its share of the idle energy
+type containerResource func(container *collector_metric.ContainerMetrics) uint64
+
+var (
+	cpuRequest    containerResource = func(container *collector_metric.ContainerMetrics) uint64 { return container.CPURequest }
+	cpuLimit      containerResource = func(container *collector_metric.ContainerMetrics) uint64 { return container.CPULimit }
+	memoryRequest containerResource = func(container *collector_metric.ContainerMetrics) uint64 { return container.MemoryRequest }
+	memoryLimit   containerResource = func(container *collector_metric.ContainerMetrics) uint64 { return container.MemoryLimit }
+)
+
+// getContainerIdleEnergy distributes the idle energy among the containers according to IDLE_POWER_ATTRIBUTION.
+// With the requests and limits policies, the containers without the resource are not attributed idle energy,
+// and the idle energy is evenly divided if no container sets the resource. With the node policy, the containers
+// are not attributed idle energy, which is only reported in the node metrics.
+func getContainerIdleEnergy(containersMetrics map[string]*collector_metric.ContainerMetrics, idleEnergy uint64, request, limit containerResource) map[string]uint64 {
+	containerIdleEnergy := map[string]uint64{}
+	var resource containerResource
+	switch config.IdlePowerAttribution {
+	case config.IdleAttributionNode:
+		return containerIdleEnergy
+	case config.IdleAttributionRequests:
+		resource = request
+	case config.IdleAttributionLimits:
+		resource = limit
+	case config.IdleAttributionEven:
+	default:
+		klog.V(5).Infof("unknown idle power attribution %q, the idle energy is evenly divided", config.IdlePowerAttribution)
+	}
+	containerNumber := uint64(len(containersMetrics))
+	var totalResource uint64
+	if resource != nil {
+		for _, container := range containersMetrics {
+			totalResource += resource(container)
+		}
+	}
+	for containerID, container := range containersMetrics {
+		if totalResource == 0 {
+			containerIdleEnergy[containerID] = idleEnergy / containerNumber
+		} else {
+			containerIdleEnergy[containerID] = getEnergyRatio(float64(resource(container)), float64(totalResource), float64(idleEnergy), float64(containerNumber))
+		}
+	}
+	return containerIdleEnergy
+}
+
 // UpdateContainerEnergyByRatioPowerModel calculates the container energy consumption based on the resource utilization ratio
 func UpdateContainerEnergyByRatioPowerModel(containersMetrics map[string]*collector_metric.ContainerMetrics, nodeMetrics *collector_metric.NodeMetrics) {
 	pkgDynPower := float64(nodeMetrics.GetSumDeltaDynEnergyFromAllSources(collector_metric.PKG))
@@ -65,32 +281,52 @@ func UpdateContainerEnergyByRatioPowerModel(containersMetrics map[string]*collec
 	gpuDynPower := float64(nodeMetrics.GetSumDeltaDynEnergyFromAllSources(collector_metric.GPU))
 
 	containerNumber := float64(len(containersMetrics))
-	// evenly divide the idle power to all containers. TODO: use the container resource request
-	pkgIdlePowerPerContainer := nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.PKG) / uint64(containerNumber)
-	coreIdlePowerPerContainer := nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.CORE) / uint64(containerNumber)
-	uncoreIdlePowerPerContainer := nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.UNCORE) / uint64(containerNumber)
-	dramIdlePowerPerContainer := nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.DRAM) / uint64(containerNumber)
-	otherIdlePowerPerContainer := nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.OTHER) / uint64(containerNumber)
+	// the idle energy of the DRAM is attributed by the memory of the containers and the other components by the CPU
+	pkgIdleEnergy := getContainerIdleEnergy(containersMetrics, nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.PKG), cpuRequest, cpuLimit)
+	coreIdleEnergy := getContainerIdleEnergy(containersMetrics, nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.CORE), cpuRequest, cpuLimit)
+	uncoreIdleEnergy := getContainerIdleEnergy(containersMetrics, nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.UNCORE), cpuRequest, cpuLimit)
+	dramIdleEnergy := getContainerIdleEnergy(containersMetrics, nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.DRAM), memoryRequest, memoryLimit)
+	otherIdleEnergy := getContainerIdleEnergy(containersMetrics, nodeMetrics.GetSumDeltaIdleEnergyromAllSources(collector_metric.OTHER), cpuRequest, cpuLimit)
 
 	containerUncoreEnergy := uint64(math.Ceil(uncoreDynPower / containerNumber))
 	containerOtherHostComponentsEnergy := uint64(math.Ceil(otherDynPower / containerNumber))
-	NodeCoreUsageMetric := nodeMetrics.GetNodeResUsagePerResType(config.CoreUsageMetric)
-	NodeDRAMUsageMetric := nodeMetrics.GetNodeResUsagePerResType(config.DRAMUsageMetric)
+	NodeCoreUsageMetric := getNodeResUsage(containersMetrics, nodeMetrics, config.CoreUsageMetric)
+	NodeDRAMUsageMetric := getNodeResUsage(containersMetrics, nodeMetrics, config.DRAMUsageMetric)
 	NodeGpuUsageMetric := nodeMetrics.GetNodeResUsagePerResType(config.GpuUsageMetric)
+	// when the hardware exposes per-core energy counters, the core energy is attributed by the CPUs on which each container ran
+	containersCoreEnergyByCPU := getContainerCoreEnergyByCPU(containersMetrics, nodeMetrics, coreDynPower)
+	// on multi-socket nodes, the energy of each package is attributed by the usage of the containers on the package
+	packageShares := getContainerPackageShares(containersMetrics)
+	containersPkgEnergyByPackage := getContainerEnergyByPackage(containersMetrics, packageShares, nodeMetrics.DynEnergyInPkg, config.CoreUsageMetric)
+	containersCoreEnergyByPackage := getContainerEnergyByPackage(containersMetrics, packageShares, nodeMetrics.DynEnergyInCore, config.CoreUsageMetric)
+	containersDRAMEnergyByPackage := getContainerEnergyByPackage(containersMetrics, packageShares, nodeMetrics.DynEnergyInDRAM, config.DRAMUsageMetric)
 	for containerID, container := range containersMetrics {
 		var containerResUsage, nodeTotalResUsage float64
 
 		// calculate the container package/socket energy consumption
-		if _, ok := container.CounterStats[config.CoreUsageMetric]; ok {
-			containerResUsage = float64(container.CounterStats[config.CoreUsageMetric].Delta)
+		containerCoreUsage, hasCoreUsage := getContainerResUsage(container, config.CoreUsageMetric)
+		if hasCoreUsage {
+			containerResUsage = containerCoreUsage
 			nodeTotalResUsage = NodeCoreUsageMetric
 			containerPkgEnergy := getEnergyRatio(containerResUsage, nodeTotalResUsage, pkgDynPower, containerNumber)
+			if containersPkgEnergyByPackage != nil {
+				containerPkgEnergy = containersPkgEnergyByPackage[containerID]
+			}
 			if err := containersMetrics[containerID].DynEnergyInPkg.AddNewDelta(containerPkgEnergy); err != nil {
 				klog.Infoln(err)
 			}
+		}
 
-			// calculate the container core energy consumption
+		// calculate the container core energy consumption
+		if containersCoreEnergyByCPU != nil {
+			if err := containersMetrics[containerID].DynEnergyInCore.AddNewDelta(containersCoreEnergyByCPU[containerID]); err != nil {
+				klog.Infoln(err)
+			}
+		} else if hasCoreUsage {
 			containerCoreEnergy := getEnergyRatio(containerResUsage, nodeTotalResUsage, coreDynPower, containerNumber)
+			if containersCoreEnergyByPackage != nil {
+				containerCoreEnergy = containersCoreEnergyByPackage[containerID]
+			}
 			if err := containersMetrics[containerID].DynEnergyInCore.AddNewDelta(containerCoreEnergy); err != nil {
 				klog.Infoln(err)
 			}
@@ -102,10 +338,13 @@ func UpdateContainerEnergyByRatioPowerModel(containersMetrics map[string]*collec
 		}
 
 		// calculate the container dram energy consumption
-		if _, ok := container.CounterStats[config.DRAMUsageMetric]; ok {
-			containerResUsage = float64(container.CounterStats[config.DRAMUsageMetric].Delta)
+		if containerDRAMUsage, ok := getContainerResUsage(container, config.DRAMUsageMetric); ok {
+			containerResUsage = containerDRAMUsage
 			nodeTotalResUsage = NodeDRAMUsageMetric
 			containerDramEnergy := getEnergyRatio(containerResUsage, nodeTotalResUsage, dramDynPower, containerNumber)
+			if containersDRAMEnergyByPackage != nil {
+				containerDramEnergy = containersDRAMEnergyByPackage[containerID]
+			}
 			if err := containersMetrics[containerID].DynEnergyInDRAM.AddNewDelta(containerDramEnergy); err != nil {
 				klog.Infoln(err)
 			}
@@ -129,19 +368,19 @@ func UpdateContainerEnergyByRatioPowerModel(containersMetrics map[string]*collec
 			klog.Infoln(err)
 		}
 		// Idle energy
-		if err := containersMetrics[containerID].IdleEnergyInPkg.AddNewDelta(pkgIdlePowerPerContainer); err != nil {
+		if err := containersMetrics[containerID].IdleEnergyInPkg.AddNewDelta(pkgIdleEnergy[containerID]); err != nil {
 			klog.Infoln(err)
 		}
-		if err := containersMetrics[containerID].IdleEnergyInCore.AddNewDelta(coreIdlePowerPerContainer); err != nil {
+		if err := containersMetrics[containerID].IdleEnergyInCore.AddNewDelta(coreIdleEnergy[containerID]); err != nil {
 			klog.Infoln(err)
 		}
-		if err := containersMetrics[containerID].IdleEnergyInUncore.AddNewDelta(uncoreIdlePowerPerContainer); err != nil {
+		if err := containersMetrics[containerID].IdleEnergyInUncore.AddNewDelta(uncoreIdleEnergy[containerID]); err != nil {
 			klog.Infoln(err)
 		}
-		if err := containersMetrics[containerID].IdleEnergyInDRAM.AddNewDelta(dramIdlePowerPerContainer); err != nil {
+		if err := containersMetrics[containerID].IdleEnergyInDRAM.AddNewDelta(dramIdleEnergy[containerID]); err != nil {
 			klog.Infoln(err)
 		}
-		if err := containersMetrics[containerID].IdleEnergyInOther.AddNewDelta(otherIdlePowerPerContainer); err != nil {
+		if err := containersMetrics[containerID].IdleEnergyInOther.AddNewDelta(otherIdleEnergy[containerID]); err != nil {
 			klog.Infoln(err)
 		}
 	}
@@ -117,4 +117,236 @@ var _ = Describe("Test Ratio Unit", func() {
 		Expect(containersMetrics["containerB"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(9)))
 		Expect(containersMetrics["containerC"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(9)))
 	})
+	It("GetContainerCoreEnergyByCPU", func() {
+		containersMetrics := map[string]*collector_metric.ContainerMetrics{}
+		// containerA runs on cpu0, containerB on cpu1 and containerC on both, all with the same CPU usage
+		cpuTimes := map[string]map[string]uint64{
+			"containerA": {"0": 100},
+			"containerB": {"1": 100},
+			"containerC": {"0": 50, "1": 50},
+		}
+		for containerID, cpuTime := range cpuTimes {
+			containersMetrics[containerID] = collector_metric.NewContainerMetrics(containerID, "pod", "test")
+			containersMetrics[containerID].CounterStats[config.CoreUsageMetric] = &collector_metric.UInt64Stat{}
+			err := containersMetrics[containerID].CounterStats[config.CoreUsageMetric].AddNewDelta(100)
+			Expect(err).NotTo(HaveOccurred())
+			for cpu, t := range cpuTime {
+				containersMetrics[containerID].CPUTimePerCPU.AddDeltaStat(cpu, t)
+			}
+		}
+
+		nodeMetrics := collector_metric.NewNodeMetrics()
+		collector_metric.ContainerMetricNames = []string{config.CoreUsageMetric}
+		nodeMetrics.AddNodeResUsageFromContainerResUsage(containersMetrics)
+		nodeMetrics.DynEnergyInCore.SetDeltaStat("0", 400)
+		// cpu0 consumed 3J and cpu1 1J
+		nodeMetrics.SetNodeCPUEnergy(map[int]uint64{0: 1000, 1: 1000})
+		nodeMetrics.SetNodeCPUEnergy(map[int]uint64{0: 4000, 1: 2000})
+
+		UpdateContainerEnergyByRatioPowerModel(containersMetrics, nodeMetrics)
+		// the weights are 3000*100/150=2000 for A, 1000*100/150=666 for B and 3000*50/150+1000*50/150=1333 for C
+		Expect(containersMetrics["containerA"].DynEnergyInCore.Delta).Should(BeEquivalentTo(uint64(200)))
+		Expect(containersMetrics["containerB"].DynEnergyInCore.Delta).Should(BeEquivalentTo(uint64(67)))
+		Expect(containersMetrics["containerC"].DynEnergyInCore.Delta).Should(BeEquivalentTo(uint64(134)))
+	})
+
+	It("GetContainerEnergyRatio with cgroup metrics", func() {
+		coreUsageMetric, dramUsageMetric := config.CoreUsageMetric, config.DRAMUsageMetric
+		defer func() { config.CoreUsageMetric, config.DRAMUsageMetric = coreUsageMetric, dramUsageMetric }()
+		config.CoreUsageMetric, config.DRAMUsageMetric = config.CgroupfsCPU, config.CgroupfsMemory
+
+		containersMetrics := map[string]*collector_metric.ContainerMetrics{}
+		// containerA used 300us of CPU and containerB 100us, containerA uses 100 bytes of memory and containerB 300 bytes
+		usages := map[string][]uint64{"containerA": {300, 100}, "containerB": {100, 300}}
+		for containerID, usage := range usages {
+			containersMetrics[containerID] = collector_metric.NewContainerMetrics(containerID, "pod", "test")
+			cpu := &collector_metric.UInt64StatCollection{Stat: map[string]*collector_metric.UInt64Stat{}}
+			cpu.SetAggrStat(containerID, 1000)
+			cpu.SetAggrStat(containerID, 1000+usage[0])
+			memory := &collector_metric.UInt64StatCollection{Stat: map[string]*collector_metric.UInt64Stat{}}
+			memory.SetAggrStat(containerID, usage[1])
+			containersMetrics[containerID].CgroupFSStats[config.CgroupfsCPU] = cpu
+			containersMetrics[containerID].CgroupFSStats[config.CgroupfsMemory] = memory
+		}
+
+		nodeMetrics := collector_metric.NewNodeMetrics()
+		collector_metric.ContainerMetricNames = []string{config.CgroupfsCPU, config.CgroupfsMemory}
+		nodeMetrics.AddNodeResUsageFromContainerResUsage(containersMetrics)
+		Expect(nodeMetrics.ResourceUsage[config.CgroupfsCPU]).Should(BeEquivalentTo(400))
+		nodeMetrics.DynEnergyInPkg.SetDeltaStat("0", 400)
+		nodeMetrics.DynEnergyInDRAM.SetDeltaStat("0", 400)
+
+		UpdateContainerEnergyByRatioPowerModel(containersMetrics, nodeMetrics)
+		Expect(containersMetrics["containerA"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(300)))
+		Expect(containersMetrics["containerB"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(100)))
+		// the memory usage is a gauge, so the dram energy is split by the current memory usage
+		Expect(containersMetrics["containerA"].DynEnergyInDRAM.Delta).Should(BeEquivalentTo(uint64(100)))
+		Expect(containersMetrics["containerB"].DynEnergyInDRAM.Delta).Should(BeEquivalentTo(uint64(300)))
+	})
+
+	It("GetContainerCoreEnergyByCPU without per CPU energy", func() {
+		containersMetrics := map[string]*collector_metric.ContainerMetrics{}
+		containersMetrics["containerA"] = collector_metric.NewContainerMetrics("containerA", "podA", "test")
+		containersMetrics["containerA"].CPUTimePerCPU.AddDeltaStat("0", 100)
+		Expect(getContainerCoreEnergyByCPU(containersMetrics, collector_metric.NewNodeMetrics(), 400)).To(BeNil())
+	})
+	It("GetContainerEnergyByPackage with asymmetric load", func() {
+		containersMetrics := map[string]*collector_metric.ContainerMetrics{}
+		// containerA is pinned to package 0, containerB to package 1 and containerC runs on both, all with the same CPU usage
+		packageTimes := map[string]map[string]uint64{
+			"containerA": {"0": 100},
+			"containerB": {"1": 100},
+			"containerC": {"0": 50, "1": 50},
+		}
+		for containerID, packageTime := range packageTimes {
+			containersMetrics[containerID] = collector_metric.NewContainerMetrics(containerID, "pod", "test")
+			containersMetrics[containerID].CounterStats[config.CoreUsageMetric] = &collector_metric.UInt64Stat{}
+			err := containersMetrics[containerID].CounterStats[config.CoreUsageMetric].AddNewDelta(100)
+			Expect(err).NotTo(HaveOccurred())
+			for pkg, t := range packageTime {
+				containersMetrics[containerID].CPUTimePerPackage.AddDeltaStat(pkg, t)
+			}
+		}
+
+		nodeMetrics := collector_metric.NewNodeMetrics()
+		collector_metric.ContainerMetricNames = []string{config.CoreUsageMetric}
+		nodeMetrics.AddNodeResUsageFromContainerResUsage(containersMetrics)
+		// package 0 is busy and consumed 900mJ, package 1 consumed 100mJ
+		nodeMetrics.DynEnergyInPkg.SetDeltaStat("0", 900)
+		nodeMetrics.DynEnergyInPkg.SetDeltaStat("1", 100)
+		nodeMetrics.DynEnergyInCore.SetDeltaStat("0", 600)
+		nodeMetrics.DynEnergyInCore.SetDeltaStat("1", 300)
+
+		UpdateContainerEnergyByRatioPowerModel(containersMetrics, nodeMetrics)
+		// package 0 is split 100:50 between A and C, package 1 is split 100:50 between B and C
+		Expect(containersMetrics["containerA"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(600)))
+		Expect(containersMetrics["containerB"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(67)))
+		Expect(containersMetrics["containerC"].DynEnergyInPkg.Delta).Should(BeEquivalentTo(uint64(300 + 33)))
+		Expect(containersMetrics["containerA"].DynEnergyInCore.Delta).Should(BeEquivalentTo(uint64(400)))
+		Expect(containersMetrics["containerB"].DynEnergyInCore.Delta).Should(BeEquivalentTo(uint64(200)))
+		Expect(containersMetrics["containerC"].DynEnergyInCore.Delta).Should(BeEquivalentTo(uint64(200 + 100)))
+		// the energy of each package is fully attributed, without rounding excess
+		var pkgEnergy, coreEnergy uint64
+		for _, container := range containersMetrics {
+			pkgEnergy += container.DynEnergyInPkg.Delta
+			coreEnergy += container.DynEnergyInCore.Delta
+		}
+		Expect(pkgEnergy).To(Equal(uint64(900 + 100)))
+		Expect(coreEnergy).To(Equal(uint64(600 + 300)))
+	})
+
+	It("GetContainerEnergyByPackage without usage", func() {
+		containersMetrics := map[string]*collector_metric.ContainerMetrics{}
+		// containerC does not collect the usage metric, so the energy is only split between containerA and containerB
+		for _, containerID := range []string{"containerA", "containerB", "containerC"} {
+			containersMetrics[containerID] = collector_metric.NewContainerMetrics(containerID, "pod", "test")
+			containersMetrics[containerID].CPUTimePerPackage.AddDeltaStat("0", 100)
+			if containerID != "containerC" {
+				containersMetrics[containerID].CounterStats[config.CoreUsageMetric] = &collector_metric.UInt64Stat{}
+			}
+		}
+		packageEnergy := &collector_metric.UInt64StatCollection{Stat: map[string]*collector_metric.UInt64Stat{}}
+		packageEnergy.SetDeltaStat("0", 101)
+		packageEnergy.SetDeltaStat("1", 0)
+		containerEnergy := getContainerEnergyByPackage(containersMetrics, getContainerPackageShares(containersMetrics), packageEnergy, config.CoreUsageMetric)
+		Expect(containerEnergy).To(Equal(map[string]uint64{"containerA": 51, "containerB": 50}))
+	})
+
+	It("SplitEnergy", func() {
+		Expect(splitEnergy(100, []float64{1, 1, 1})).To(Equal([]uint64{33, 34, 33}))
+		Expect(splitEnergy(100, []float64{0, 0, 0})).To(Equal([]uint64{33, 34, 33}))
+		Expect(splitEnergy(10, []float64{3, 0, 1})).To(Equal([]uint64{8, 0, 2}))
+		Expect(splitEnergy(10, []float64{})).To(BeEmpty())
+	})
+
+	It("GetContainerPackageShares", func() {
+		containersMetrics := map[string]*collector_metric.ContainerMetrics{}
+		containersMetrics["containerA"] = collector_metric.NewContainerMetrics("containerA", "podA", "test")
+		Expect(getContainerPackageShares(containersMetrics)).To(BeNil())
+
+		containersMetrics["containerA"].CPUTimePerPackage.AddDeltaStat("0", 300)
+		containersMetrics["containerA"].CPUTimePerPackage.AddDeltaStat("1", 100)
+		// containerB did not report CPU time, so it is spread like the node CPU time
+		containersMetrics["containerB"] = collector_metric.NewContainerMetrics("containerB", "podB", "test")
+		shares := getContainerPackageShares(containersMetrics)
+		Expect(shares["containerA"]).To(Equal(map[string]float64{"0": 0.75, "1": 0.25}))
+		Expect(shares["containerB"]).To(Equal(map[string]float64{"0": 0.75, "1": 0.25}))
+
+		// a single package is split by the node usage
+		packageEnergy := &collector_metric.UInt64StatCollection{Stat: map[string]*collector_metric.UInt64Stat{}}
+		packageEnergy.SetDeltaStat("0", 400)
+		Expect(getContainerEnergyByPackage(containersMetrics, shares, packageEnergy, config.CoreUsageMetric)).To(BeNil())
+	})
+	Context("GetContainerIdleEnergy", func() {
+		var containersMetrics map[string]*collector_metric.ContainerMetrics
+		idlePowerAttribution := config.IdlePowerAttribution
+
+		BeforeEach(func() {
+			containersMetrics = map[string]*collector_metric.ContainerMetrics{}
+			// containerA requests 1 CPU and 1GiB and is limited to 2 CPUs, containerB requests 3 CPUs and 3GiB without limit,
+			// the system processes have no requests nor limits
+			resources := map[string][]uint64{
+				"containerA":       {1000, 1 << 30, 2000, 0},
+				"containerB":       {3000, 3 << 30, 0, 0},
+				"system_processes": {0, 0, 0, 0},
+			}
+			for containerID, resource := range resources {
+				containersMetrics[containerID] = collector_metric.NewContainerMetrics(containerID, "pod", "test")
+				containersMetrics[containerID].CPURequest = resource[0]
+				containersMetrics[containerID].MemoryRequest = resource[1]
+				containersMetrics[containerID].CPULimit = resource[2]
+				containersMetrics[containerID].MemoryLimit = resource[3]
+			}
+		})
+		AfterEach(func() {
+			config.IdlePowerAttribution = idlePowerAttribution
+		})
+
+		It("evenly divides the idle energy", func() {
+			config.IdlePowerAttribution = config.IdleAttributionEven
+			idleEnergy := getContainerIdleEnergy(containersMetrics, 300, cpuRequest, cpuLimit)
+			Expect(idleEnergy).To(Equal(map[string]uint64{"containerA": 100, "containerB": 100, "system_processes": 100}))
+		})
+
+		It("divides the idle energy by the requests", func() {
+			config.IdlePowerAttribution = config.IdleAttributionRequests
+			idleEnergy := getContainerIdleEnergy(containersMetrics, 400, cpuRequest, cpuLimit)
+			Expect(idleEnergy).To(Equal(map[string]uint64{"containerA": 100, "containerB": 300, "system_processes": 0}))
+			idleEnergy = getContainerIdleEnergy(containersMetrics, 400, memoryRequest, memoryLimit)
+			Expect(idleEnergy).To(Equal(map[string]uint64{"containerA": 100, "containerB": 300, "system_processes": 0}))
+		})
+
+		It("divides the idle energy by the limits", func() {
+			config.IdlePowerAttribution = config.IdleAttributionLimits
+			idleEnergy := getContainerIdleEnergy(containersMetrics, 400, cpuRequest, cpuLimit)
+			Expect(idleEnergy).To(Equal(map[string]uint64{"containerA": 400, "containerB": 0, "system_processes": 0}))
+			// no container has a memory limit, so the idle energy is evenly divided
+			idleEnergy = getContainerIdleEnergy(containersMetrics, 300, memoryRequest, memoryLimit)
+			Expect(idleEnergy).To(Equal(map[string]uint64{"containerA": 100, "containerB": 100, "system_processes": 100}))
+		})
+
+		It("keeps the idle energy in the node", func() {
+			config.IdlePowerAttribution = config.IdleAttributionNode
+			Expect(getContainerIdleEnergy(containersMetrics, 300, cpuRequest, cpuLimit)).To(BeEmpty())
+
+			nodeMetrics := collector_metric.NewNodeMetrics()
+			nodeMetrics.IdleEnergyInPkg.SetDeltaStat("0", 300)
+			UpdateContainerEnergyByRatioPowerModel(containersMetrics, nodeMetrics)
+			for _, container := range containersMetrics {
+				Expect(container.IdleEnergyInPkg.Delta).To(BeZero())
+			}
+		})
+
+		It("attributes the idle energy of the node by the requests", func() {
+			config.IdlePowerAttribution = config.IdleAttributionRequests
+			nodeMetrics := collector_metric.NewNodeMetrics()
+			nodeMetrics.IdleEnergyInPkg.SetDeltaStat("0", 400)
+			nodeMetrics.IdleEnergyInDRAM.SetDeltaStat("0", 800)
+			UpdateContainerEnergyByRatioPowerModel(containersMetrics, nodeMetrics)
+			Expect(containersMetrics["containerA"].IdleEnergyInPkg.Delta).To(BeEquivalentTo(100))
+			Expect(containersMetrics["containerB"].IdleEnergyInPkg.Delta).To(BeEquivalentTo(300))
+			Expect(containersMetrics["containerB"].IdleEnergyInDRAM.Delta).To(BeEquivalentTo(600))
+			Expect(containersMetrics["system_processes"].IdleEnergyInDRAM.Delta).To(BeZero())
+		})
+	})
 })
@@ -20,6 +20,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	collector_metric "github.com/sustainable-computing-io/kepler/pkg/collector/metric"
+	"github.com/sustainable-computing-io/kepler/pkg/config"
 	"github.com/sustainable-computing-io/kepler/pkg/power/components/source"
 )
 
@@ -37,6 +38,7 @@ var _ = Describe("Test Model Unit", func() {
 
 	BeforeEach(func() {
 		source.SystemCollectionSupported = false // disable the system power collection to use the prediction power model
+		config.SamplePeriodSec = 1               // the expected energy is the estimated power during one second
 		setCollectorMetrics()
 		containersMetrics = createMockContainersMetrics()
 		nodeMetrics = createMockNodeMetrics(containersMetrics)
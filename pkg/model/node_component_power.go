@@ -65,10 +65,10 @@ func GetNodeComponentPowers(nodeMetrics *collector_metric.NodeMetrics) (nodeComp
 		if err != nil {
 			return
 		}
-		pkgPower := getComponentPower(powers, "pkg", socketID)
-		corePower := getComponentPower(powers, "core", socketID)
-		uncorePower := getComponentPower(powers, "uncore", socketID)
-		dramPower := getComponentPower(powers, "dram", socketID)
+		pkgPower := getComponentPower(powers, "pkg", socketID, nodeMetrics.GetElapsedSec())
+		corePower := getComponentPower(powers, "core", socketID, nodeMetrics.GetElapsedSec())
+		uncorePower := getComponentPower(powers, "uncore", socketID, nodeMetrics.GetElapsedSec())
+		dramPower := getComponentPower(powers, "dram", socketID, nodeMetrics.GetElapsedSec())
 		nodeComponentsEnergy[socketID] = fillRAPLPower(pkgPower, corePower, uncorePower, dramPower)
 		return
 	}
@@ -58,7 +58,7 @@ func GetEstimatedNodePlatformPower(nodeMetrics *collector_metric.NodeMetrics) (p
 		if err != nil || len(powers) == 0 {
 			return
 		}
-		platformEnergy[estimatorACPISensorID] = powers[0]
+		platformEnergy[estimatorACPISensorID] = float64(powerToEnergy(powers[0], nodeMetrics.GetElapsedSec()))
 		return
 	}
 	return
@@ -76,17 +76,17 @@ func getProcessMetricsList(processsMetrics map[string]*collector_metric.ProcessM
 }
 
 // updateProcessEnergy returns Process energy consumption for each node component
-func UpdateProcessEnergy(processMetrics map[uint64]*collector_metric.ProcessMetrics, systemContainerMetrics *collector_metric.ContainerMetrics) {
+func UpdateProcessEnergy(processMetrics map[uint64]*collector_metric.ProcessMetrics, systemContainerMetrics *collector_metric.ContainerMetrics, elapsedSec float64) {
 	// If the node can expose power measurement per component, we can use the RATIO power model
 	// Otherwise, we estimate it from trained power model
 	if components.IsSystemCollectionSupported() {
 		local.UpdateProcessEnergyByRatioPowerModel(processMetrics, systemContainerMetrics)
 	} else {
-		updateProcessEnergyByTrainedPowerModel(processMetrics)
+		updateProcessEnergyByTrainedPowerModel(processMetrics, elapsedSec)
 	}
 }
 
-func updateProcessEnergyByTrainedPowerModel(processsMetrics map[uint64]*collector_metric.ProcessMetrics) {
+func updateProcessEnergyByTrainedPowerModel(processsMetrics map[uint64]*collector_metric.ProcessMetrics, elapsedSec float64) {
 	var enabled bool
 	// convert the Process metrics map to an array since the model server does not receive structured data
 	// TODO: send data to model server via protobuf instead of no structured data
@@ -94,7 +94,7 @@ func updateProcessEnergyByTrainedPowerModel(processsMetrics map[uint64]*collecto
 
 	totalPowerValid, totalProcessPowers := getProcessTotalPower(processMetricValuesOnly)
 
-	enabled, processComponentPowers := getProcessComponentPowers(processMetricValuesOnly)
+	enabled, processComponentPowers := getProcessComponentPowers(processMetricValuesOnly, elapsedSec)
 	if !enabled {
 		klog.V(5).Infoln("No ProcessComponentPower Model")
 		return
@@ -103,7 +103,7 @@ func updateProcessEnergyByTrainedPowerModel(processsMetrics map[uint64]*collecto
 	if totalPowerValid {
 		for index, componentPower := range processComponentPowers {
 			// TODO: include GPU into consideration
-			processOtherPowers[index] = uint64(totalProcessPowers[index]) - componentPower.Pkg - componentPower.DRAM
+			processOtherPowers[index] = powerToEnergy(totalProcessPowers[index], elapsedSec) - componentPower.Pkg - componentPower.DRAM
 		}
 	}
 
@@ -144,7 +144,7 @@ func getProcessTotalPower(processMetricValuesOnly [][]float64) (valid bool, resu
 }
 
 // getProcessComponentPowers returns estimated pods' RAPL power
-func getProcessComponentPowers(processMetricValuesOnly [][]float64) (bool, []source.NodeComponentsEnergy) {
+func getProcessComponentPowers(processMetricValuesOnly [][]float64, elapsedSec float64) (bool, []source.NodeComponentsEnergy) {
 	processNumber := len(processMetricValuesOnly)
 	if ProcessComponentPowerModelValid {
 		powers, err := ProcessComponentPowerModelFunc(processMetricValuesOnly, collector_metric.NodeMetadataValues)
@@ -153,10 +153,10 @@ func getProcessComponentPowers(processMetricValuesOnly [][]float64) (bool, []sou
 		}
 		raplPowers := make([]source.NodeComponentsEnergy, processNumber)
 		for index := 0; index < processNumber; index++ {
-			pkgPower := getComponentPower(powers, "pkg", index)
-			corePower := getComponentPower(powers, "core", index)
-			uncorePower := getComponentPower(powers, "uncore", index)
-			dramPower := getComponentPower(powers, "dram", index)
+			pkgPower := getComponentPower(powers, "pkg", index, elapsedSec)
+			corePower := getComponentPower(powers, "core", index, elapsedSec)
+			uncorePower := getComponentPower(powers, "uncore", index, elapsedSec)
+			dramPower := getComponentPower(powers, "dram", index, elapsedSec)
 			raplPowers[index] = fillRAPLPower(pkgPower, corePower, uncorePower, dramPower)
 		}
 		return true, raplPowers
@@ -26,15 +26,21 @@ const (
 )
 
 // getComponentPower called by getPodComponentPowers to check if component key is present in powers response and fills with single 0
-func getComponentPower(powers map[string][]float64, componentKey string, index int) uint64 {
+// The returned value is the energy in mJ consumed during elapsedSec
+func getComponentPower(powers map[string][]float64, componentKey string, index int, elapsedSec float64) uint64 {
 	values := powers[componentKey]
 	if index >= len(values) {
 		return 0
 	} else {
-		return uint64(values[index] * jouleToMiliJoule)
+		return powerToEnergy(values[index], elapsedSec)
 	}
 }
 
+// powerToEnergy converts the estimated power in watts to the energy in mJ consumed during the measured sample duration
+func powerToEnergy(power, elapsedSec float64) uint64 {
+	return uint64(power * jouleToMiliJoule * elapsedSec)
+}
+
 // fillRAPLPower fills missing component (pkg or core) power
 func fillRAPLPower(pkgPower, corePower, uncorePower, dramPower uint64) source.NodeComponentsEnergy {
 	if pkgPower < corePower+uncorePower {
@@ -28,6 +28,8 @@ import (
 	"time"
 
 	"k8s.io/klog/v2"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
 )
 
 const (
@@ -37,7 +39,6 @@ const (
 	acpiPowerPath       = "/sys/devices/LNXSYSTM:00"
 	acpiPowerFilePrefix = "power"
 	acpiPowerFileSuffix = "_average"
-	poolingInterval     = 3000 * time.Millisecond // in seconds
 	sensorIDPrefix      = "energy"
 )
 
@@ -54,6 +55,10 @@ type ACPI struct {
 	collectEnergy    bool
 	cpuCoreFrequency map[int32]uint64 /*cpuID:value*/
 	stopChannel      chan bool
+	// poolingInterval is the time between two sensor reads
+	poolingInterval time.Duration
+	// lastReadTime is the time of the last sensor read
+	lastReadTime time.Time
 
 	mu sync.Mutex
 }
@@ -63,6 +68,7 @@ func NewACPIPowerMeter() *ACPI {
 		systemEnergy:     map[string]float64{},
 		cpuCoreFrequency: map[int32]uint64{},
 		stopChannel:      make(chan bool),
+		poolingInterval:  config.GetSamplePeriod(),
 	}
 	if acpi.IsPowerSupported() {
 		acpi.collectEnergy = true
@@ -125,11 +131,18 @@ func (a *ACPI) Run(isEBPFEnabled bool) {
 
 				if a.collectEnergy {
 					if sensorPower, err := getPowerFromSensor(); err == nil {
+						now := time.Now()
 						a.mu.Lock()
+						// the sleep can take longer than the pooling interval, so we use the measured elapsed time
+						elapsedSec := a.poolingInterval.Seconds()
+						if !a.lastReadTime.IsZero() {
+							elapsedSec = now.Sub(a.lastReadTime).Seconds()
+						}
 						for sensorID, power := range sensorPower {
 							// energy (mJ) is equal to miliwatts*time(second)
-							a.systemEnergy[sensorID] += power * float64(poolingInterval/time.Second)
+							a.systemEnergy[sensorID] += power * elapsedSec
 						}
+						a.lastReadTime = now
 						a.mu.Unlock()
 					} else {
 						// There is a kernel bug that does not allow us to collect metrics in /sys/devices/LNXSYSTM:00/device:00/ACPI000D:00/power1_average
@@ -145,7 +158,7 @@ func (a *ACPI) Run(isEBPFEnabled bool) {
 					return
 				}
 
-				time.Sleep(poolingInterval)
+				time.Sleep(a.poolingInterval)
 			}
 		}
 	}()
@@ -0,0 +1,253 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+idle_power.go
+calibrate the idle power of the node components by regressing their power over the node utilization.
+*/
+
+package calibration
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// minFitSamples is the number of samples in the window needed to fit the idle power
+	minFitSamples = 10
+	// stateSaveInterval is the minimum time between two writes of the state file
+	stateSaveInterval = time.Minute
+)
+
+// Fit is the idle power of a node component fitted by the calibration or set in the override file
+type Fit struct {
+	// IdlePower is the power in mW at zero utilization, i.e. the intercept of the regression of the power over the utilization
+	IdlePower float64 `json:"idle_power_mw"`
+	// RSquared is the coefficient of determination of the regression, between 0 and 1
+	RSquared float64 `json:"r_squared"`
+	// Samples is the number of samples of the regression
+	Samples int `json:"samples"`
+	// Override is true if the idle power is set in the override file
+	Override bool `json:"-"`
+}
+
+type sample struct {
+	utilization float64
+	power       float64
+}
+
+// IdlePowerCalibrator fits the idle power of each node component, e.g. pkg, and id, e.g. the package 0, with a linear
+// regression of the power over the node utilization in a sliding window of samples. The fitted idle power is persisted
+// in the state file so that it is known after a restart, and the idle power set in the override file takes precedence.
+type IdlePowerCalibrator struct {
+	mu         sync.Mutex
+	windowSize int
+	statePath  string
+	lastSave   time.Time
+
+	samples   map[string]map[string][]sample
+	fits      map[string]map[string]Fit
+	overrides map[string]map[string]Fit
+}
+
+// NewIdlePowerCalibrator creates a calibrator, the state and override files are ignored if the path is empty
+func NewIdlePowerCalibrator(windowSize int, statePath, overridePath string) *IdlePowerCalibrator {
+	if windowSize < minFitSamples {
+		windowSize = minFitSamples
+	}
+	c := &IdlePowerCalibrator{
+		windowSize: windowSize,
+		statePath:  statePath,
+		lastSave:   time.Now(),
+		samples:    map[string]map[string][]sample{},
+		fits:       map[string]map[string]Fit{},
+		overrides:  map[string]map[string]Fit{},
+	}
+	if statePath != "" {
+		if err := readJSONFile(statePath, &c.fits); err != nil && !os.IsNotExist(err) {
+			klog.Infof("failed to read the idle power calibration state, the idle power is calibrated again: %v", err)
+			c.fits = map[string]map[string]Fit{}
+		}
+	}
+	if overridePath != "" {
+		if err := c.readOverrides(overridePath); err != nil {
+			klog.Infof("failed to read the idle power override file: %v", err)
+		}
+	}
+	return c
+}
+
+// readOverrides reads the idle power in watts of the node components, e.g. {"pkg": {"0": 20.5}, "platform": {"platform": 95}}
+func (c *IdlePowerCalibrator) readOverrides(path string) error {
+	overrides := map[string]map[string]float64{}
+	if err := readJSONFile(path, &overrides); err != nil {
+		return err
+	}
+	for component, ids := range overrides {
+		c.overrides[component] = map[string]Fit{}
+		for id, watts := range ids {
+			if watts < 0 {
+				return fmt.Errorf("invalid idle power %f of %s %s", watts, component, id)
+			}
+			c.overrides[component][id] = Fit{IdlePower: watts * 1000, RSquared: 1, Override: true}
+		}
+	}
+	return nil
+}
+
+// AddSample adds the power in mW of a node component at the given node utilization and fits its idle power again
+func (c *IdlePowerCalibrator) AddSample(component, id string, utilization, power float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exist := c.samples[component]; !exist {
+		c.samples[component] = map[string][]sample{}
+	}
+	samples := append(c.samples[component][id], sample{utilization: utilization, power: power})
+	if len(samples) > c.windowSize {
+		samples = samples[len(samples)-c.windowSize:]
+	}
+	c.samples[component][id] = samples
+	if fit, ok := fitIdlePower(samples); ok {
+		if _, exist := c.fits[component]; !exist {
+			c.fits[component] = map[string]Fit{}
+		}
+		c.fits[component][id] = fit
+	}
+	if c.statePath != "" && time.Since(c.lastSave) >= stateSaveInterval {
+		if err := c.save(); err != nil {
+			klog.V(3).Infof("failed to save the idle power calibration state: %v", err)
+		}
+	}
+}
+
+// GetIdlePower returns the idle power in mW of a node component, false if it is neither overridden nor fitted yet
+func (c *IdlePowerCalibrator) GetIdlePower(component, id string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fit, found := c.overrides[component][id]; found {
+		return fit.IdlePower, true
+	}
+	if fit, found := c.fits[component][id]; found {
+		return fit.IdlePower, true
+	}
+	return 0, false
+}
+
+// GetFits returns the idle power of the node components per component and id, the overrides replace the fitted values
+func (c *IdlePowerCalibrator) GetFits() map[string]map[string]Fit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fits := map[string]map[string]Fit{}
+	for _, source := range []map[string]map[string]Fit{c.fits, c.overrides} {
+		for component, ids := range source {
+			if _, exist := fits[component]; !exist {
+				fits[component] = map[string]Fit{}
+			}
+			for id, fit := range ids {
+				fits[component][id] = fit
+			}
+		}
+	}
+	return fits
+}
+
+// Save writes the fitted idle power to the state file
+func (c *IdlePowerCalibrator) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.statePath == "" {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *IdlePowerCalibrator) save() error {
+	c.lastSave = time.Now()
+	data, err := json.Marshal(c.fits)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.statePath), 0o755); err != nil {
+		return err
+	}
+	// the file is replaced atomically so that a crash does not leave a partial state
+	tmpPath := c.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, c.statePath)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid file %s: %v", path, err)
+	}
+	return nil
+}
+
+// fitIdlePower fits power = idle + slope * utilization with the least squares method, the fit is rejected if the utilization
+// did not vary in the window or if the power does not increase with the utilization. The idle power is at most the lowest
+// power of the window since the node cannot consume less than its idle power.
+func fitIdlePower(samples []sample) (Fit, bool) {
+	n := float64(len(samples))
+	if len(samples) < minFitSamples {
+		return Fit{}, false
+	}
+	var sumUtilization, sumPower float64
+	minPower := math.MaxFloat64
+	for _, s := range samples {
+		sumUtilization += s.utilization
+		sumPower += s.power
+		minPower = math.Min(minPower, s.power)
+	}
+	meanUtilization, meanPower := sumUtilization/n, sumPower/n
+	var covariance, utilizationVariance, powerVariance float64
+	for _, s := range samples {
+		du, dp := s.utilization-meanUtilization, s.power-meanPower
+		covariance += du * dp
+		utilizationVariance += du * du
+		powerVariance += dp * dp
+	}
+	if utilizationVariance == 0 {
+		return Fit{}, false
+	}
+	slope := covariance / utilizationVariance
+	if slope < 0 {
+		return Fit{}, false
+	}
+	intercept := meanPower - slope*meanUtilization
+	rSquared := 1.0
+	if powerVariance > 0 {
+		rSquared = covariance * covariance / (utilizationVariance * powerVariance)
+	}
+	return Fit{
+		IdlePower: math.Max(0, math.Min(intercept, minPower)),
+		RSquared:  rSquared,
+		Samples:   len(samples),
+	}, true
+}
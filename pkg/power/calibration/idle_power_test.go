@@ -0,0 +1,128 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package calibration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// addLinearSamples adds the samples of a component consuming idle + slope * utilization mW
+func addLinearSamples(c *IdlePowerCalibrator, component, id string, idle, slope float64, n int) {
+	for i := 0; i < n; i++ {
+		utilization := float64(i%10) * 100
+		c.AddSample(component, id, utilization, idle+slope*utilization)
+	}
+}
+
+func TestFitIdlePower(t *testing.T) {
+	g := NewWithT(t)
+	c := NewIdlePowerCalibrator(20, "", "")
+
+	addLinearSamples(c, "pkg", "0", 20000, 30, minFitSamples-1)
+	_, found := c.GetIdlePower("pkg", "0")
+	g.Expect(found).To(BeFalse())
+
+	addLinearSamples(c, "pkg", "0", 20000, 30, 1)
+	idlePower, found := c.GetIdlePower("pkg", "0")
+	g.Expect(found).To(BeTrue())
+	g.Expect(idlePower).To(BeNumerically("~", 20000, 1e-6))
+	fit := c.GetFits()["pkg"]["0"]
+	g.Expect(fit.RSquared).To(BeNumerically("~", 1, 1e-9))
+	g.Expect(fit.Samples).To(Equal(minFitSamples))
+
+	// the samples of the previous idle power leave the window
+	addLinearSamples(c, "pkg", "0", 15000, 30, 20)
+	idlePower, _ = c.GetIdlePower("pkg", "0")
+	g.Expect(idlePower).To(BeNumerically("~", 15000, 1e-6))
+	g.Expect(c.GetFits()["pkg"]["0"].Samples).To(Equal(20))
+}
+
+func TestFitIdlePowerRejected(t *testing.T) {
+	g := NewWithT(t)
+
+	constantUtilization := []sample{}
+	decreasingPower := []sample{}
+	for i := 0; i < minFitSamples; i++ {
+		constantUtilization = append(constantUtilization, sample{utilization: 100, power: float64(10000 + i)})
+		decreasingPower = append(decreasingPower, sample{utilization: float64(i), power: float64(10000 - i)})
+	}
+	_, ok := fitIdlePower(constantUtilization)
+	g.Expect(ok).To(BeFalse())
+	_, ok = fitIdlePower(decreasingPower)
+	g.Expect(ok).To(BeFalse())
+
+	// the idle power cannot be higher than the lowest power or negative
+	samples := []sample{}
+	for i := 0; i < minFitSamples; i++ {
+		samples = append(samples, sample{utilization: float64(i), power: float64(1000 + 10*i*i)})
+	}
+	fit, ok := fitIdlePower(samples)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(fit.IdlePower).To(BeNumerically("<=", 1000))
+	g.Expect(fit.IdlePower).To(BeNumerically(">=", 0))
+	g.Expect(fit.RSquared).To(BeNumerically("<", 1))
+}
+
+func TestIdlePowerOverride(t *testing.T) {
+	g := NewWithT(t)
+	overridePath := filepath.Join(t.TempDir(), "override.json")
+	g.Expect(os.WriteFile(overridePath, []byte(`{"pkg": {"0": 12.5}}`), 0o644)).To(Succeed())
+
+	c := NewIdlePowerCalibrator(20, "", overridePath)
+	idlePower, found := c.GetIdlePower("pkg", "0")
+	g.Expect(found).To(BeTrue())
+	g.Expect(idlePower).To(Equal(12500.0))
+
+	addLinearSamples(c, "pkg", "0", 20000, 30, 20)
+	addLinearSamples(c, "pkg", "1", 20000, 30, 20)
+	idlePower, _ = c.GetIdlePower("pkg", "0")
+	g.Expect(idlePower).To(Equal(12500.0))
+	idlePower, _ = c.GetIdlePower("pkg", "1")
+	g.Expect(idlePower).To(BeNumerically("~", 20000, 1e-6))
+	fits := c.GetFits()
+	g.Expect(fits["pkg"]["0"].Override).To(BeTrue())
+	g.Expect(fits["pkg"]["1"].Override).To(BeFalse())
+}
+
+func TestIdlePowerState(t *testing.T) {
+	g := NewWithT(t)
+	statePath := filepath.Join(t.TempDir(), "kepler", "idle_power.json")
+
+	c := NewIdlePowerCalibrator(20, statePath, "")
+	addLinearSamples(c, "dram", "0", 3000, 2, 20)
+	g.Expect(c.Save()).To(Succeed())
+
+	c = NewIdlePowerCalibrator(20, statePath, "")
+	idlePower, found := c.GetIdlePower("dram", "0")
+	g.Expect(found).To(BeTrue())
+	g.Expect(idlePower).To(BeNumerically("~", 3000, 1e-6))
+	g.Expect(c.GetFits()["dram"]["0"].Samples).To(Equal(20))
+
+	// a corrupt state is discarded and overwritten by the next save
+	g.Expect(os.WriteFile(statePath, []byte(`{"dram": {"0": `), 0o644)).To(Succeed())
+	c = NewIdlePowerCalibrator(20, statePath, "")
+	_, found = c.GetIdlePower("dram", "0")
+	g.Expect(found).To(BeFalse())
+	g.Expect(c.Save()).To(Succeed())
+	data, err := os.ReadFile(statePath)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("{}"))
+}
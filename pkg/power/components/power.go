@@ -23,49 +23,24 @@ import (
 	"github.com/sustainable-computing-io/kepler/pkg/power/components/source"
 )
 
-type powerInterface interface {
-	// GetEnergyFromDram returns mJ in DRAM
-	GetEnergyFromDram() (uint64, error)
-	// GetEnergyFromDram returns mJ in CPU cores
-	GetEnergyFromCore() (uint64, error)
-	// GetEnergyFromDram returns mJ in uncore (i.e. iGPU)
-	GetEnergyFromUncore() (uint64, error)
-	// GetEnergyFromDram returns mJ in package
-	GetEnergyFromPackage() (uint64, error)
-	// GetNodeComponentsEnergy returns set of mJ per RAPL components
-	GetNodeComponentsEnergy() map[int]source.NodeComponentsEnergy
-	// StopPower stops the collection
-	StopPower()
-	// IsSystemCollectionSupported returns if it is possible to use this collector
-	IsSystemCollectionSupported() bool
-}
-
 var (
-	estimateImpl                     = &source.PowerEstimate{}
-	sysfsImpl                        = &source.PowerSysfs{}
-	msrImpl                          = &source.PowerMSR{}
-	apmXgeneSysfsImpl                = &source.ApmXgeneSysfs{}
-	powerImpl         powerInterface = sysfsImpl
+	powerImpl       source.PowerSource = &source.PowerSysfs{}
+	powerSourceName                    = source.RAPLSysfsSourceName
 )
 
+// InitPowerImpl selects the node components power source.
+// The source set by POWER_SOURCE is used if it is supported, otherwise the supported registered source with the highest priority.
 func InitPowerImpl() {
-	if sysfsImpl.IsSystemCollectionSupported() /*&& false*/ {
-		klog.V(1).Infoln("use sysfs to obtain power")
-		powerImpl = sysfsImpl
-	} else {
-		if msrImpl.IsSystemCollectionSupported() && config.EnabledMSR {
-			klog.V(1).Infoln("use MSR to obtain power")
-			powerImpl = msrImpl
-		} else {
-			if apmXgeneSysfsImpl.IsSystemCollectionSupported() {
-				klog.V(1).Infoln("use Ampere Xgene sysfs to obtain power")
-				powerImpl = apmXgeneSysfsImpl
-			} else {
-				klog.V(1).Infoln("Not able to obtain power, use estimate method")
-				powerImpl = estimateImpl
-			}
-		}
+	powerSourceName, powerImpl = source.SelectPowerSource(config.PowerSource)
+	if powerSourceName == source.EstimateSourceName {
+		klog.V(1).Infoln("Not able to obtain power, use estimate method")
 	}
+	klog.Infof("use %s to obtain power (registered sources: %v)", powerSourceName, source.RegisteredSources())
+}
+
+// GetPowerSourceName returns the name of the selected node components power source
+func GetPowerSourceName() string {
+	return powerSourceName
 }
 
 func GetEnergyFromDram() (uint64, error) {
@@ -92,6 +67,22 @@ func IsSystemCollectionSupported() bool {
 	return powerImpl.IsSystemCollectionSupported()
 }
 
+// GetPowerLimits returns the RAPL power limits if the selected power source can read them
+func GetPowerLimits() []source.RAPLDomainPowerLimit {
+	if reader, ok := powerImpl.(source.PowerLimitReader); ok {
+		return reader.GetPowerLimits()
+	}
+	return nil
+}
+
+// GetPerCPUEnergy returns the accumulated core energy in mJ per CPU if the selected power source can read it
+func GetPerCPUEnergy() map[int]uint64 {
+	if reader, ok := powerImpl.(source.PerCPUEnergyReader); ok {
+		return reader.GetPerCPUEnergy()
+	}
+	return nil
+}
+
 func StopPower() {
 	powerImpl.StopPower()
 }
@@ -0,0 +1,178 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the amd_energy hwmon driver exposes energyN_input files in uJ labeled Ecore<cpu> and Esocket<package>,
+	// there is one Ecore channel per physical core, labeled with the first logical CPU of the core
+	amdEnergyDriverName        = "amd_energy"
+	amdEnergyLabelGlob         = "energy*_label"
+	amdEnergyCoreLabelPrefix   = "Ecore"
+	amdEnergySocketLabelPrefix = "Esocket"
+)
+
+var (
+	// hwmonRoot is a variable so that tests can point it to a fake device tree
+	hwmonRoot = "/sys/class/hwmon"
+	// amdEnergyCounters handles the energy counter wraparound of each amd_energy channel, the key is the input file
+	amdEnergyCounters = newEnergyCounters()
+)
+
+// PowerAMDEnergy reads the AMD RAPL energy counters from the amd_energy hwmon driver
+type PowerAMDEnergy struct {
+	// path is the hwmon directory of the amd_energy driver
+	path string
+}
+
+func init() {
+	Register(AMDEnergySourceName, 35, nil, &PowerAMDEnergy{})
+}
+
+func (r *PowerAMDEnergy) IsSystemCollectionSupported() bool {
+	hwmonDirs, err := filepath.Glob(filepath.Join(hwmonRoot, "hwmon*"))
+	if err != nil {
+		return false
+	}
+	for _, dir := range hwmonDirs {
+		data, err := os.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(data)) == amdEnergyDriverName {
+			r.path = dir
+			klog.V(1).Infof("Found amd_energy hwmon: %s", dir)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PowerAMDEnergy) GetEnergyFromDram() (uint64, error) {
+	return 0, nil
+}
+
+func (r *PowerAMDEnergy) GetEnergyFromCore() (uint64, error) {
+	energy := uint64(0)
+	for _, e := range r.GetNodeComponentsEnergy() {
+		energy += e.Core
+	}
+	return energy, nil
+}
+
+func (r *PowerAMDEnergy) GetEnergyFromUncore() (uint64, error) {
+	return 0, nil
+}
+
+func (r *PowerAMDEnergy) GetEnergyFromPackage() (uint64, error) {
+	energy := uint64(0)
+	for _, e := range r.GetNodeComponentsEnergy() {
+		energy += e.Pkg
+	}
+	return energy, nil
+}
+
+// readChannels returns the accumulated energy in uJ of each socket and each core
+func (r *PowerAMDEnergy) readChannels() (socketEnergies, cpuEnergies map[int]uint64) {
+	socketEnergies = map[int]uint64{}
+	cpuEnergies = map[int]uint64{}
+	labelFiles, err := filepath.Glob(filepath.Join(r.path, amdEnergyLabelGlob))
+	if err != nil {
+		klog.V(3).Infoln(err)
+	}
+	for _, labelFile := range labelFiles {
+		data, err := os.ReadFile(labelFile)
+		if err != nil {
+			klog.V(3).Infoln(err)
+			continue
+		}
+		label := strings.TrimSpace(string(data))
+		inputFile := strings.TrimSuffix(labelFile, "_label") + "_input"
+		e, err := readUint64File(inputFile)
+		if err != nil {
+			klog.V(3).Infoln(err)
+			continue
+		}
+		e = amdEnergyCounters.update(inputFile, e, func() uint64 { return 0 })
+		switch {
+		case strings.HasPrefix(label, amdEnergySocketLabelPrefix):
+			pkgID, err := strconv.Atoi(strings.TrimPrefix(label, amdEnergySocketLabelPrefix))
+			if err != nil {
+				continue
+			}
+			socketEnergies[pkgID] = e
+		case strings.HasPrefix(label, amdEnergyCoreLabelPrefix):
+			cpu, err := strconv.Atoi(strings.TrimPrefix(label, amdEnergyCoreLabelPrefix))
+			if err != nil {
+				continue
+			}
+			cpuEnergies[cpu] = e
+		}
+	}
+	return socketEnergies, cpuEnergies
+}
+
+// GetNodeComponentsEnergy returns the socket energy as the package energy and the sum of the energy of its cores as the core energy
+func (r *PowerAMDEnergy) GetNodeComponentsEnergy() map[int]NodeComponentsEnergy {
+	pkgEnergies, cpuEnergies := r.readChannels()
+	coreEnergies := map[int]uint64{} /*uJ*/
+	for cpu, e := range cpuEnergies {
+		pkgID, err := getCPUPackageID(cpu)
+		if err != nil {
+			klog.V(3).Infoln(err)
+			continue
+		}
+		coreEnergies[pkgID] += e
+	}
+
+	packageEnergies := make(map[int]NodeComponentsEnergy)
+	for pkgID, pkgEnergy := range pkgEnergies {
+		packageEnergies[pkgID] = NodeComponentsEnergy{
+			Core: coreEnergies[pkgID] / 1000, /*mJ*/
+			Pkg:  pkgEnergy / 1000,           /*mJ*/
+		}
+	}
+	return packageEnergies
+}
+
+// GetPerCPUEnergy returns the energy of each logical CPU, the Ecore channel of a physical core is evenly split among its SMT siblings
+func (r *PowerAMDEnergy) GetPerCPUEnergy() map[int]uint64 {
+	_, cpuEnergies := r.readChannels()
+	energy := map[int]uint64{}
+	for cpu, e := range cpuEnergies {
+		siblings, err := getCPUThreadSiblings(cpu)
+		if err != nil || len(siblings) == 0 {
+			klog.V(3).Infoln(err)
+			siblings = []int{cpu}
+		}
+		for _, sibling := range siblings {
+			energy[sibling] = e / 1000 /*mJ*/ / uint64(len(siblings))
+		}
+	}
+	return energy
+}
+
+func (r *PowerAMDEnergy) StopPower() {
+}
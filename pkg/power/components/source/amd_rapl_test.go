@@ -0,0 +1,214 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// energy status unit of 2^-16 J, as reported by AMD Zen CPUs
+	testAMDPowerUnit   = 0x000a1003
+	testAMDEnergyUnits = 1 << 16
+)
+
+// createFakeCPUs creates the cpuinfo and topology files of a node with the given vendor and number of logical CPUs,
+// the logical CPUs are split into two packages when there is more than one, and in SMT siblings pairs when possible
+func createFakeCPUs(root, vendor string, cpus int) (numPackages int) {
+	numPackages = 1
+	if cpus > 1 {
+		numPackages = 2
+	}
+	threadsPerCore := 1
+	if cpus%4 == 0 {
+		threadsPerCore = 2
+	}
+	cpuInfo := ""
+	for cpu := 0; cpu < cpus; cpu++ {
+		cpuInfo += fmt.Sprintf("processor\t: %d\nvendor_id\t: %s\n\n", cpu, vendor)
+		dir := filepath.Join(root, fmt.Sprintf("cpu%d", cpu))
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		pkgID := cpu * numPackages / cpus
+		Expect(os.WriteFile(filepath.Join(dir, "physical_package_id"), []byte(strconv.Itoa(pkgID)+"\n"), 0o644)).To(Succeed())
+		coreID := cpu / threadsPerCore
+		Expect(os.WriteFile(filepath.Join(dir, "core_id"), []byte(strconv.Itoa(coreID)+"\n"), 0o644)).To(Succeed())
+		siblings := fmt.Sprintf("%d-%d", coreID*threadsPerCore, (coreID+1)*threadsPerCore-1)
+		Expect(os.WriteFile(filepath.Join(dir, "thread_siblings_list"), []byte(siblings+"\n"), 0o644)).To(Succeed())
+	}
+	cpuInfoPath = filepath.Join(root, "cpuinfo")
+	Expect(os.WriteFile(cpuInfoPath, []byte(cpuInfo), 0o644)).To(Succeed())
+	topologyPath = filepath.Join(root, "cpu%d", "physical_package_id")
+	coreIDPath = filepath.Join(root, "cpu%d", "core_id")
+	siblingsPath = filepath.Join(root, "cpu%d", "thread_siblings_list")
+	return numPackages
+}
+
+// fakeMSRPread reads a fake msr device file, which stores each 64-bit register at the offset 8*address.
+// The real msr device uses the address as offset, but a regular file cannot hold overlapping registers.
+func fakeMSRPread(fd int, p []byte, msr int64) (int, error) {
+	return syscall.Pread(fd, p, msr*8)
+}
+
+// writeFakeMSR writes a 64-bit register in a fake msr device file
+func writeFakeMSR(path string, msr int64, value uint64) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	Expect(err).NotTo(HaveOccurred())
+	defer file.Close()
+	buf := make([]byte, 8)
+	byteOrder.PutUint64(buf, value)
+	_, err = file.WriteAt(buf, msr*8)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("Test AMD RAPL", func() {
+	var (
+		root                                     string
+		savedCPUInfoPath, savedTopologyPath      string
+		savedCoreIDPath, savedSiblingsPath       string
+		savedMSRPath, savedHwmonRoot             string
+		savedMSRPread                            func(int, []byte, int64) (int, error)
+		savedMSRCounters, savedAMDEnergyCounters *energyCounters
+	)
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		savedCPUInfoPath, savedTopologyPath, savedCoreIDPath, savedSiblingsPath = cpuInfoPath, topologyPath, coreIDPath, siblingsPath
+		savedMSRPath, savedHwmonRoot, savedMSRPread = msrPath, hwmonRoot, msrPread
+		savedMSRCounters, savedAMDEnergyCounters = msrEnergyCounters, amdEnergyCounters
+		msrEnergyCounters, amdEnergyCounters = newEnergyCounters(), newEnergyCounters()
+	})
+
+	AfterEach(func() {
+		cpuInfoPath, topologyPath, coreIDPath, siblingsPath = savedCPUInfoPath, savedTopologyPath, savedCoreIDPath, savedSiblingsPath
+		msrPath, hwmonRoot, msrPread = savedMSRPath, savedHwmonRoot, savedMSRPread
+		msrEnergyCounters, amdEnergyCounters = savedMSRCounters, savedAMDEnergyCounters
+	})
+
+	It("detects the cpu vendor", func() {
+		createFakeCPUs(root, cpuVendorAMD, 2)
+		Expect(getCPUVendor()).To(Equal(cpuVendorAMD))
+		Expect(isAMDCPU()).To(BeTrue())
+
+		createFakeCPUs(root, cpuVendorIntel, 2)
+		Expect(getCPUVendor()).To(Equal(cpuVendorIntel))
+		Expect(isAMDCPU()).To(BeFalse())
+
+		cpuInfoPath = filepath.Join(root, "missing")
+		Expect(getCPUVendor()).To(BeEmpty())
+	})
+
+	It("reads the AMD energy MSRs", func() {
+		// the msr source reads the topology of all the CPUs of the host
+		numPackages := createFakeCPUs(root, cpuVendorAMD, runtime.NumCPU())
+		msrPath = filepath.Join(root, "cpu%d", "msr")
+		msrPread = fakeMSRPread
+		for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+			path := fmt.Sprintf(msrPath, cpu)
+			writeFakeMSR(path, msrAMDRaplPowerUnit, testAMDPowerUnit)
+			// 5J per package, 1J per physical core
+			writeFakeMSR(path, msrAMDPackageEnergyStatus, 5*testAMDEnergyUnits)
+			writeFakeMSR(path, msrAMDCoreEnergyStatus, testAMDEnergyUnits)
+		}
+		Expect(InitUnits()).To(Succeed())
+		defer CloseAllMSR()
+		Expect(msrs).To(Equal(amdRAPLMSRs))
+
+		// probing again replaces the msr files instead of leaking them
+		openFiles := func() int {
+			entries, err := os.ReadDir("/proc/self/fd")
+			Expect(err).NotTo(HaveOccurred())
+			return len(entries)
+		}
+		opened := openFiles()
+		Expect(InitUnits()).To(Succeed())
+		Expect(openFiles()).To(Equal(opened))
+
+		energy := (&PowerMSR{}).GetNodeComponentsEnergy()
+		Expect(energy).To(HaveLen(numPackages))
+		totalCore := uint64(0)
+		for pkgID := 0; pkgID < numPackages; pkgID++ {
+			Expect(energy[pkgID].Pkg).To(Equal(uint64(5000)))
+			Expect(energy[pkgID].Core).To(Equal(uint64(len(packageCores[pkgID]) * 1000)))
+			Expect(energy[pkgID].DRAM).To(BeZero())
+			totalCore += energy[pkgID].Core
+		}
+
+		// the energy of a physical core is split among its SMT siblings
+		perCPUEnergy := (&PowerMSR{}).GetPerCPUEnergy()
+		Expect(perCPUEnergy).To(HaveLen(runtime.NumCPU()))
+		totalCPU := uint64(0)
+		for core, siblings := range coreSiblings {
+			for _, cpu := range siblings {
+				Expect(perCPUEnergy[cpu]).To(Equal(uint64(1000/len(siblings))), "core %d", core)
+				totalCPU += perCPUEnergy[cpu]
+			}
+		}
+		Expect(totalCPU).To(Equal(totalCore))
+
+		// the counter of a single core wraps around
+		writeFakeMSR(fmt.Sprintf(msrPath, 0), msrAMDCoreEnergyStatus, testAMDEnergyUnits/2)
+		coreEnergy, err := ReadCorePower(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(coreEnergy).To(BeNumerically(">", energy[0].Core))
+	})
+
+	It("probes the MSRs once", func() {
+		createFakeCPUs(root, cpuVendorAMD, runtime.NumCPU())
+		msrPath = filepath.Join(root, "cpu%d", "msr")
+		msrPread = fakeMSRPread
+		for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+			writeFakeMSR(fmt.Sprintf(msrPath, cpu), msrAMDRaplPowerUnit, testAMDPowerUnit)
+		}
+		msrImpl := &PowerMSR{}
+		defer CloseAllMSR()
+		Expect(msrImpl.IsSystemCollectionSupported()).To(BeTrue())
+		// the msr files are not opened again
+		msrPath = filepath.Join(root, "missing%d")
+		Expect(msrImpl.IsSystemCollectionSupported()).To(BeTrue())
+	})
+
+	It("reads the amd_energy hwmon", func() {
+		// 8 CPUs with SMT, the cores 0 and 1 of package 0 are the CPUs 0-1 and 2-3
+		createFakeCPUs(root, cpuVendorAMD, 8)
+		hwmonRoot = filepath.Join(root, "hwmon")
+		other := filepath.Join(hwmonRoot, "hwmon0")
+		dir := filepath.Join(hwmonRoot, "hwmon1")
+		Expect(os.MkdirAll(other, 0o755)).To(Succeed())
+		Expect(os.MkdirAll(dir, 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(other, "name"), []byte("k10temp\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "name"), []byte(amdEnergyDriverName+"\n"), 0o644)).To(Succeed())
+		writeChannel := func(channel int, label string, energy uint64) {
+			prefix := filepath.Join(dir, fmt.Sprintf("energy%d_", channel))
+			Expect(os.WriteFile(prefix+"label", []byte(label+"\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(prefix+"input", []byte(strconv.FormatUint(energy, 10)+"\n"), 0o644)).To(Succeed())
+		}
+		// two cores and a socket in package 0
+		writeChannel(1, "Ecore000", 2000000)
+		writeChannel(2, "Ecore002", 3000000)
+		writeChannel(3, "Esocket0", 9000000)
+
+		r := &PowerAMDEnergy{}
+		Expect(r.IsSystemCollectionSupported()).To(BeTrue())
+		energy := r.GetNodeComponentsEnergy()
+		Expect(energy).To(HaveLen(1))
+		Expect(energy[0]).To(Equal(NodeComponentsEnergy{Core: 5000, Pkg: 9000}))
+		// the energy of each core is split among its SMT siblings
+		Expect(r.GetPerCPUEnergy()).To(Equal(map[int]uint64{0: 1000, 1: 1000, 2: 1500, 3: 1500}))
+
+		writeChannel(3, "Esocket0", 12000000)
+		pkgEnergy, err := r.GetEnergyFromPackage()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkgEnergy).To(Equal(uint64(12000)))
+	})
+
+	It("does not support nodes without amd_energy", func() {
+		hwmonRoot = filepath.Join(root, "hwmon")
+		Expect((&PowerAMDEnergy{}).IsSystemCollectionSupported()).To(BeFalse())
+	})
+})
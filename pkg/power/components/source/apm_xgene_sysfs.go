@@ -41,6 +41,10 @@ type ApmXgeneSysfs struct {
 	currTime time.Time
 }
 
+func init() {
+	Register(ApmXgeneSourceName, 20, nil, &ApmXgeneSysfs{})
+}
+
 func (r *ApmXgeneSysfs) IsSystemCollectionSupported() bool {
 	labelFiles, err := filepath.Glob(powerLabelPathTemplate)
 	if err != nil {
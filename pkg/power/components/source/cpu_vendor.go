@@ -0,0 +1,111 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// vendor_id reported in /proc/cpuinfo
+	cpuVendorIntel = "GenuineIntel"
+	cpuVendorAMD   = "AuthenticAMD"
+	cpuVendorHygon = "HygonGenuine"
+)
+
+var (
+	// the paths are variables so that tests can point them to a fake device tree
+	cpuInfoPath  = "/proc/cpuinfo"
+	topologyPath = "/sys/devices/system/cpu/cpu%d/topology/physical_package_id"
+	coreIDPath   = "/sys/devices/system/cpu/cpu%d/topology/core_id"
+	siblingsPath = "/sys/devices/system/cpu/cpu%d/topology/thread_siblings_list"
+)
+
+// getCPUVendor returns the vendor_id of the first processor in /proc/cpuinfo, or an empty string if unknown
+func getCPUVendor() string {
+	file, err := os.Open(cpuInfoPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if found && strings.TrimSpace(key) == "vendor_id" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// isAMDCPU returns true if the CPU implements the AMD RAPL interface (AMD Zen and Hygon Dhyana)
+func isAMDCPU() bool {
+	vendor := getCPUVendor()
+	return vendor == cpuVendorAMD || vendor == cpuVendorHygon
+}
+
+// getCPUPackageID returns the physical package (socket) of a logical CPU
+func getCPUPackageID(cpu int) (int, error) {
+	path := fmt.Sprintf(topologyPath, cpu)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read topology %s: %v", path, err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// getCPUCoreID returns the physical core of a logical CPU, the SMT siblings of a core have the same core id
+func getCPUCoreID(cpu int) (int, error) {
+	path := fmt.Sprintf(coreIDPath, cpu)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read topology %s: %v", path, err)
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// getCPUThreadSiblings returns the logical CPUs of the physical core of a logical CPU, including the CPU itself
+func getCPUThreadSiblings(cpu int) ([]int, error) {
+	path := fmt.Sprintf(siblingsPath, cpu)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology %s: %v", path, err)
+	}
+	// the list is a comma-separated list of CPUs and CPU ranges, e.g. 0,64 or 0-1
+	var siblings []int
+	for _, cpuRange := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		bounds := strings.SplitN(cpuRange, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list in %s: %v", path, err)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid cpu list in %s: %v", path, err)
+			}
+		}
+		for sibling := first; sibling <= last; sibling++ {
+			siblings = append(siblings, sibling)
+		}
+	}
+	return siblings, nil
+}
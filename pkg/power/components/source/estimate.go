@@ -28,6 +28,11 @@ import (
 
 type PowerEstimate struct{}
 
+func init() {
+	// the estimate source is the last resort and is always available
+	Register(EstimateSourceName, 0, func() bool { return true }, &PowerEstimate{})
+}
+
 var (
 	dramRegex = "^MemTotal:[\\s]+([0-9]+)"
 
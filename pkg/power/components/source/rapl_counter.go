@@ -0,0 +1,83 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// energyCounter reconstructs a monotonic energy value from a RAPL counter that wraps around.
+// A RAPL counter wraps around after maxRange, which can happen in about a minute on a busy socket.
+// If the counter wraps around more than once between two reads the lost energy cannot be recovered.
+type energyCounter struct {
+	// maxRange is the modulus of the counter, i.e. the counter restarts from 0 when it reaches maxRange, 0 if unknown
+	maxRange uint64
+	// last is the last raw value read from the counter
+	last uint64
+	// total is the accumulated value without wraparound
+	total       uint64
+	initialized bool
+}
+
+// update returns the accumulated value of the counter after reading the raw value
+func (c *energyCounter) update(raw uint64) uint64 {
+	if !c.initialized {
+		c.last = raw
+		c.total = raw
+		c.initialized = true
+		return c.total
+	}
+	switch {
+	case raw >= c.last:
+		c.total += raw - c.last
+	case c.maxRange > 0 && c.last < c.maxRange:
+		// the counter has wrapped around
+		c.total += c.maxRange - c.last + raw
+	default:
+		// without the counter range we cannot know how much energy was consumed, so we skip the interval
+		klog.V(3).Infof("energy counter decreased from %d to %d with unknown range\n", c.last, raw)
+	}
+	c.last = raw
+	return c.total
+}
+
+// energyCounters holds the energy counters of all RAPL domains of a source
+type energyCounters struct {
+	counters map[string]*energyCounter
+	mu       sync.Mutex
+}
+
+func newEnergyCounters() *energyCounters {
+	return &energyCounters{
+		counters: map[string]*energyCounter{},
+	}
+}
+
+// update returns the accumulated value of the counter identified by key.
+// getMaxRange is only called when the counter is read for the first time.
+func (e *energyCounters) update(key string, raw uint64, getMaxRange func() uint64) uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counter, exist := e.counters[key]
+	if !exist {
+		counter = &energyCounter{maxRange: getMaxRange()}
+		e.counters[key] = counter
+	}
+	return counter.update(raw)
+}
@@ -31,3 +31,10 @@ type NodeComponentsEnergy struct {
 func (p NodeComponentsEnergy) String() string {
 	return fmt.Sprintf("Pkg: %d (Core: %d, Uncore: %d) DRAM: %d", p.Pkg, p.Core, p.Uncore, p.DRAM)
 }
+
+// PerCPUEnergyReader is implemented by the power sources that can read the core energy of each CPU, e.g., AMD Zen
+type PerCPUEnergyReader interface {
+	// GetPerCPUEnergy returns the accumulated core energy in mJ per logical CPU.
+	// The energy of a counter shared by SMT siblings is evenly split among them.
+	GetPerCPUEnergy() map[int]uint64
+}
@@ -16,10 +16,31 @@ limitations under the License.
 
 package source
 
-type PowerMSR struct{}
+import (
+	"sync"
+
+	"github.com/sustainable-computing-io/kepler/pkg/config"
+)
+
+type PowerMSR struct {
+	// the MSRs are probed once since the probe opens the msr file of each package and core
+	probeOnce sync.Once
+	supported bool
+}
+
+func init() {
+	msrImpl := &PowerMSR{}
+	// reading MSR requires privileges, so it is only probed when explicitly enabled
+	Register(RAPLMSRSourceName, 30, func() bool {
+		return config.EnabledMSR && msrImpl.IsSystemCollectionSupported()
+	}, msrImpl)
+}
 
 func (r *PowerMSR) IsSystemCollectionSupported() bool {
-	return InitUnits() == nil
+	r.probeOnce.Do(func() {
+		r.supported = InitUnits() == nil
+	})
+	return r.supported
 }
 
 func (r *PowerMSR) GetEnergyFromDram() (uint64, error) {
@@ -42,6 +63,10 @@ func (r *PowerMSR) GetNodeComponentsEnergy() map[int]NodeComponentsEnergy {
 	return GetRAPLEnergyByMSR(ReadCorePower, ReadDramPower, ReadUncorePower, ReadPkgPower)
 }
 
+func (r *PowerMSR) GetPerCPUEnergy() map[int]uint64 {
+	return ReadPerCPUCorePower()
+}
+
 func (r *PowerMSR) StopPower() {
 	CloseAllMSR()
 }
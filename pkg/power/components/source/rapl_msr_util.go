@@ -25,10 +25,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
-	"os"
 	"runtime"
-	"strconv"
-	"strings"
+	"sort"
 	"syscall"
 
 	"github.com/sustainable-computing-io/kepler/pkg/utils"
@@ -36,26 +34,76 @@ import (
 )
 
 const (
-	msrPath      = "/dev/cpu/%d/msr"
-	topologyPath = "/sys/devices/system/cpu/cpu%d/topology/physical_package_id"
-
 	msrRaplPowerUnit    = 0x00000606
 	msrPkgEnergyStatus  = 0x00000611
 	msrDramEnergyStatus = 0x00000619
 	msrPP0EnergyStatus  = 0x00000639
 	msrPP1EnergyStatus  = 0x00000641
+
+	// AMD family 17h+ RAPL MSRs, the core energy status is reported per core and there is no DRAM or PP1 domain
+	msrAMDRaplPowerUnit       = 0xC0010299
+	msrAMDCoreEnergyStatus    = 0xC001029A
+	msrAMDPackageEnergyStatus = 0xC001029B
+
+	// the energy status MSRs are 32-bit counters (bits 31:0) wrapping around to 0 after 0xffffffff, the higher bits are reserved
+	msrEnergyStatusMask     = 0xffffffff
+	msrEnergyStatusMaxRange = msrEnergyStatusMask + 1
 )
 
+// raplMSRs are the vendor specific RAPL MSR addresses, an address of 0 means the domain is not supported
+type raplMSRs struct {
+	powerUnit  int64
+	pkgEnergy  int64
+	pp0Energy  int64
+	pp1Energy  int64
+	dramEnergy int64
+	// perCorePP0 is true if the pp0 energy status is reported by each core instead of each package
+	perCorePP0 bool
+}
+
 var (
+	intelRAPLMSRs = raplMSRs{
+		powerUnit:  msrRaplPowerUnit,
+		pkgEnergy:  msrPkgEnergyStatus,
+		pp0Energy:  msrPP0EnergyStatus,
+		pp1Energy:  msrPP1EnergyStatus,
+		dramEnergy: msrDramEnergyStatus,
+	}
+	amdRAPLMSRs = raplMSRs{
+		powerUnit:  msrAMDRaplPowerUnit,
+		pkgEnergy:  msrAMDPackageEnergyStatus,
+		pp0Energy:  msrAMDCoreEnergyStatus,
+		perCorePP0: true,
+	}
+)
+
+var (
+	// msrPath and msrPread are variables so that tests can use a fake device
+	msrPath = "/dev/cpu/%d/msr"
+	// msrPread reads a register of an msr file, the offset is the register address
+	msrPread = syscall.Pread
+
 	fds       []int
 	byteOrder binary.ByteOrder
 
+	// msrs are the RAPL MSRs of the CPU vendor
+	msrs = intelRAPLMSRs
+
 	// package - core
 	packageMap  map[int]int
 	packageList []int
+	// package - physical cores, each core is identified by its first logical CPU
+	packageCores map[int][]int
+	// core - logical CPUs (SMT siblings) of the core
+	coreSiblings map[int][]int
+	// the msr file of each core when the energy is reported per core
+	coreFds map[int]int
 
 	powerUnits, timeUnits           float64
 	cpuEnergyUnits, dramEnergyUnits []float64
+
+	// msrEnergyCounters handles the energy status wraparound of each package and MSR
+	msrEnergyCounters = newEnergyCounters()
 )
 
 func init() {
@@ -65,39 +113,77 @@ func init() {
 func mapPackageAndCore() error {
 	cores := runtime.NumCPU()
 	packageMap = make(map[int]int, cores)
-	packageList = make([]int, cores)
+	packageList = []int{}
+	packageCores = map[int][]int{}
+	coreSiblings = map[int][]int{}
+	// first logical CPU of each package and physical core
+	firstCPUs := map[[2]int]int{}
 
 	for i := 0; i < cores; {
-		path := fmt.Sprintf(topologyPath, i)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read topology %s: %v", path, err)
-		}
-		id, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		id, err := getCPUPackageID(i)
 		if err != nil {
 			return err
 		}
 
-		packageMap[id] = i
-		packageList = append(packageList, id)
+		// the msr of the first core of each package is used to read the package counters
+		if _, exist := packageMap[id]; !exist {
+			packageMap[id] = i
+			packageList = append(packageList, id)
+		}
+		// the SMT siblings share the core counters, if the core id is unknown every CPU is its own core
+		coreID, err := getCPUCoreID(i)
+		if err != nil {
+			coreID = -1 - i
+		}
+		first, exist := firstCPUs[[2]int{id, coreID}]
+		if !exist {
+			first = i
+			firstCPUs[[2]int{id, coreID}] = i
+			packageCores[id] = append(packageCores[id], i)
+		}
+		coreSiblings[first] = append(coreSiblings[first], i)
 
 		i++
 	}
+	sort.Ints(packageList)
 	return nil
 }
 
+func openMSR(core int) (int, error) {
+	path := fmt.Sprintf(msrPath, core)
+	fd, err := syscall.Open(path, syscall.O_RDONLY, 777)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open path %s: %v", path, err)
+	}
+	return fd, nil
+}
+
+// OpenAllMSR opens the msr file of each package, and of each core if the energy is reported per core.
+// The files opened by a previous call are closed first.
 func OpenAllMSR() error {
+	CloseAllMSR()
 	fds = make([]int, len(packageList))
-	var i int = 0
-	for packid := range packageList {
-		core := packageMap[packid]
-		path := fmt.Sprintf(msrPath, core)
-		fd, err := syscall.Open(path, syscall.O_RDONLY, 777)
+	for i, packid := range packageList {
+		fd, err := openMSR(packageMap[packid])
 		if err != nil {
-			return fmt.Errorf("failed to open path %s: %v", path, err)
+			CloseAllMSR()
+			return err
 		}
 		fds[i] = fd
-		i++
+	}
+	coreFds = map[int]int{}
+	if !msrs.perCorePP0 {
+		return nil
+	}
+	for _, packid := range packageList {
+		for _, core := range packageCores[packid] {
+			fd, err := openMSR(core)
+			if err != nil {
+				CloseAllMSR()
+				return err
+			}
+			coreFds[core] = fd
+		}
 	}
 	return nil
 }
@@ -108,18 +194,37 @@ func CloseAllMSR() {
 			syscall.Close(v)
 		}
 	}
+	for _, v := range coreFds {
+		if v != 0 {
+			syscall.Close(v)
+		}
+	}
+	fds, coreFds = nil, nil
 }
 
 func ReadMSR(packageID int, msr int64) (uint64, error) {
-	if packageID > len(packageList) {
+	if packageID >= len(packageList) {
 		return 0, fmt.Errorf("package Id %d greater than max package id %d", packageID, len(packageList))
 	}
-	buf := make([]byte, 8)
 	core := packageMap[packageID]
 	if core == -1 || fds[packageID] == 0 {
 		return 0, fmt.Errorf("no cpu core or msr found in package %d", packageID)
 	}
-	bytes, err := syscall.Pread(fds[packageID], buf, msr)
+	return readMSRFile(fds[packageID], msr)
+}
+
+// readCoreMSR reads the msr of a given core, the core msr files are only opened when the energy is reported per core
+func readCoreMSR(core int, msr int64) (uint64, error) {
+	fd, exist := coreFds[core]
+	if !exist || fd == 0 {
+		return 0, fmt.Errorf("no msr found for core %d", core)
+	}
+	return readMSRFile(fd, msr)
+}
+
+func readMSRFile(fd int, msr int64) (uint64, error) {
+	buf := make([]byte, 8)
+	bytes, err := msrPread(fd, buf, msr)
 
 	if err != nil {
 		return 0, err
@@ -135,6 +240,10 @@ func ReadMSR(packageID int, msr int64) (uint64, error) {
 }
 
 func InitUnits() error {
+	msrs = intelRAPLMSRs
+	if isAMDCPU() {
+		msrs = amdRAPLMSRs
+	}
 	if err := mapPackageAndCore(); err != nil {
 		klog.V(1).Info(err)
 		return err
@@ -146,7 +255,7 @@ func InitUnits() error {
 	cpuEnergyUnits = make([]float64, len(packageList))
 	dramEnergyUnits = make([]float64, len(packageList))
 	for i := 0; i < len(packageList); {
-		result, err := ReadMSR(i, msrRaplPowerUnit)
+		result, err := ReadMSR(i, msrs.powerUnit)
 		if err != nil {
 			klog.V(1).Info(err)
 			return fmt.Errorf("failed to read power unit: %v", err)
@@ -160,24 +269,92 @@ func InitUnits() error {
 	return nil
 }
 
+// readEnergyStatus returns the accumulated energy status counter of a package without wraparound
+func readEnergyStatus(packageID int, msr int64) (uint64, error) {
+	if msr == 0 {
+		return 0, fmt.Errorf("the domain is not supported by the cpu")
+	}
+	result, err := ReadMSR(packageID, msr)
+	if err != nil {
+		return 0, err
+	}
+	key := fmt.Sprintf("%d-%#x", packageID, msr)
+	return msrEnergyCounters.update(key, result&msrEnergyStatusMask, func() uint64 { return msrEnergyStatusMaxRange }), nil
+}
+
+// readCoreEnergyStatus returns the accumulated energy status counter of a physical core without wraparound
+func readCoreEnergyStatus(core int, msr int64) (uint64, error) {
+	result, err := readCoreMSR(core, msr)
+	if err != nil {
+		return 0, err
+	}
+	key := fmt.Sprintf("core%d-%#x", core, msr)
+	return msrEnergyCounters.update(key, result&msrEnergyStatusMask, func() uint64 { return msrEnergyStatusMaxRange }), nil
+}
+
+// readCoresEnergyStatus returns the sum of the accumulated energy status counters of all cores of a package
+func readCoresEnergyStatus(packageID int, msr int64) (uint64, error) {
+	if packageID >= len(packageList) {
+		return 0, fmt.Errorf("package Id %d greater than max package id %d", packageID, len(packageList))
+	}
+	total := uint64(0)
+	for _, core := range packageCores[packageList[packageID]] {
+		result, err := readCoreEnergyStatus(core, msr)
+		if err != nil {
+			return 0, err
+		}
+		total += result
+	}
+	return total, nil
+}
+
 func ReadPkgPower(packageID int) (uint64, error) {
-	result, err := ReadMSR(packageID, msrPkgEnergyStatus)
+	result, err := readEnergyStatus(packageID, msrs.pkgEnergy)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read pkg energy: %v", err)
 	}
-	return uint64(cpuEnergyUnits[packageID] * float64(result)), nil
+	return uint64(cpuEnergyUnits[packageID] * float64(result) * 1000 /*mJ*/), nil
 }
 
 func ReadCorePower(packageID int) (uint64, error) {
-	result, err := ReadMSR(packageID, msrPP0EnergyStatus)
+	var result uint64
+	var err error
+	if msrs.perCorePP0 {
+		result, err = readCoresEnergyStatus(packageID, msrs.pp0Energy)
+	} else {
+		result, err = readEnergyStatus(packageID, msrs.pp0Energy)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to read pp0 energy: %v", err)
 	}
 	return uint64(cpuEnergyUnits[packageID] * float64(result) * 1000 /*mJ*/), nil
 }
 
+// ReadPerCPUCorePower returns the core energy in mJ of each logical CPU when the cpu reports the energy per core
+func ReadPerCPUCorePower() map[int]uint64 {
+	energy := map[int]uint64{}
+	if !msrs.perCorePP0 {
+		return energy
+	}
+	for i, packid := range packageList {
+		for _, core := range packageCores[packid] {
+			result, err := readCoreEnergyStatus(core, msrs.pp0Energy)
+			if err != nil {
+				klog.V(3).Infof("failed to read the energy of core %d: %v", core, err)
+				continue
+			}
+			siblings := coreSiblings[core]
+			coreEnergy := cpuEnergyUnits[i] * float64(result) * 1000 /*mJ*/
+			for _, cpu := range siblings {
+				energy[cpu] = uint64(coreEnergy / float64(len(siblings)))
+			}
+		}
+	}
+	return energy
+}
+
 func ReadUncorePower(packageID int) (uint64, error) {
-	result, err := ReadMSR(packageID, msrPP1EnergyStatus)
+	result, err := readEnergyStatus(packageID, msrs.pp1Energy)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read pp1 energy: %v", err)
 	}
@@ -185,7 +362,7 @@ func ReadUncorePower(packageID int) (uint64, error) {
 }
 
 func ReadDramPower(packageID int) (uint64, error) {
-	result, err := ReadMSR(packageID, msrDramEnergyStatus)
+	result, err := readEnergyStatus(packageID, msrs.dramEnergy)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read dram energy: %v", err)
 	}
@@ -0,0 +1,122 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// sysfs files of the powercap constraints
+	enabledFile                  = "enabled"
+	constraintNameFileTemplate   = "constraint_%d_name"
+	constraintPowerLimitTemplate = "constraint_%d_power_limit_uw"
+	constraintTimeWindowTemplate = "constraint_%d_time_window_us"
+	maxRAPLConstraintsPerDomain  = 3
+	defaultRAPLConstraintName    = "constraint_%d"
+)
+
+// RAPLConstraint is a power limit enforced by RAPL on a domain
+type RAPLConstraint struct {
+	// Name is the constraint name, e.g. long_term or short_term
+	Name string
+	// PowerLimit is the power limit in uW
+	PowerLimit uint64
+	// TimeWindow is the time window in us over which the power limit is enforced
+	TimeWindow uint64
+}
+
+// RAPLDomainPowerLimit holds the power capping configuration of a RAPL domain
+type RAPLDomainPowerLimit struct {
+	PackageID int
+	// Domain is the RAPL event of the domain, i.e. package, core, uncore or dram
+	Domain      string
+	Enabled     bool
+	Constraints []RAPLConstraint
+}
+
+// PowerLimitReader is implemented by the power sources that can read the RAPL power limits
+type PowerLimitReader interface {
+	// GetPowerLimits returns the power capping configuration of all RAPL domains
+	GetPowerLimits() []RAPLDomainPowerLimit
+}
+
+// GetPowerLimits returns the power capping configuration of the RAPL domains exposed by powercap
+func (r *PowerSysfs) GetPowerLimits() []RAPLDomainPowerLimit {
+	limits := []RAPLDomainPowerLimit{}
+	for pkgName, subTree := range eventPaths {
+		splits := strings.Split(pkgName, "-")
+		pkgID, err := strconv.Atoi(splits[len(splits)-1])
+		if err != nil {
+			continue
+		}
+		for event, path := range subTree {
+			domain := event
+			if strings.Index(event, packageEvent) == 0 {
+				domain = packageEvent
+			}
+			if limit, err := readDomainPowerLimit(path); err == nil {
+				limit.PackageID = pkgID
+				limit.Domain = domain
+				limits = append(limits, limit)
+			} else {
+				klog.V(5).Infof("failed to read the power limit of %s: %v", path, err)
+			}
+		}
+	}
+	return limits
+}
+
+func readDomainPowerLimit(path string) (RAPLDomainPowerLimit, error) {
+	limit := RAPLDomainPowerLimit{}
+	enabled, err := readUint64File(path + enabledFile)
+	if err != nil {
+		return limit, err
+	}
+	limit.Enabled = enabled == 1
+	for i := 0; i < maxRAPLConstraintsPerDomain; i++ {
+		powerLimit, err := readUint64File(path + fmt.Sprintf(constraintPowerLimitTemplate, i))
+		if err != nil {
+			break
+		}
+		// the time window is not available on all domains
+		timeWindow, _ := readUint64File(path + fmt.Sprintf(constraintTimeWindowTemplate, i))
+		name := fmt.Sprintf(defaultRAPLConstraintName, i)
+		if data, err := os.ReadFile(path + fmt.Sprintf(constraintNameFileTemplate, i)); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+		limit.Constraints = append(limit.Constraints, RAPLConstraint{
+			Name:       name,
+			PowerLimit: powerLimit,
+			TimeWindow: timeWindow,
+		})
+	}
+	return limit, nil
+}
+
+func readUint64File(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test RAPL power limits", func() {
+	var savedEventPaths map[string]map[string]string
+
+	BeforeEach(func() {
+		savedEventPaths = eventPaths
+	})
+
+	AfterEach(func() {
+		eventPaths = savedEventPaths
+	})
+
+	It("reads the constraints of each domain", func() {
+		root := GinkgoT().TempDir()
+		pkgPath := createRAPLDomain(root, "intel-rapl:1", testMaxEnergyRange)
+		dramPath := createRAPLDomain(root, "intel-rapl:1/intel-rapl:1:0", 0)
+		files := map[string]string{
+			pkgPath + "enabled":                      "1\n",
+			pkgPath + "constraint_0_name":            "long_term\n",
+			pkgPath + "constraint_0_power_limit_uw":  "150000000\n",
+			pkgPath + "constraint_0_time_window_us":  "999424\n",
+			pkgPath + "constraint_1_name":            "short_term\n",
+			pkgPath + "constraint_1_power_limit_uw":  "180000000\n",
+			pkgPath + "constraint_1_time_window_us":  "2440\n",
+			dramPath + "enabled":                     "0\n",
+			dramPath + "constraint_0_power_limit_uw": "0\n",
+		}
+		for file, content := range files {
+			Expect(os.WriteFile(file, []byte(content), 0o644)).To(Succeed())
+		}
+		eventPaths = map[string]map[string]string{
+			"package-1": {
+				"package-1": pkgPath,
+				dramEvent:   dramPath,
+			},
+		}
+
+		limits := (&PowerSysfs{}).GetPowerLimits()
+		Expect(limits).To(ConsistOf(
+			RAPLDomainPowerLimit{
+				PackageID: 1,
+				Domain:    packageEvent,
+				Enabled:   true,
+				Constraints: []RAPLConstraint{
+					{Name: "long_term", PowerLimit: 150000000, TimeWindow: 999424},
+					{Name: "short_term", PowerLimit: 180000000, TimeWindow: 2440},
+				},
+			},
+			RAPLDomainPowerLimit{
+				PackageID:   1,
+				Domain:      dramEvent,
+				Enabled:     false,
+				Constraints: []RAPLConstraint{{Name: "constraint_0"}},
+			},
+		))
+	})
+
+	It("skips the domains without power capping", func() {
+		root := GinkgoT().TempDir()
+		eventPaths = map[string]map[string]string{
+			"package-0": {"package-0": createRAPLDomain(root, "intel-rapl:0", testMaxEnergyRange)},
+		}
+		Expect((&PowerSysfs{}).GetPowerLimits()).To(BeEmpty())
+	})
+})
@@ -30,8 +30,8 @@ const (
 	numPkgPathTemplate      = "/sys/devices/system/cpu/cpu%d/topology/physical_package_id"
 	packageNamePathTemplate = "/sys/class/powercap/intel-rapl/intel-rapl:%d/"
 	eventNamePathTemplate   = "/sys/class/powercap/intel-rapl/intel-rapl:%d/intel-rapl:%d:%d/"
-	cpuInfoPath             = "/proc/cpuinfo"
 	energyFile              = "energy_uj"
+	maxEnergyRangeFile      = "max_energy_range_uj"
 
 	// RAPL number of events (core, dram and uncore)
 	numRAPLEvents = 3
@@ -45,11 +45,14 @@ const (
 
 var (
 	eventPaths map[string]map[string]string
+	// sysfsEnergyCounters handles the energy_uj wraparound of each RAPL domain, the key is the domain path
+	sysfsEnergyCounters = newEnergyCounters()
 )
 
 func init() {
 	eventPaths = map[string]map[string]string{}
 	detectEventPaths()
+	Register(RAPLSysfsSourceName, 40, nil, &PowerSysfs{})
 }
 
 // getEnergy returns the sum of the energy consumption of all sockets for a given event
@@ -84,6 +87,7 @@ func readEventEnergy(eventName string) map[string]uint64 {
 				klog.V(3).Infoln(err)
 				continue
 			}
+			e = sysfsEnergyCounters.update(path, e, func() uint64 { return readMaxEnergyRange(path) })
 			e /= 1000 /*mJ*/
 			energy[pkID] = e
 		}
@@ -91,6 +95,25 @@ func readEventEnergy(eventName string) map[string]uint64 {
 	return energy
 }
 
+// getPackageID returns the physical_package_id of a RAPL package domain named package-<id>, or package-<id>-die-<id> on multi-die CPUs
+func getPackageID(packageName string) (int, error) {
+	splits := strings.Split(packageName, "-")
+	if len(splits) < 2 || splits[0] != "package" {
+		return 0, fmt.Errorf("unknown RAPL package domain %s", packageName)
+	}
+	return strconv.Atoi(splits[1])
+}
+
+// readMaxEnergyRange returns the range of the energy_uj counter of a RAPL domain, 0 if unknown
+func readMaxEnergyRange(path string) uint64 {
+	maxRange, err := readUint64File(path + maxEnergyRangeFile)
+	if err != nil {
+		klog.V(3).Infoln(err)
+		return 0
+	}
+	return maxRange
+}
+
 type PowerSysfs struct{}
 
 func (r *PowerSysfs) IsSystemCollectionSupported() bool {
@@ -123,18 +146,19 @@ func (r *PowerSysfs) GetNodeComponentsEnergy() map[int]NodeComponentsEnergy {
 	dramEnergies := readEventEnergy(dramEvent)
 	uncoreEnergies := readEventEnergy(uncoreEvent)
 
-	for pkgID, pkgEnergy := range pkgEnergies {
-		coreEnergy := coreEnergies[pkgID]
-		dramEnergy := dramEnergies[pkgID]
-		uncoreEnergy := uncoreEnergies[pkgID]
-		splits := strings.Split(pkgID, "-")
-		i, _ := strconv.Atoi(splits[len(splits)-1])
-		packageEnergies[i] = NodeComponentsEnergy{
-			Core:   coreEnergy,
-			DRAM:   dramEnergy,
-			Uncore: uncoreEnergy,
-			Pkg:    pkgEnergy,
+	for pkgName, pkgEnergy := range pkgEnergies {
+		i, err := getPackageID(pkgName)
+		if err != nil {
+			klog.V(3).Infoln(err)
+			continue
 		}
+		// the dies of a multi-die package are summed in the package
+		energy := packageEnergies[i]
+		energy.Core += coreEnergies[pkgName]
+		energy.DRAM += dramEnergies[pkgName]
+		energy.Uncore += uncoreEnergies[pkgName]
+		energy.Pkg += pkgEnergy
+		packageEnergies[i] = energy
 	}
 
 	return packageEnergies
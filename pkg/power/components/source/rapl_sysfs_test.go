@@ -0,0 +1,129 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// typical energy_uj range of an Intel package domain
+	testMaxEnergyRange = 262143328850
+)
+
+// createRAPLDomain creates a synthetic sysfs RAPL domain and returns its path
+func createRAPLDomain(root, name string, maxRange uint64) string {
+	path := filepath.Join(root, name) + "/"
+	Expect(os.MkdirAll(path, 0o755)).To(Succeed())
+	Expect(os.WriteFile(path+"name", []byte(name+"\n"), 0o644)).To(Succeed())
+	if maxRange > 0 {
+		Expect(os.WriteFile(path+maxEnergyRangeFile, []byte(strconv.FormatUint(maxRange, 10)+"\n"), 0o644)).To(Succeed())
+	}
+	return path
+}
+
+func writeRAPLEnergy(path string, energy uint64) {
+	Expect(os.WriteFile(path+energyFile, []byte(strconv.FormatUint(energy, 10)+"\n"), 0o644)).To(Succeed())
+}
+
+var _ = Describe("Test RAPL sysfs wraparound", func() {
+	var (
+		pkgPath, corePath string
+		savedEventPaths   map[string]map[string]string
+	)
+
+	BeforeEach(func() {
+		root := GinkgoT().TempDir()
+		pkgPath = createRAPLDomain(root, "intel-rapl:0", testMaxEnergyRange)
+		corePath = createRAPLDomain(root, "intel-rapl:0/intel-rapl:0:0", 0)
+		savedEventPaths = eventPaths
+		eventPaths = map[string]map[string]string{
+			"package-0": {
+				"package-0": pkgPath,
+				coreEvent:   corePath,
+			},
+		}
+		sysfsEnergyCounters = newEnergyCounters()
+	})
+
+	AfterEach(func() {
+		eventPaths = savedEventPaths
+	})
+
+	It("reads the energy of each package", func() {
+		writeRAPLEnergy(pkgPath, 10000000)
+		writeRAPLEnergy(corePath, 4000000)
+		energy := (&PowerSysfs{}).GetNodeComponentsEnergy()
+		Expect(energy).To(HaveKey(0))
+		Expect(energy[0].Pkg).To(Equal(uint64(10000)))
+		Expect(energy[0].Core).To(Equal(uint64(4000)))
+	})
+
+	It("sums the dies of a package", func() {
+		root := GinkgoT().TempDir()
+		die0Path := createRAPLDomain(root, "intel-rapl:0", testMaxEnergyRange)
+		die1Path := createRAPLDomain(root, "intel-rapl:1", testMaxEnergyRange)
+		package1Path := createRAPLDomain(root, "intel-rapl:2", testMaxEnergyRange)
+		eventPaths = map[string]map[string]string{
+			"package-0-die-0": {"package-0-die-0": die0Path},
+			"package-0-die-1": {"package-0-die-1": die1Path},
+			"package-1-die-0": {"package-1-die-0": package1Path},
+		}
+		writeRAPLEnergy(die0Path, 10000000)
+		writeRAPLEnergy(die1Path, 5000000)
+		writeRAPLEnergy(package1Path, 7000000)
+		// the packages are keyed by their physical_package_id, not by the die
+		energy := (&PowerSysfs{}).GetNodeComponentsEnergy()
+		Expect(energy).To(HaveLen(2))
+		Expect(energy[0].Pkg).To(Equal(uint64(15000)))
+		Expect(energy[1].Pkg).To(Equal(uint64(7000)))
+	})
+
+	It("reconstructs the energy when the counter wraps around", func() {
+		writeRAPLEnergy(pkgPath, testMaxEnergyRange-1000000)
+		first := (&PowerSysfs{}).GetNodeComponentsEnergy()[0].Pkg
+
+		// 1J before the wraparound and 2J after
+		writeRAPLEnergy(pkgPath, 2000000)
+		second := (&PowerSysfs{}).GetNodeComponentsEnergy()[0].Pkg
+		Expect(second - first).To(Equal(uint64(3000)))
+
+		writeRAPLEnergy(pkgPath, 5000000)
+		third := (&PowerSysfs{}).GetNodeComponentsEnergy()[0].Pkg
+		Expect(third - second).To(Equal(uint64(3000)))
+	})
+
+	It("does not report energy when the counter range is unknown", func() {
+		writeRAPLEnergy(pkgPath, 10000000)
+		writeRAPLEnergy(corePath, 8000000)
+		first := (&PowerSysfs{}).GetNodeComponentsEnergy()[0].Core
+
+		writeRAPLEnergy(corePath, 1000000)
+		second := (&PowerSysfs{}).GetNodeComponentsEnergy()[0].Core
+		Expect(second).To(Equal(first))
+
+		writeRAPLEnergy(corePath, 3000000)
+		third := (&PowerSysfs{}).GetNodeComponentsEnergy()[0].Core
+		Expect(third - second).To(Equal(uint64(2000)))
+	})
+})
+
+var _ = Describe("Test RAPL MSR wraparound", func() {
+	It("reconstructs the 32-bit energy status counter", func() {
+		counters := newEnergyCounters()
+		maxRange := func() uint64 { return msrEnergyStatusMaxRange }
+		key := "0-0x611"
+		// the reserved bits must be ignored
+		raw := uint64(0xabcd00000000) | (msrEnergyStatusMask - 100)
+		first := counters.update(key, raw&msrEnergyStatusMask, maxRange)
+		Expect(first).To(Equal(uint64(msrEnergyStatusMask - 100)))
+
+		// 101 units up to 0xffffffff, 1 unit to wrap around to 0 and 50 units after it
+		second := counters.update(key, 50, maxRange)
+		Expect(second - first).To(Equal(uint64(151)))
+		Expect(second).To(BeNumerically(">", uint64(msrEnergyStatusMaxRange)))
+	})
+})
@@ -0,0 +1,146 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// PowerSource is implemented by every node components power source (e.g. RAPL sysfs, MSR).
+// Out-of-tree sources can implement it and call Register from an init function.
+type PowerSource interface {
+	// GetEnergyFromDram returns mJ in DRAM
+	GetEnergyFromDram() (uint64, error)
+	// GetEnergyFromCore returns mJ in CPU cores
+	GetEnergyFromCore() (uint64, error)
+	// GetEnergyFromUncore returns mJ in uncore (i.e. iGPU)
+	GetEnergyFromUncore() (uint64, error)
+	// GetEnergyFromPackage returns mJ in package
+	GetEnergyFromPackage() (uint64, error)
+	// GetNodeComponentsEnergy returns set of mJ per RAPL components, keyed by the physical_package_id of the CPU package
+	// so that the packages match the collector_metric.NodeCPUPackageMap of the CPU time per package
+	GetNodeComponentsEnergy() map[int]NodeComponentsEnergy
+	// StopPower stops the collection
+	StopPower()
+	// IsSystemCollectionSupported returns if it is possible to use this collector
+	IsSystemCollectionSupported() bool
+}
+
+// ProbeFunc reports whether a power source can be used on this node
+type ProbeFunc func() bool
+
+type registeredSource struct {
+	name     string
+	priority int
+	probe    ProbeFunc
+	source   PowerSource
+}
+
+const (
+	// names of the in-tree power sources
+	RAPLSysfsSourceName = "rapl-sysfs"
+	RAPLMSRSourceName   = "rapl-msr"
+	AMDEnergySourceName = "amd-energy-hwmon"
+	ApmXgeneSourceName  = "apm-xgene-sysfs"
+	EstimateSourceName  = "estimate"
+)
+
+var (
+	registryLock sync.Mutex
+	registry     = map[string]*registeredSource{}
+)
+
+// Register adds a power source to the registry.
+// When no source is explicitly selected, the supported source with the highest priority is used.
+// Registering a name twice replaces the previous entry.
+func Register(name string, priority int, probe ProbeFunc, source PowerSource) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if probe == nil {
+		probe = source.IsSystemCollectionSupported
+	}
+	registry[name] = &registeredSource{
+		name:     name,
+		priority: priority,
+		probe:    probe,
+		source:   source,
+	}
+}
+
+// Unregister removes a power source from the registry
+func Unregister(name string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, name)
+}
+
+// RegisteredSources returns the names of the registered power sources ordered by priority
+func RegisteredSources() []string {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	names := []string{}
+	for _, s := range sortedSources() {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// sortedSources returns the registered sources from the highest to the lowest priority, the registryLock must be held
+func sortedSources() []*registeredSource {
+	sources := make([]*registeredSource, 0, len(registry))
+	for _, s := range registry {
+		sources = append(sources, s)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].priority == sources[j].priority {
+			return sources[i].name < sources[j].name
+		}
+		return sources[i].priority > sources[j].priority
+	})
+	return sources
+}
+
+// SelectPowerSource returns the name and the implementation of the power source to use.
+// If override is set and the named source is registered and supported, it is used.
+// Otherwise the supported source with the highest priority is returned.
+// If no source is supported, the estimate source is returned.
+func SelectPowerSource(override string) (string, PowerSource) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if override != "" {
+		if s, found := registry[override]; found {
+			if s.probe() {
+				return s.name, s.source
+			}
+			klog.Warningf("power source %s is not supported on this node, fall back to auto selection", override)
+		} else {
+			klog.Warningf("power source %s is not registered, fall back to auto selection", override)
+		}
+	}
+	for _, s := range sortedSources() {
+		if s.name == override {
+			continue
+		}
+		if s.probe() {
+			return s.name, s.source
+		}
+	}
+	return EstimateSourceName, &PowerEstimate{}
+}
@@ -0,0 +1,72 @@
+package source
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Power Source Registry", func() {
+	const (
+		highSource = "test-high"
+		lowSource  = "test-low"
+	)
+	var (
+		highSupported bool
+		highImpl      = &PowerDummy{}
+		lowImpl       = &PowerDummy{}
+	)
+
+	BeforeEach(func() {
+		highSupported = true
+		Register(highSource, 1000, func() bool { return highSupported }, highImpl)
+		Register(lowSource, 999, func() bool { return true }, lowImpl)
+	})
+
+	AfterEach(func() {
+		Unregister(highSource)
+		Unregister(lowSource)
+	})
+
+	It("lists the registered sources by priority", func() {
+		names := RegisteredSources()
+		Expect(names[0]).To(Equal(highSource))
+		Expect(names[1]).To(Equal(lowSource))
+		Expect(names).To(ContainElement(EstimateSourceName))
+	})
+
+	It("selects the supported source with the highest priority", func() {
+		name, impl := SelectPowerSource("")
+		Expect(name).To(Equal(highSource))
+		Expect(impl).To(BeIdenticalTo(highImpl))
+
+		highSupported = false
+		name, impl = SelectPowerSource("")
+		Expect(name).To(Equal(lowSource))
+		Expect(impl).To(BeIdenticalTo(lowImpl))
+	})
+
+	It("selects the overridden source", func() {
+		name, impl := SelectPowerSource(lowSource)
+		Expect(name).To(Equal(lowSource))
+		Expect(impl).To(BeIdenticalTo(lowImpl))
+	})
+
+	It("falls back to auto selection when the overridden source cannot be used", func() {
+		name, _ := SelectPowerSource("not-registered")
+		Expect(name).To(Equal(highSource))
+
+		highSupported = false
+		Register(highSource+"-override", 0, func() bool { return false }, highImpl)
+		defer Unregister(highSource + "-override")
+		name, _ = SelectPowerSource(highSource + "-override")
+		Expect(name).To(Equal(lowSource))
+	})
+
+	It("falls back to the estimate source", func() {
+		Unregister(highSource)
+		Unregister(lowSource)
+		name, impl := SelectPowerSource(EstimateSourceName)
+		Expect(name).To(Equal(EstimateSourceName))
+		Expect(impl.IsSystemCollectionSupported()).To(BeFalse())
+	})
+})
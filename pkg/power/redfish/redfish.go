@@ -0,0 +1,282 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redfish
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	chassisCollectionPath = "/redfish/v1/Chassis"
+	chassisPowerSuffix    = "/Power"
+	requestTimeout        = 10 * time.Second
+	sensorIDPrefix        = "redfish"
+)
+
+// chassisCollection is the subset of the Redfish ChassisCollection resource used by kepler
+type chassisCollection struct {
+	Members []odataID `json:"Members"`
+}
+
+type odataID struct {
+	ID string `json:"@odata.id"`
+}
+
+// chassisPower is the subset of the Redfish Power resource used by kepler
+type chassisPower struct {
+	PowerControl []powerControl `json:"PowerControl"`
+}
+
+type powerControl struct {
+	MemberID string `json:"MemberId"`
+	Name     string `json:"Name"`
+	// PowerConsumedWatts is null when the BMC cannot measure it
+	PowerConsumedWatts *float64 `json:"PowerConsumedWatts"`
+}
+
+// Redfish reads the node power consumption from the Chassis/Power resource exposed by the BMC.
+// The BMC is probed less often than the metrics are collected, the last read power is held until the next probe so that
+// the energy of each collection is the held power integrated over its elapsed time, instead of a burst at every probe.
+type Redfish struct {
+	endpoint      string
+	username      string
+	password      string
+	probeInterval time.Duration
+	client        *http.Client
+
+	// powerPaths are the Chassis/Power resources that report the power consumption
+	powerPaths []string
+	// sensorPower is the last power in mW read from each sensor
+	sensorPower map[string]float64 /*sensorID:value*/
+	// systemEnergy is the system accumulated energy consumption in mJ since the last read
+	systemEnergy map[string]float64 /*sensorID:value*/
+	// lastEnergyTime is the time up to which the held power was integrated into systemEnergy
+	lastEnergyTime time.Time
+	collectEnergy  bool
+	stopChannel    chan bool
+
+	mu sync.Mutex
+}
+
+// NewRedfishPowerMeter creates a Redfish power meter for the BMC at endpoint, e.g. https://10.0.0.1
+// The BMC is only contacted by Run, the power meter is not supported if the endpoint is empty or if no chassis reports its power consumption.
+func NewRedfishPowerMeter(endpoint, username, password string, skipSSLVerify bool, probeInterval time.Duration) *Redfish {
+	r := &Redfish{
+		endpoint:      strings.TrimSuffix(endpoint, "/"),
+		username:      username,
+		password:      password,
+		probeInterval: probeInterval,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			Transport: &http.Transport{
+				// BMCs are commonly deployed with self-signed certificates
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: skipSSLVerify}, //nolint:gosec // configurable by the user
+			},
+		},
+		sensorPower:  map[string]float64{},
+		systemEnergy: map[string]float64{},
+		stopChannel:  make(chan bool),
+	}
+	return r
+}
+
+// start discovers the chassis reporting power and reads their power for the first time
+func (r *Redfish) start() error {
+	if err := r.discoverChassis(); err != nil {
+		return err
+	}
+	if err := r.updatePower(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.collectEnergy = true
+	r.mu.Unlock()
+	klog.V(1).Infof("Using the Redfish power meter in %s: %v\n", r.endpoint, r.powerPaths)
+	return nil
+}
+
+func (r *Redfish) IsPowerSupported() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.collectEnergy
+}
+
+// Run discovers the BMC chassis in background and then periodically reads their power
+func (r *Redfish) Run() {
+	if r.endpoint == "" {
+		return
+	}
+	go func() {
+		// the BMC can be slow to answer, the other platform power sources are used until the discovery completes
+		started := r.tryStart()
+		ticker := time.NewTicker(r.probeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopChannel:
+				return
+			case <-ticker.C:
+				if !started {
+					// the BMC can be unreachable at boot, the discovery is retried at each probe
+					started = r.tryStart()
+					continue
+				}
+				if err := r.updatePower(); err != nil {
+					// the BMC can be temporarily unavailable (e.g., during a reset), the last power is held until it answers
+					klog.V(3).Infof("failed to read the Redfish power: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// tryStart starts the power meter and logs the failure, it returns whether the power meter is started
+func (r *Redfish) tryStart() bool {
+	if err := r.start(); err != nil {
+		klog.Infof("Could not find any Redfish power meter in %s, retrying in %v: %v\n", r.endpoint, r.probeInterval, err)
+		return false
+	}
+	return true
+}
+
+func (r *Redfish) Stop() {
+	close(r.stopChannel)
+}
+
+// GetEnergyFromHost returns the accumulated energy consumption and reset the counter
+func (r *Redfish) GetEnergyFromHost() (map[string]float64, error) {
+	energy := map[string]float64{}
+	r.mu.Lock()
+	r.integratePower(time.Now())
+	// reset counter when readed to prevent overflow
+	for sensorID := range r.systemEnergy {
+		energy[sensorID] = r.systemEnergy[sensorID]
+		r.systemEnergy[sensorID] = 0
+	}
+	r.mu.Unlock()
+	return energy, nil
+}
+
+// updatePower reads the current power, the previous power is integrated up to now before being replaced
+func (r *Redfish) updatePower() error {
+	sensorPower, err := r.getPowerFromSensor()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.integratePower(time.Now())
+	for sensorID, power := range sensorPower {
+		r.sensorPower[sensorID] = power
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// integratePower adds the energy of the held power since the last integration, it must be called with the lock held
+func (r *Redfish) integratePower(now time.Time) {
+	if !r.lastEnergyTime.IsZero() {
+		elapsed := now.Sub(r.lastEnergyTime).Seconds()
+		for sensorID, power := range r.sensorPower {
+			// energy (mJ) is equal to miliwatts*time(second)
+			r.systemEnergy[sensorID] += power * elapsed
+		}
+	}
+	r.lastEnergyTime = now
+}
+
+// discoverChassis finds the chassis that report their power consumption
+func (r *Redfish) discoverChassis() error {
+	// the discovery is retried until it succeeds, the chassis of a previous attempt are discarded
+	r.powerPaths = nil
+	var chassis chassisCollection
+	if err := r.get(chassisCollectionPath, &chassis); err != nil {
+		return err
+	}
+	for _, member := range chassis.Members {
+		powerPath := member.ID + chassisPowerSuffix
+		var power chassisPower
+		if err := r.get(powerPath, &power); err != nil {
+			klog.V(3).Infof("chassis %s does not expose its power: %v\n", member.ID, err)
+			continue
+		}
+		for _, control := range power.PowerControl {
+			if control.PowerConsumedWatts != nil {
+				r.powerPaths = append(r.powerPaths, powerPath)
+				break
+			}
+		}
+	}
+	if len(r.powerPaths) == 0 {
+		return fmt.Errorf("no chassis reports PowerConsumedWatts")
+	}
+	return nil
+}
+
+// getPowerFromSensor returns the power consumption in miliWatts of each chassis power control
+func (r *Redfish) getPowerFromSensor() (map[string]float64, error) {
+	power := map[string]float64{}
+	for _, powerPath := range r.powerPaths {
+		var chassisPower chassisPower
+		if err := r.get(powerPath, &chassisPower); err != nil {
+			return power, err
+		}
+		chassisID := path.Base(path.Dir(powerPath))
+		for i, control := range chassisPower.PowerControl {
+			if control.PowerConsumedWatts == nil {
+				continue
+			}
+			memberID := control.MemberID
+			if memberID == "" {
+				memberID = fmt.Sprint(i)
+			}
+			power[sensorIDPrefix+"-"+chassisID+"-"+memberID] = *control.PowerConsumedWatts * 1000 /*miliWatts*/
+		}
+	}
+	return power, nil
+}
+
+func (r *Redfish) get(resourcePath string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, r.endpoint+resourcePath, http.NoBody)
+	if err != nil {
+		return err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %s: %s", resourcePath, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", resourcePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package redfish
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testUsername = "admin"
+	testPassword = "secret"
+)
+
+// fakeBMC is a minimal Redfish service with one chassis reporting power and one enclosure without power
+type fakeBMC struct {
+	mu          sync.Mutex
+	watts       float64
+	unavailable bool
+}
+
+func (f *fakeBMC) setWatts(watts float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watts = watts
+}
+
+func (f *fakeBMC) setAvailable(available bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unavailable = !available
+}
+
+func (f *fakeBMC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	unavailable := f.unavailable
+	f.mu.Unlock()
+	if unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok || username != testUsername || password != testPassword {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/redfish/v1/Chassis":
+		fmt.Fprint(w, `{"Members": [{"@odata.id": "/redfish/v1/Chassis/1"}, {"@odata.id": "/redfish/v1/Chassis/Enclosure"}]}`)
+	case "/redfish/v1/Chassis/1/Power":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		fmt.Fprintf(w, `{"PowerControl": [{"MemberId": "0", "Name": "System Power Control", "PowerConsumedWatts": %f}]}`, f.watts)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+var _ = Describe("Test Redfish Power Meter", func() {
+	var (
+		bmc    *fakeBMC
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		bmc = &fakeBMC{watts: 200}
+		server = httptest.NewServer(bmc)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("is not supported without endpoint", func() {
+		r := NewRedfishPowerMeter("", "", "", false, time.Second)
+		r.Run()
+		defer r.Stop()
+		Expect(r.IsPowerSupported()).To(BeFalse())
+	})
+
+	It("is not supported with wrong credentials", func() {
+		r := NewRedfishPowerMeter(server.URL, testUsername, "wrong", false, time.Second)
+		Expect(r.start()).NotTo(Succeed())
+		Expect(r.IsPowerSupported()).To(BeFalse())
+	})
+
+	It("discovers the chassis reporting power", func() {
+		r := NewRedfishPowerMeter(server.URL+"/", testUsername, testPassword, false, time.Second)
+		// the BMC is not contacted before the power meter runs
+		Expect(r.IsPowerSupported()).To(BeFalse())
+		Expect(r.start()).To(Succeed())
+		Expect(r.IsPowerSupported()).To(BeTrue())
+		Expect(r.powerPaths).To(Equal([]string{"/redfish/v1/Chassis/1/Power"}))
+
+		power, err := r.getPowerFromSensor()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(power).To(HaveKeyWithValue("redfish-1-0", 200000.0))
+	})
+
+	It("integrates the held power over the elapsed time", func() {
+		r := NewRedfishPowerMeter(server.URL, testUsername, testPassword, false, time.Second)
+		Expect(r.start()).To(Succeed())
+
+		// 200W is held during 2s until the next probe reads 100W, which is held during 3s
+		r.lastEnergyTime = time.Now().Add(-2 * time.Second)
+		bmc.setWatts(100)
+		Expect(r.updatePower()).To(Succeed())
+		r.lastEnergyTime = r.lastEnergyTime.Add(-3 * time.Second)
+		energy, err := r.GetEnergyFromHost()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(energy["redfish-1-0"]).To(BeNumerically("~", 700000, 1000))
+
+		// the counter is reset after being read, and each read gets the held power during its elapsed time
+		r.lastEnergyTime = r.lastEnergyTime.Add(-time.Second)
+		energy, err = r.GetEnergyFromHost()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(energy["redfish-1-0"]).To(BeNumerically("~", 100000, 1000))
+	})
+
+	It("holds the last power when the BMC is unavailable", func() {
+		r := NewRedfishPowerMeter(server.URL, testUsername, testPassword, false, time.Second)
+		Expect(r.start()).To(Succeed())
+
+		server.Close()
+		Expect(r.updatePower()).NotTo(Succeed())
+		r.lastEnergyTime = r.lastEnergyTime.Add(-time.Second)
+		energy, _ := r.GetEnergyFromHost()
+		Expect(energy["redfish-1-0"]).To(BeNumerically("~", 200000, 1000))
+	})
+
+	It("retries the discovery until the BMC answers", func() {
+		bmc.setAvailable(false)
+		r := NewRedfishPowerMeter(server.URL, testUsername, testPassword, false, 10*time.Millisecond)
+		r.Run()
+		defer r.Stop()
+		Consistently(r.IsPowerSupported, 50*time.Millisecond).Should(BeFalse())
+
+		bmc.setAvailable(true)
+		Eventually(r.IsPowerSupported).Should(BeTrue())
+	})
+
+	It("collects the energy in background", func() {
+		r := NewRedfishPowerMeter(server.URL, testUsername, testPassword, false, 10*time.Millisecond)
+		r.Run()
+		defer r.Stop()
+		Eventually(r.IsPowerSupported).Should(BeTrue())
+		Eventually(func() float64 {
+			energy, _ := r.GetEnergyFromHost()
+			return energy["redfish-1-0"]
+		}).Should(BeNumerically(">", 0))
+	})
+})